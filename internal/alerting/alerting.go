@@ -0,0 +1,324 @@
+// Package alerting notifies webhooks when an endpoint goes down after
+// consecutive failures or comes back up after consecutive successes, so
+// outages are reported without Prometheus and Alertmanager.
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/metrics"
+	"github.com/dntosas/astrolavos/internal/model"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// State is the health of an endpoint as seen by the notifier.
+type State string
+
+// Endpoints start unknown until enough consecutive results agree.
+const (
+	StateUnknown State = "unknown"
+	StateUp      State = "up"
+	StateDown    State = "down"
+)
+
+// queueSize is the number of notifications waiting for delivery before new
+// ones are dropped.
+const queueSize = 100
+
+// Notification is the payload describing a state transition. It is sent as
+// is by json webhooks and is the data of webhook templates. Consecutive is
+// the number of results in a row that caused the transition and
+// LastLatencySeconds the total latency of the last successful probe.
+type Notification struct {
+	Endpoint           string            `json:"endpoint"`
+	ProberType         string            `json:"prober_type"`
+	Tag                string            `json:"tag,omitempty"`
+	Labels             map[string]string `json:"labels,omitempty"`
+	Source             string            `json:"source"`
+	State              State             `json:"state"`
+	PreviousState      State             `json:"previous_state"`
+	Consecutive        int               `json:"consecutive"`
+	Error              string            `json:"error,omitempty"`
+	ErrorCategory      string            `json:"error_category,omitempty"`
+	StatusCode         string            `json:"status_code,omitempty"`
+	LastLatencySeconds float64           `json:"last_latency_seconds"`
+	Time               time.Time         `json:"time"`
+}
+
+// endpointState tracks the consecutive results of an endpoint.
+type endpointState struct {
+	state       State
+	failures    int
+	successes   int
+	lastLatency time.Duration
+}
+
+// Notifier observes probe results and delivers a notification to every
+// webhook on each state transition. Delivery happens in the background so
+// probers are never held up by a slow receiver.
+type Notifier struct {
+	cfg    model.Alerting
+	source string
+	client *http.Client
+
+	mu     sync.Mutex
+	states map[model.Key]*endpointState
+	queue  chan Notification
+	closed bool
+	done   chan struct{}
+
+	// ctx bounds the deliveries and is canceled by Close
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewNotifier creates a Notifier and starts its delivery loop. Source
+// identifies this instance in notifications.
+func NewNotifier(cfg model.Alerting, source string) *Notifier {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	n := &Notifier{
+		cfg:    cfg,
+		source: source,
+		client: &http.Client{},
+		states: map[model.Key]*endpointState{},
+		queue:  make(chan Notification, queueSize),
+		done:   make(chan struct{}),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	go n.deliverLoop()
+
+	return n
+}
+
+// Observe records the result of a probe of e and queues a notification if
+// it changes the state of the endpoint. An endpoint becoming up for the
+// first time is not notified, as it is the expected state.
+func (n *Notifier) Observe(e *model.Endpoint, r model.Result) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		return
+	}
+
+	s, ok := n.states[e.Key()]
+	if !ok {
+		s = &endpointState{state: StateUnknown}
+		n.states[e.Key()] = s
+	}
+
+	previous := s.state
+
+	if r.Success() {
+		s.failures = 0
+		s.successes++
+		s.lastLatency = r.Total
+
+		if s.state != StateUp && s.successes >= n.cfg.SuccessThreshold {
+			s.state = StateUp
+		}
+	} else {
+		s.successes = 0
+		s.failures++
+
+		if s.state != StateDown && s.failures >= n.cfg.FailureThreshold {
+			s.state = StateDown
+		}
+	}
+
+	if s.state == previous || (previous == StateUnknown && s.state == StateUp) {
+		return
+	}
+
+	notification := Notification{
+		Endpoint:           e.URI,
+		ProberType:         e.ProberType,
+		Tag:                e.Tag,
+		Labels:             e.Labels,
+		Source:             n.source,
+		State:              s.state,
+		PreviousState:      previous,
+		Consecutive:        max(s.failures, s.successes),
+		StatusCode:         r.StatusCode,
+		LastLatencySeconds: s.lastLatency.Seconds(),
+		Time:               r.Time.UTC(),
+	}
+
+	if r.Err != nil {
+		notification.Error = r.Err.Error()
+		notification.ErrorCategory = metrics.CategorizeError(r.Err)
+	}
+
+	log.WithFields(log.Fields{
+		"endpoint": e.URI,
+		"state":    s.state,
+	}).Info("Endpoint state changed")
+
+	select {
+	case n.queue <- notification:
+	default:
+		log.WithField("endpoint", e.URI).Warn("Alerting queue is full, dropping notification")
+	}
+}
+
+// Remove forgets the state of an endpoint that is no longer probed.
+func (n *Notifier) Remove(key model.Key) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	delete(n.states, key)
+}
+
+// Close stops accepting results and waits until the queued notifications
+// are delivered or ctx is done, in which case the pending deliveries are
+// canceled.
+func (n *Notifier) Close(ctx context.Context) {
+	defer n.cancel()
+
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.queue)
+	}
+	n.mu.Unlock()
+
+	select {
+	case <-n.done:
+	case <-ctx.Done():
+		log.Warn("Gave up waiting for alerting notifications to be delivered")
+	}
+}
+
+// deliverLoop sends queued notifications to every webhook in order.
+func (n *Notifier) deliverLoop() {
+	defer close(n.done)
+
+	for notification := range n.queue {
+		for _, w := range n.cfg.Webhooks {
+			if err := n.deliver(n.ctx, w, notification); err != nil {
+				log.WithError(err).WithField("endpoint", notification.Endpoint).Error("Failed to deliver alerting webhook")
+			}
+		}
+	}
+}
+
+// deliver posts a notification to a webhook, retrying with exponential
+// backoff on network errors, 429 and 5xx responses, until ctx is done.
+func (n *Notifier) deliver(ctx context.Context, w model.Webhook, notification Notification) error {
+	body, err := Render(w, notification)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+
+	for attempt := range w.Retries {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("webhook delivery canceled: %w", errors.Join(lastErr, ctx.Err()))
+			case <-time.After(time.Duration(500*(1<<min(attempt-1, 6))) * time.Millisecond):
+			}
+		}
+
+		var retry bool
+
+		retry, lastErr = n.post(ctx, w, body)
+		if lastErr == nil || !retry {
+			return lastErr
+		}
+
+		log.WithError(lastErr).Debugf("Webhook attempt %d/%d failed", attempt+1, w.Retries)
+	}
+
+	return fmt.Errorf("giving up after %d attempts: %w", w.Retries, lastErr)
+}
+
+// post sends a single webhook request and reports whether a failure is
+// worth retrying.
+func (n *Notifier) post(ctx context.Context, w model.Webhook, body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, w.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("creation of webhook request failed: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	for name, value := range w.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("webhook request failed: %w", err)
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+
+	return retry, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+}
+
+// Render returns the request body of a notification for a webhook: its
+// template if set, otherwise the notification in the webhook format.
+func Render(w model.Webhook, notification Notification) ([]byte, error) {
+	if w.Template != nil {
+		var buf bytes.Buffer
+		if err := w.Template.Execute(&buf, notification); err != nil {
+			return nil, fmt.Errorf("rendering webhook template failed: %w", err)
+		}
+
+		return buf.Bytes(), nil
+	}
+
+	switch w.Format {
+	case "slack":
+		return json.Marshal(map[string]string{"text": slackText(notification)})
+	case "json", "":
+		return json.Marshal(notification)
+	default:
+		return nil, errors.New("unknown webhook format: " + w.Format)
+	}
+}
+
+// slackText formats a notification as Slack mrkdwn.
+func slackText(n Notification) string {
+	where := n.ProberType
+	if n.Tag != "" {
+		where += ", tag " + n.Tag
+	}
+
+	if n.State == StateDown {
+		text := fmt.Sprintf(":red_circle: *DOWN* `%s` (%s) from %s after %d consecutive failures",
+			n.Endpoint, where, n.Source, n.Consecutive)
+		if n.ErrorCategory != "" {
+			text += fmt.Sprintf("\n*%s*: %s", n.ErrorCategory, n.Error)
+		}
+
+		return text
+	}
+
+	return fmt.Sprintf(":large_green_circle: *UP* `%s` (%s) from %s after %d consecutive successes, latency %.1fms",
+		n.Endpoint, where, n.Source, n.Consecutive, n.LastLatencySeconds*1000)
+}
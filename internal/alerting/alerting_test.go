@@ -0,0 +1,235 @@
+package alerting_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/alerting"
+	"github.com/dntosas/astrolavos/internal/model"
+)
+
+// receiver records webhook bodies, failing the first failures requests.
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+	bodies   []string
+	headers  []http.Header
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.calls++
+
+	if rc.failures > 0 {
+		rc.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	rc.bodies = append(rc.bodies, string(body))
+	rc.headers = append(rc.headers, r.Header.Clone())
+}
+
+var testEndpoint = &model.Endpoint{URI: "https://example.com", ProberType: "httpTrace", Tag: "prod"}
+
+func failure() model.Result {
+	return model.Result{Time: time.Now(), Err: context.DeadlineExceeded}
+}
+
+func success() model.Result {
+	return model.Result{Time: time.Now(), StatusCode: "200", Total: 120 * time.Millisecond}
+}
+
+func TestNotifier_Transitions(t *testing.T) {
+	rc := &receiver{}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	n := alerting.NewNotifier(model.Alerting{
+		FailureThreshold: 3,
+		SuccessThreshold: 2,
+		Webhooks: []model.Webhook{{
+			URL:     srv.URL,
+			Format:  "json",
+			Headers: map[string]string{"Authorization": "Bearer token"},
+			Retries: 1,
+			Timeout: time.Second,
+		}},
+	}, "eu-1")
+
+	// Coming up for the first time is not notified
+	n.Observe(testEndpoint, success())
+	n.Observe(testEndpoint, success())
+
+	// Down only after 3 consecutive failures
+	n.Observe(testEndpoint, failure())
+	n.Observe(testEndpoint, failure())
+	n.Observe(testEndpoint, success())
+	n.Observe(testEndpoint, failure())
+	n.Observe(testEndpoint, failure())
+	n.Observe(testEndpoint, failure())
+	n.Observe(testEndpoint, failure())
+
+	// Up again after 2 consecutive successes
+	n.Observe(testEndpoint, success())
+	n.Observe(testEndpoint, success())
+	n.Observe(testEndpoint, success())
+
+	n.Close(context.Background())
+
+	if len(rc.bodies) != 2 {
+		t.Fatalf("expected 2 notifications, got %d: %v", len(rc.bodies), rc.bodies)
+	}
+
+	var down, up alerting.Notification
+	if err := json.Unmarshal([]byte(rc.bodies[0]), &down); err != nil {
+		t.Fatalf("failed to parse notification: %v", err)
+	}
+
+	if err := json.Unmarshal([]byte(rc.bodies[1]), &up); err != nil {
+		t.Fatalf("failed to parse notification: %v", err)
+	}
+
+	if down.State != alerting.StateDown || down.PreviousState != alerting.StateUp || down.Consecutive != 3 ||
+		down.ErrorCategory != "timeout" || down.Endpoint != "https://example.com" || down.Tag != "prod" ||
+		down.Source != "eu-1" || down.LastLatencySeconds != 0.12 {
+		t.Errorf("unexpected down notification: %+v", down)
+	}
+
+	if up.State != alerting.StateUp || up.PreviousState != alerting.StateDown || up.Consecutive != 2 || up.Error != "" {
+		t.Errorf("unexpected up notification: %+v", up)
+	}
+
+	if rc.headers[0].Get("Authorization") != "Bearer token" || rc.headers[0].Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected headers: %v", rc.headers[0])
+	}
+
+	// Results after closing are ignored
+	n.Observe(testEndpoint, failure())
+}
+
+func TestNotifier_RetriesDelivery(t *testing.T) {
+	rc := &receiver{failures: 1}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	n := alerting.NewNotifier(model.Alerting{
+		FailureThreshold: 1,
+		SuccessThreshold: 1,
+		Webhooks:         []model.Webhook{{URL: srv.URL, Format: "slack", Retries: 2, Timeout: time.Second}},
+	}, "eu-1")
+
+	n.Observe(testEndpoint, model.Result{Time: time.Now(), Err: errors.New("dial tcp: connection refused")})
+	n.Close(context.Background())
+
+	if rc.calls != 2 || len(rc.bodies) != 1 {
+		t.Fatalf("expected delivery on the second attempt, got %d calls and %d bodies", rc.calls, len(rc.bodies))
+	}
+
+	var slack map[string]string
+	if err := json.Unmarshal([]byte(rc.bodies[0]), &slack); err != nil {
+		t.Fatalf("failed to parse Slack message: %v", err)
+	}
+
+	if !strings.Contains(slack["text"], "*DOWN* `https://example.com`") || !strings.Contains(slack["text"], "connection_refused") {
+		t.Errorf("unexpected Slack message: %q", slack["text"])
+	}
+}
+
+func TestNotifier_CloseCancelsDelivery(t *testing.T) {
+	rc := &receiver{failures: 100}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	n := alerting.NewNotifier(model.Alerting{
+		FailureThreshold: 1,
+		SuccessThreshold: 1,
+		Webhooks:         []model.Webhook{{URL: srv.URL, Format: "json", Retries: 10, Timeout: time.Second}},
+	}, "eu-1")
+
+	n.Observe(testEndpoint, failure())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	n.Close(ctx)
+
+	// Giving up on Close cancels the backoff, so the delivery ends at once
+	closed := make(chan struct{})
+
+	go func() {
+		n.Close(context.Background())
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the pending delivery to be canceled")
+	}
+}
+
+func TestNotifier_GivesUpOnClientErrors(t *testing.T) {
+	var calls int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	n := alerting.NewNotifier(model.Alerting{
+		FailureThreshold: 1,
+		SuccessThreshold: 1,
+		Webhooks:         []model.Webhook{{URL: srv.URL, Format: "json", Retries: 3, Timeout: time.Second}},
+	}, "eu-1")
+
+	n.Observe(testEndpoint, failure())
+	n.Close(context.Background())
+
+	if calls != 1 {
+		t.Errorf("expected a 4xx response not to be retried, got %d calls", calls)
+	}
+}
+
+func TestRender_Template(t *testing.T) {
+	tmpl := template.Must(template.New("webhook").Parse(`{"summary": "{{.Endpoint}} is {{.State}} ({{.ErrorCategory}})"}`))
+
+	body, err := alerting.Render(model.Webhook{Template: tmpl}, alerting.Notification{
+		Endpoint:      "https://example.com",
+		State:         alerting.StateDown,
+		ErrorCategory: "timeout",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if string(body) != `{"summary": "https://example.com is down (timeout)"}` {
+		t.Errorf("unexpected body: %s", body)
+	}
+
+	up, err := alerting.Render(model.Webhook{Format: "slack"}, alerting.Notification{
+		Endpoint:           "https://example.com",
+		ProberType:         "httpTrace",
+		State:              alerting.StateUp,
+		Consecutive:        1,
+		LastLatencySeconds: 0.1205,
+	})
+	if err != nil || !strings.Contains(string(up), "*UP*") || !strings.Contains(string(up), "latency 120.5ms") {
+		t.Errorf("unexpected Slack body %s: %v", up, err)
+	}
+}
@@ -0,0 +1,330 @@
+// Package bench runs a fixed number of probes against an endpoint from
+// concurrent workers and summarizes the latency of each phase, the
+// throughput and the failures of the run.
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/metrics"
+	"github.com/dntosas/astrolavos/internal/probers"
+	"github.com/dntosas/astrolavos/internal/report"
+)
+
+// Phases of a probe, in the order they happen. FirstByte spans every phase
+// up to the first response byte and Total the whole probe.
+const (
+	PhaseDNS              = "dns"
+	PhaseConnect          = "connect"
+	PhaseTLS              = "tls"
+	PhaseServerProcessing = "server_processing"
+	PhaseTransfer         = "transfer"
+	PhaseFirstByte        = "first_byte"
+	PhaseTotal            = "total"
+)
+
+// Phases lists every phase summarized by a run.
+var Phases = []string{
+	PhaseDNS, PhaseConnect, PhaseTLS, PhaseServerProcessing, PhaseTransfer, PhaseFirstByte, PhaseTotal,
+}
+
+// Options configures a run.
+type Options struct {
+	// URL and Tag identify the benchmarked endpoint in the result.
+	URL string
+	Tag string
+	// Probes is the number of probes to run.
+	Probes int
+	// Workers is the number of probes in flight at once.
+	Workers int
+	// Rate caps the probes started per second. Zero runs them back to back.
+	Rate float64
+	// Measure runs a single probe.
+	Measure func(ctx context.Context) (probers.Measurement, error)
+	// OnProbe is called with the outcome of every probe, from the worker
+	// that ran it. Optional.
+	OnProbe func(m probers.Measurement, err error)
+}
+
+// Stats summarizes the latency of a phase over the successful probes of a
+// run, in seconds.
+type Stats struct {
+	Min  float64 `json:"min_seconds"`
+	Mean float64 `json:"mean_seconds"`
+	P50  float64 `json:"p50_seconds"`
+	P90  float64 `json:"p90_seconds"`
+	P99  float64 `json:"p99_seconds"`
+	Max  float64 `json:"max_seconds"`
+}
+
+// Result is the summary of a run. Probes interrupted by the end of the run
+// are not counted.
+type Result struct {
+	URL        string  `json:"url"`
+	Tag        string  `json:"tag,omitempty"`
+	Probes     int     `json:"probes"`
+	Succeeded  int     `json:"succeeded"`
+	Failed     int     `json:"failed"`
+	Workers    int     `json:"workers"`
+	TargetRate float64 `json:"target_rate,omitempty"`
+	Duration   float64 `json:"duration_seconds"`
+	// Throughput is the number of probes completed per second.
+	Throughput float64 `json:"throughput"`
+	// StatusCodes counts the probes by response status code.
+	StatusCodes map[string]int `json:"status_codes,omitempty"`
+	// Errors counts the failed probes by error category.
+	Errors map[string]int `json:"errors,omitempty"`
+	// Phases summarizes every phase by name, for the successful probes.
+	Phases map[string]Stats `json:"phases,omitempty"`
+}
+
+// Run runs the probes of opts and returns their summary once all of them
+// finished or ctx is done.
+func Run(ctx context.Context, opts Options) Result {
+	workers := max(min(opts.Workers, opts.Probes), 1)
+
+	var (
+		mu          sync.Mutex
+		wg          sync.WaitGroup
+		samples     []probers.Measurement
+		statusCodes = map[string]int{}
+		errs        = map[string]int{}
+		failed      int
+	)
+
+	jobs := make(chan struct{})
+	start := time.Now()
+
+	go dispatch(ctx, jobs, opts.Probes, opts.Rate)
+
+	for range workers {
+		wg.Go(func() {
+			for range jobs {
+				m, err := opts.Measure(ctx)
+				if ctx.Err() != nil {
+					return
+				}
+
+				if opts.OnProbe != nil {
+					opts.OnProbe(m, err)
+				}
+
+				// The certificates of every probe would only waste memory
+				m.TLSState = nil
+
+				mu.Lock()
+
+				if m.StatusCode != "" {
+					statusCodes[m.StatusCode]++
+				}
+
+				if err != nil {
+					failed++
+					errs[metrics.CategorizeError(err)]++
+				} else {
+					samples = append(samples, m)
+				}
+
+				mu.Unlock()
+			}
+		})
+	}
+
+	wg.Wait()
+
+	elapsed := time.Since(start)
+
+	r := Result{
+		URL:         opts.URL,
+		Tag:         opts.Tag,
+		Probes:      len(samples) + failed,
+		Succeeded:   len(samples),
+		Failed:      failed,
+		Workers:     workers,
+		TargetRate:  opts.Rate,
+		Duration:    elapsed.Seconds(),
+		StatusCodes: statusCodes,
+		Errors:      errs,
+		Phases:      newPhases(samples),
+	}
+
+	if elapsed > 0 {
+		r.Throughput = float64(r.Probes) / elapsed.Seconds()
+	}
+
+	return r
+}
+
+// dispatch sends n jobs, at most rate per second if rate is positive, and
+// closes jobs once done or when ctx is done.
+func dispatch(ctx context.Context, jobs chan<- struct{}, n int, rate float64) {
+	defer close(jobs)
+
+	var tick <-chan time.Time
+
+	if rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+		defer ticker.Stop()
+
+		tick = ticker.C
+	}
+
+	for i := range n {
+		if tick != nil && i > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick:
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case jobs <- struct{}{}:
+		}
+	}
+}
+
+// newPhases summarizes every phase of samples, or returns nil without
+// samples.
+func newPhases(samples []probers.Measurement) map[string]Stats {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	durations := map[string][]time.Duration{}
+
+	for _, m := range samples {
+		durations[PhaseDNS] = append(durations[PhaseDNS], m.DNS)
+		durations[PhaseConnect] = append(durations[PhaseConnect], m.Connect)
+		durations[PhaseTLS] = append(durations[PhaseTLS], m.TLS)
+		durations[PhaseServerProcessing] = append(durations[PhaseServerProcessing], m.ServerProcessing)
+		durations[PhaseTransfer] = append(durations[PhaseTransfer], m.Transfer)
+		durations[PhaseFirstByte] = append(durations[PhaseFirstByte], m.FirstByte)
+		durations[PhaseTotal] = append(durations[PhaseTotal], m.Total)
+	}
+
+	phases := make(map[string]Stats, len(durations))
+	for name, d := range durations {
+		phases[name] = newStats(d)
+	}
+
+	return phases
+}
+
+// newStats summarizes the non-empty durations d, which it sorts.
+func newStats(d []time.Duration) Stats {
+	slices.Sort(d)
+
+	var sum time.Duration
+	for _, v := range d {
+		sum += v
+	}
+
+	return Stats{
+		Min:  d[0].Seconds(),
+		Mean: (sum / time.Duration(len(d))).Seconds(),
+		P50:  percentile(d, 50).Seconds(),
+		P90:  percentile(d, 90).Seconds(),
+		P99:  percentile(d, 99).Seconds(),
+		Max:  d[len(d)-1].Seconds(),
+	}
+}
+
+// percentile returns the nearest-rank p-th percentile of the sorted
+// durations d.
+func percentile(d []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(d))))
+
+	return d[max(rank, 1)-1]
+}
+
+// Write writes the result in the given report format.
+func (r Result) Write(w io.Writer, format string) error {
+	switch format {
+	case report.FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		if err := enc.Encode(r); err != nil {
+			return fmt.Errorf("encoding result failed: %w", err)
+		}
+
+		return nil
+	case report.FormatText:
+		return r.writeText(w)
+	default:
+		return fmt.Errorf("unknown report format: %s", format)
+	}
+}
+
+// writeText writes the run, a table of the phases and the failures. The
+// TLS phase is left out for plain http URLs.
+func (r Result) writeText(w io.Writer) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s: %d probes, %d workers", r.URL, r.Probes, r.Workers)
+
+	if r.TargetRate > 0 {
+		fmt.Fprintf(&b, ", %s/s target rate", strconv.FormatFloat(r.TargetRate, 'f', -1, 64))
+	}
+
+	fmt.Fprintf(&b, ", %.2fs, %.1f probes/s\n\n", r.Duration, r.Throughput)
+
+	if len(r.Phases) > 0 {
+		tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+
+		fmt.Fprintln(tw, "PHASE\tMIN\tMEAN\tP50\tP90\tP99\tMAX")
+
+		for _, name := range Phases {
+			s, ok := r.Phases[name]
+			if !ok || (name == PhaseTLS && strings.HasPrefix(r.URL, "http://")) {
+				continue
+			}
+
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", name,
+				report.FormatSeconds(s.Min), report.FormatSeconds(s.Mean), report.FormatSeconds(s.P50),
+				report.FormatSeconds(s.P90), report.FormatSeconds(s.P99), report.FormatSeconds(s.Max))
+		}
+
+		if err := tw.Flush(); err != nil {
+			return fmt.Errorf("writing result failed: %w", err)
+		}
+
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "%d/%d probes succeeded\n", r.Succeeded, r.Probes)
+
+	if len(r.StatusCodes) > 0 {
+		b.WriteString("  status codes:")
+
+		for _, code := range slices.Sorted(maps.Keys(r.StatusCodes)) {
+			fmt.Fprintf(&b, " %s=%d", code, r.StatusCodes[code])
+		}
+
+		b.WriteString("\n")
+	}
+
+	for _, category := range slices.Sorted(maps.Keys(r.Errors)) {
+		fmt.Fprintf(&b, "  %s: %d\n", category, r.Errors[category])
+	}
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("writing result failed: %w", err)
+	}
+
+	return nil
+}
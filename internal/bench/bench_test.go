@@ -0,0 +1,209 @@
+package bench_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/bench"
+	"github.com/dntosas/astrolavos/internal/probers"
+	"github.com/dntosas/astrolavos/internal/report"
+)
+
+// sequence returns a Measure whose i-th probe takes i milliseconds, and
+// fails with err every failEvery probes if failEvery is positive. It
+// records the most probes it saw in flight at once in peak.
+func sequence(failEvery int, err error, peak *atomic.Int64) func(context.Context) (probers.Measurement, error) {
+	var n, inFlight atomic.Int64
+
+	return func(context.Context) (probers.Measurement, error) {
+		cur := inFlight.Add(1)
+		defer inFlight.Add(-1)
+
+		for {
+			p := peak.Load()
+			if cur <= p || peak.CompareAndSwap(p, cur) {
+				break
+			}
+		}
+
+		time.Sleep(time.Millisecond)
+
+		i := n.Add(1)
+		if failEvery > 0 && i%int64(failEvery) == 0 {
+			return probers.Measurement{StatusCode: "503"}, err
+		}
+
+		d := time.Duration(i) * time.Millisecond
+
+		return probers.Measurement{StatusCode: "200", DNS: d / 4, FirstByte: d / 2, Total: d}, nil
+	}
+}
+
+func TestRun(t *testing.T) {
+	var peak, seen atomic.Int64
+
+	res := bench.Run(context.Background(), bench.Options{
+		URL:     "https://example.com",
+		Probes:  100,
+		Workers: 4,
+		Measure: sequence(0, nil, &peak),
+		OnProbe: func(probers.Measurement, error) { seen.Add(1) },
+	})
+
+	if res.Probes != 100 || res.Succeeded != 100 || res.Failed != 0 || res.Workers != 4 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+
+	if seen.Load() != 100 {
+		t.Errorf("expected OnProbe for every probe, got %d calls", seen.Load())
+	}
+
+	if p := peak.Load(); p > 4 {
+		t.Errorf("expected at most 4 probes in flight, got %d", p)
+	}
+
+	if res.StatusCodes["200"] != 100 || len(res.Errors) != 0 || res.Throughput <= 0 {
+		t.Errorf("unexpected counts %+v", res)
+	}
+
+	// Probes take 1ms to 100ms, whatever the order the workers ran them in
+	want := bench.Stats{Min: 0.001, Mean: 0.0505, P50: 0.05, P90: 0.09, P99: 0.099, Max: 0.1}
+	if got := res.Phases[bench.PhaseTotal]; got != want {
+		t.Errorf("expected total %+v, got %+v", want, got)
+	}
+
+	if got := res.Phases[bench.PhaseFirstByte]; got.Max != 0.05 {
+		t.Errorf("expected a slowest first byte of 50ms, got %+v", got)
+	}
+
+	for _, phase := range bench.Phases {
+		if _, ok := res.Phases[phase]; !ok {
+			t.Errorf("expected a summary of %s", phase)
+		}
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	var peak atomic.Int64
+
+	res := bench.Run(context.Background(), bench.Options{
+		Probes:  10,
+		Workers: 20,
+		Measure: sequence(5, context.DeadlineExceeded, &peak),
+	})
+
+	if res.Probes != 10 || res.Succeeded != 8 || res.Failed != 2 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+
+	if res.Workers != 10 {
+		t.Errorf("expected the workers to be capped by the probes, got %d", res.Workers)
+	}
+
+	if res.Errors["timeout"] != 2 || res.StatusCodes["503"] != 2 || res.StatusCodes["200"] != 8 {
+		t.Errorf("unexpected failures %+v %+v", res.Errors, res.StatusCodes)
+	}
+}
+
+func TestRun_Rate(t *testing.T) {
+	var peak atomic.Int64
+
+	res := bench.Run(context.Background(), bench.Options{
+		Probes:  5,
+		Workers: 5,
+		Rate:    50,
+		Measure: sequence(0, nil, &peak),
+	})
+
+	// Five probes started 20ms apart take at least 80ms
+	if res.Probes != 5 || res.Duration < 0.08 {
+		t.Errorf("expected the rate to pace the probes, got %+v", res)
+	}
+}
+
+func TestRun_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var n atomic.Int64
+
+	res := bench.Run(ctx, bench.Options{
+		Probes:  1000,
+		Workers: 2,
+		Measure: func(ctx context.Context) (probers.Measurement, error) {
+			if n.Add(1) == 10 {
+				cancel()
+			}
+
+			return probers.Measurement{Total: time.Millisecond}, ctx.Err()
+		},
+	})
+
+	if res.Probes >= 1000 || res.Failed != 0 {
+		t.Errorf("expected the run to stop without counting canceled probes, got %+v", res)
+	}
+}
+
+func TestResult_Write(t *testing.T) {
+	res := bench.Result{
+		URL:         "http://example.com",
+		Probes:      3,
+		Succeeded:   2,
+		Failed:      1,
+		Workers:     2,
+		TargetRate:  10,
+		Duration:    0.3,
+		Throughput:  10,
+		StatusCodes: map[string]int{"200": 2},
+		Errors:      map[string]int{"connection_refused": 1},
+		Phases: map[string]bench.Stats{
+			bench.PhaseTLS:   {},
+			bench.PhaseTotal: {Min: 0.01, Mean: 0.015, P50: 0.01, P90: 0.02, P99: 0.02, Max: 0.02},
+		},
+	}
+
+	var out bytes.Buffer
+	if err := res.Write(&out, report.FormatText); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []string{
+		"http://example.com: 3 probes, 2 workers, 10/s target rate, 0.30s, 10.0 probes/s",
+		"PHASE  MIN     MEAN    P50     P90     P99     MAX",
+		"total  10.0ms  15.0ms  10.0ms  20.0ms  20.0ms  20.0ms",
+		"2/3 probes succeeded",
+		"status codes: 200=2",
+		"connection_refused: 1",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in output:\n%s", want, out.String())
+		}
+	}
+
+	if strings.Contains(out.String(), "tls") {
+		t.Errorf("expected no TLS phase for plain http:\n%s", out.String())
+	}
+
+	out.Reset()
+
+	if err := res.Write(&out, report.FormatJSON); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var decoded bench.Result
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("failed to decode output: %v", err)
+	}
+
+	if decoded.Phases[bench.PhaseTotal] != res.Phases[bench.PhaseTotal] || decoded.Errors["connection_refused"] != 1 {
+		t.Errorf("unexpected decoded result %+v", decoded)
+	}
+
+	if err := res.Write(&out, "yaml"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,240 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"strings"
+
+	"github.com/dntosas/astrolavos/internal/bench"
+	"github.com/dntosas/astrolavos/internal/config"
+	"github.com/dntosas/astrolavos/internal/metrics"
+	"github.com/dntosas/astrolavos/internal/model"
+	"github.com/dntosas/astrolavos/internal/probers"
+	"github.com/dntosas/astrolavos/internal/report"
+)
+
+// benchGrouping is the grouping label of pushed benchmarks, which keeps
+// them from replacing the metrics pushed by the prober itself.
+const benchGrouping = "mode"
+
+// benchOptions holds the parsed flags of the bench subcommand.
+type benchOptions struct {
+	configPath string
+	endpoint   string
+	bench      bench.Options
+	format     string
+	push       bool
+}
+
+// Bench runs the bench subcommand, which fires a number of probes at a
+// configured httpTrace endpoint from concurrent workers and summarizes
+// their latency, and returns its exit code: ExitFailed if no probe
+// succeeded or the push failed and ExitUsage for invalid arguments.
+func Bench(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	opts, err := parseBenchFlags(args, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitOK
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "astrolavos bench: %v\n", err)
+
+		return ExitUsage
+	}
+
+	cfg, err := config.NewConfig(opts.configPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "astrolavos bench: %v\n", err)
+
+		return ExitFailed
+	}
+
+	e, err := benchEndpoint(cfg.Endpoints, opts.endpoint)
+	if err != nil {
+		fmt.Fprintf(stderr, "astrolavos bench: %v\n", err)
+
+		return ExitUsage
+	}
+
+	if opts.push && cfg.PushGateway == nil {
+		fmt.Fprintln(stderr, "astrolavos bench: -push requires a push gateway, set ASTROLAVOS_PROM_PUSH_GW")
+
+		return ExitUsage
+	}
+
+	promC := metrics.NewPrometheusClient(metrics.Options{
+		IsOneOff:       true,
+		PushGateway:    benchPushGateway(cfg.PushGateway),
+		LabelNames:     cfg.LabelNames,
+		ExternalLabels: cfg.ExternalLabels,
+	})
+	target := metrics.Target{Domain: e.URI, ProberType: "httptrace", Tag: e.Tag, Labels: e.Labels}
+
+	prober := probers.NewHTTPTrace(probers.NewProberConfig(probers.ProberOptions{
+		Endpoint:            e.URI,
+		Tag:                 e.Tag,
+		Labels:              e.Labels,
+		ReuseConnection:     e.ReuseConnection,
+		SkipTLSVerification: e.SkipTLSVerification,
+		Method:              e.Method,
+		Headers:             e.Headers,
+		Body:                e.Body,
+		Expect:              e.Expect,
+		Timeout:             e.Timeout,
+		PhaseTimeouts:       e.PhaseTimeouts,
+	}))
+
+	opts.bench.URL = e.URI
+	opts.bench.Tag = e.Tag
+	opts.bench.Measure = prober.Measure
+	opts.bench.OnProbe = func(m probers.Measurement, err error) {
+		promC.UpdateRequestsCounter(target, m.StatusCode)
+
+		if err != nil {
+			promC.UpdateErrorsCounter(target, err)
+		}
+	}
+
+	res := bench.Run(ctx, opts.bench)
+
+	if err = res.Write(stdout, opts.format); err != nil {
+		fmt.Fprintf(stderr, "astrolavos bench: %v\n", err)
+
+		return ExitFailed
+	}
+
+	code := ExitOK
+	if res.Succeeded == 0 {
+		code = ExitFailed
+	}
+
+	if opts.push {
+		promC.UpdateBenchThroughput(target, res.Throughput)
+
+		for phase, s := range res.Phases {
+			for statistic, v := range map[string]float64{
+				"min": s.Min, "mean": s.Mean, "p50": s.P50, "p90": s.P90, "p99": s.P99, "max": s.Max,
+			} {
+				promC.UpdateBenchLatency(target, phase, statistic, v)
+			}
+		}
+
+		// The run may have been interrupted, the push still deserves a chance
+		if err = promC.PrometheusPush(context.WithoutCancel(ctx)); err != nil {
+			fmt.Fprintf(stderr, "astrolavos bench: %v\n", err)
+
+			code = ExitFailed
+		}
+	}
+
+	return code
+}
+
+// parseBenchFlags parses the arguments of the bench subcommand.
+func parseBenchFlags(args []string, stderr io.Writer) (benchOptions, error) {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	configPath := fs.String("config-path", "/etc/astrolavos", "Specify the path of the config file.")
+	endpoint := fs.String("endpoint", "", "URL, domain or tag of the httpTrace endpoint to benchmark. Optional if the config has a single httpTrace endpoint.")
+	probes := fs.Int("n", 100, "Number of probes to run.")
+	workers := fs.Int("c", 10, "Number of concurrent workers.")
+	rate := fs.Float64("rate", 0, "Probes started per second across all workers. Zero runs them as fast as the workers allow.")
+	jsonOutput := fs.Bool("json", false, "Print the results as JSON.")
+	push := fs.Bool("push", false, "Push the results to the Prometheus push gateway.")
+
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: astrolavos bench [flags]")
+		fmt.Fprintln(stderr, "\nFires probes at a configured httpTrace endpoint from concurrent workers and prints the latency percentiles of every phase.")
+		fmt.Fprintln(stderr, "\nFlags:")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return benchOptions{}, err
+	}
+
+	if fs.NArg() > 0 {
+		fs.Usage()
+
+		return benchOptions{}, fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+
+	switch {
+	case *probes < 1:
+		return benchOptions{}, fmt.Errorf("-n must be at least 1, got %d", *probes)
+	case *workers < 1:
+		return benchOptions{}, fmt.Errorf("-c must be at least 1, got %d", *workers)
+	case *rate < 0:
+		return benchOptions{}, fmt.Errorf("-rate cannot be negative, got %v", *rate)
+	}
+
+	opts := benchOptions{
+		configPath: *configPath,
+		endpoint:   *endpoint,
+		bench:      bench.Options{Probes: *probes, Workers: *workers, Rate: *rate},
+		format:     report.FormatText,
+		push:       *push,
+	}
+
+	if *jsonOutput {
+		opts.format = report.FormatJSON
+	}
+
+	return opts, nil
+}
+
+// benchEndpoint returns the httpTrace endpoint matching name by URL, by
+// URL without its scheme or by tag, or the only httpTrace endpoint if name
+// is empty.
+func benchEndpoint(endpoints []*model.Endpoint, name string) (*model.Endpoint, error) {
+	var matches []*model.Endpoint
+
+	for _, e := range endpoints {
+		if e.ProberType != proberHTTPTrace {
+			continue
+		}
+
+		_, domain, _ := strings.Cut(e.URI, "://")
+
+		if name == "" || name == e.URI || name == domain || name == e.Tag {
+			matches = append(matches, e)
+		}
+	}
+
+	switch {
+	case len(matches) == 1:
+		return matches[0], nil
+	case len(matches) == 0 && name == "":
+		return nil, errors.New("no httpTrace endpoint configured")
+	case len(matches) == 0:
+		return nil, fmt.Errorf("no httpTrace endpoint matches %q", name)
+	case name == "":
+		return nil, fmt.Errorf("%d httpTrace endpoints configured, pick one with -endpoint", len(matches))
+	default:
+		return nil, fmt.Errorf("%d httpTrace endpoints match %q, pick one by its URL", len(matches), name)
+	}
+}
+
+// benchPushGateway returns a copy of gateway that pushes to its own group,
+// or nil without a gateway.
+func benchPushGateway(gateway *model.PushGateway) *model.PushGateway {
+	if gateway == nil {
+		return nil
+	}
+
+	g := *gateway
+	g.Grouping = maps.Clone(gateway.Grouping)
+
+	if g.Grouping == nil {
+		g.Grouping = map[string]string{}
+	}
+
+	g.Grouping[benchGrouping] = "bench"
+
+	return &g
+}
@@ -0,0 +1,121 @@
+package cli_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dntosas/astrolavos/internal/cli"
+)
+
+func runBench(t *testing.T, config string, args ...string) (int, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(config), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	args = append([]string{"-config-path", dir}, args...)
+	code := cli.Bench(context.Background(), args, &stdout, &stderr)
+	t.Logf("astrolavos bench %s:\n%s%s", strings.Join(args, " "), stdout.String(), stderr.String())
+
+	return code, stdout.String()
+}
+
+func TestBench(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {}))
+	defer srv.Close()
+
+	var (
+		mu     sync.Mutex
+		pushed []string
+	)
+
+	gateway := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		pushed = append(pushed, r.URL.Path, string(body))
+	}))
+	defer gateway.Close()
+
+	t.Setenv("ASTROLAVOS_PROM_PUSH_GW", gateway.URL)
+	t.Setenv("ASTROLAVOS_PUSH_GW_GROUPING", "instance=ci")
+
+	domain := strings.TrimPrefix(srv.URL, "http://")
+	config := "endpoints:\n  - domain: " + domain + "\n    tag: web\n  - domain: " + domain + "\n    prober: tcp\n"
+
+	code, out := runBench(t, config, "-n", "20", "-c", "4", "-json", "-push")
+	if code != cli.ExitOK {
+		t.Fatalf("expected exit code %d, got %d", cli.ExitOK, code)
+	}
+
+	var res struct {
+		URL       string                        `json:"url"`
+		Probes    int                           `json:"probes"`
+		Succeeded int                           `json:"succeeded"`
+		Workers   int                           `json:"workers"`
+		Phases    map[string]map[string]float64 `json:"phases"`
+	}
+
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatalf("failed to decode output: %v", err)
+	}
+
+	if res.URL != srv.URL || res.Probes != 20 || res.Succeeded != 20 || res.Workers != 4 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+
+	if total := res.Phases["total"]; total["p99_seconds"] <= 0 || total["p50_seconds"] > total["p99_seconds"] {
+		t.Errorf("unexpected total summary %v", total)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// The grouping labels of the path come in any order
+	if len(pushed) != 2 || !strings.Contains(pushed[0], "/instance/ci") || !strings.Contains(pushed[0], "/mode/bench") {
+		t.Fatalf("expected a single push to the bench group, got %v", pushed)
+	}
+
+	for _, want := range []string{"astrolavos_bench_latency_seconds", "astrolavos_bench_throughput", "astrolavos_requests_total"} {
+		if !strings.Contains(pushed[1], want) {
+			t.Errorf("expected %s to be pushed", want)
+		}
+	}
+}
+
+func TestBench_Usage(t *testing.T) {
+	t.Setenv("ASTROLAVOS_PROM_PUSH_GW", "")
+
+	two := "endpoints:\n  - domain: a.example.com\n  - domain: b.example.com\n"
+
+	for _, tt := range []struct {
+		config string
+		args   []string
+	}{
+		{config: two},
+		{config: two, args: []string{"-endpoint", "c.example.com"}},
+		{config: two, args: []string{"-endpoint", "a.example.com", "-n", "0"}},
+		{config: two, args: []string{"-endpoint", "a.example.com", "-push"}},
+		{config: two, args: []string{"-endpoint", "a.example.com", "extra"}},
+		{config: "endpoints:\n  - domain: a.example.com:80\n    prober: tcp\n"},
+	} {
+		if code, _ := runBench(t, tt.config, tt.args...); code != cli.ExitUsage {
+			t.Errorf("%v: expected exit code %d, got %d", tt.args, cli.ExitUsage, code)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+// Package cli implements the astrolavos subcommands, which run a single
+// task from the command line instead of the long-running prober.
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/probers"
+)
+
+// Exit codes of the subcommands.
+const (
+	ExitOK     = 0
+	ExitFailed = 1
+	ExitUsage  = 2
+)
+
+// Prober types the probe subcommand runs.
+const (
+	proberHTTPTrace = "httpTrace"
+	proberTCP       = "tcp"
+)
+
+// headerFlags collects repeated "Name: value" header flags.
+type headerFlags map[string]string
+
+func (h headerFlags) String() string {
+	return ""
+}
+
+func (h headerFlags) Set(v string) error {
+	name, value, ok := strings.Cut(v, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("expected 'Name: value', got %q", v)
+	}
+
+	h[strings.TrimSpace(name)] = strings.TrimSpace(value)
+
+	return nil
+}
+
+// probeOptions holds the parsed flags of the probe subcommand.
+type probeOptions struct {
+	url        string
+	endpoint   string
+	proberType string
+	count      int
+	interval   time.Duration
+	json       bool
+	color      bool
+	prober     probers.ProberOptions
+}
+
+// Probe runs the probe subcommand, which measures a URL without a config
+// file, and returns its exit code: ExitFailed if any probe failed and
+// ExitUsage for invalid arguments.
+func Probe(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	opts, err := parseProbeFlags(args, stdout, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitOK
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "astrolavos probe: %v\n", err)
+
+		return ExitUsage
+	}
+
+	measure := newMeasure(opts)
+	results := make([]probeResult, 0, opts.count)
+	p := painter(opts.color)
+
+	for i := range opts.count {
+		if i > 0 && !sleep(ctx, opts.interval) {
+			break
+		}
+
+		m, err := measure(ctx)
+		res := probeResult{m: m, err: err}
+		results = append(results, res)
+
+		if !opts.json && opts.count > 1 {
+			fmt.Fprintf(stdout, "probe %d: %s\n", i+1, res.line(p))
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	s := newProbeSummary(opts, results)
+
+	if opts.json {
+		err = s.writeJSON(stdout)
+	} else {
+		err = s.writeText(stdout, p)
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "astrolavos probe: %v\n", err)
+
+		return ExitFailed
+	}
+
+	if len(results) == 0 || s.Failed > 0 {
+		return ExitFailed
+	}
+
+	return ExitOK
+}
+
+// parseProbeFlags parses the arguments of the probe subcommand. Flags may
+// come before or after the URL.
+func parseProbeFlags(args []string, stdout, stderr io.Writer) (probeOptions, error) {
+	fs := flag.NewFlagSet("probe", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	count := fs.Int("n", 1, "Number of probes to run.")
+	interval := fs.Duration("interval", 0, "Time to wait between probes.")
+	jsonOutput := fs.Bool("json", false, "Print the results as JSON.")
+	proberType := fs.String("type", "", "Prober to run: httpTrace or tcp. Defaults to httpTrace for http(s) URLs and tcp for host:port.")
+	method := fs.String("X", http.MethodGet, "Method of the HTTP request.")
+	insecure := fs.Bool("k", false, "Skip TLS certificate verification.")
+	timeout := fs.Duration("timeout", 10*time.Second, "Timeout of each probe.")
+	noColor := fs.Bool("no-color", false, "Disable colored output.")
+
+	headers := headerFlags{}
+	fs.Var(headers, "H", "Header of the HTTP request as 'Name: value'. Can be repeated.")
+
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: astrolavos probe [flags] <url>")
+		fmt.Fprintln(stderr, "\nProbes an http(s):// URL or a host:port once and prints its latency breakdown.")
+		fmt.Fprintln(stderr, "\nFlags:")
+		fs.PrintDefaults()
+	}
+
+	var positional []string
+
+	for {
+		if err := fs.Parse(args); err != nil {
+			return probeOptions{}, err
+		}
+
+		if fs.NArg() == 0 {
+			break
+		}
+
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	if len(positional) != 1 {
+		fs.Usage()
+
+		return probeOptions{}, fmt.Errorf("expected a single URL, got %d arguments", len(positional))
+	}
+
+	if *count < 1 {
+		return probeOptions{}, fmt.Errorf("invalid -n %d: must be at least 1", *count)
+	}
+
+	endpoint, resolvedType, err := probeTarget(positional[0], *proberType)
+	if err != nil {
+		return probeOptions{}, err
+	}
+
+	return probeOptions{
+		url:        positional[0],
+		endpoint:   endpoint,
+		proberType: resolvedType,
+		count:      *count,
+		interval:   *interval,
+		json:       *jsonOutput,
+		color:      !*noColor && os.Getenv("NO_COLOR") == "" && isTerminal(stdout),
+		prober: probers.ProberOptions{
+			Endpoint:            endpoint,
+			Method:              *method,
+			Headers:             headers,
+			SkipTLSVerification: *insecure,
+			Timeout:             *timeout,
+			TCPTimeout:          *timeout,
+		},
+	}, nil
+}
+
+// probeTarget returns the endpoint and prober type of a URL. Without an
+// explicit type, http(s) URLs are traced and host:port pairs or tcp:// URLs
+// are dialed. A tcp probe of an http(s) URL dials its host and port.
+func probeTarget(raw, proberType string) (string, string, error) {
+	if proberType != "" && proberType != proberHTTPTrace && proberType != proberTCP {
+		return "", "", fmt.Errorf("invalid -type %q: must be %s or %s", proberType, proberHTTPTrace, proberTCP)
+	}
+
+	if !strings.Contains(raw, "://") {
+		if _, _, err := net.SplitHostPort(raw); err != nil {
+			return "", "", fmt.Errorf("invalid target %q: expected an http(s):// URL or host:port", raw)
+		}
+
+		if proberType == proberHTTPTrace {
+			return "", "", fmt.Errorf("invalid target %q: %s probes need an http(s):// URL", raw, proberHTTPTrace)
+		}
+
+		return raw, proberTCP, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid URL %q: %w", raw, err)
+	}
+
+	if u.Host == "" {
+		return "", "", fmt.Errorf("invalid URL %q: missing host", raw)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		if proberType != proberTCP {
+			return raw, proberHTTPTrace, nil
+		}
+
+		port := u.Port()
+		if port == "" {
+			port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+		}
+
+		return net.JoinHostPort(u.Hostname(), port), proberTCP, nil
+	case "tcp":
+		if proberType == proberHTTPTrace {
+			return "", "", fmt.Errorf("invalid URL %q: %s probes need an http(s):// URL", raw, proberHTTPTrace)
+		}
+
+		if u.Port() == "" {
+			return "", "", fmt.Errorf("invalid URL %q: missing port", raw)
+		}
+
+		return u.Host, proberTCP, nil
+	default:
+		return "", "", fmt.Errorf("invalid URL %q: unsupported scheme %q", raw, u.Scheme)
+	}
+}
+
+// newMeasure returns the measurement of a single probe of the target.
+func newMeasure(opts probeOptions) func(context.Context) (probers.Measurement, error) {
+	cfg := probers.NewProberConfig(opts.prober)
+
+	if opts.proberType == proberTCP {
+		return probers.NewTCP(cfg).Measure
+	}
+
+	return probers.NewHTTPTrace(cfg).Measure
+}
+
+// sleep waits for d and reports whether ctx is still active.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// isTerminal reports whether w is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
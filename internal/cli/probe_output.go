@@ -0,0 +1,432 @@
+package cli
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/metrics"
+	"github.com/dntosas/astrolavos/internal/probers"
+	"github.com/dntosas/astrolavos/internal/report"
+)
+
+// ANSI colors of the text output.
+const (
+	colorReset = "\x1b[0m"
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorCyan  = "\x1b[36m"
+)
+
+// painter colors text when enabled.
+type painter bool
+
+func (p painter) paint(color, s string) string {
+	if !p {
+		return s
+	}
+
+	return color + s + colorReset
+}
+
+// phase is a step of a probe shown in the waterfall and the summary.
+type phase struct {
+	name  string
+	key   string
+	value func(probers.Measurement) time.Duration
+}
+
+var (
+	phaseDNS       = phase{"DNS Lookup", "dns", func(m probers.Measurement) time.Duration { return m.DNS }}
+	phaseConnect   = phase{"TCP Connection", "connect", func(m probers.Measurement) time.Duration { return m.Connect }}
+	phaseTLS       = phase{"TLS Handshake", "tls", func(m probers.Measurement) time.Duration { return m.TLS }}
+	phaseServer    = phase{"Server Processing", "server_processing", func(m probers.Measurement) time.Duration { return m.ServerProcessing }}
+	phaseTransfer  = phase{"Content Transfer", "transfer", func(m probers.Measurement) time.Duration { return m.Transfer }}
+	phaseTotal     = phase{"Total", "total", func(m probers.Measurement) time.Duration { return m.Total }}
+	phasesTCP      = []phase{phaseDNS, phaseConnect, phaseTotal}
+	phasesHTTP     = []phase{phaseDNS, phaseConnect, phaseServer, phaseTransfer, phaseTotal}
+	phasesHTTPS    = []phase{phaseDNS, phaseConnect, phaseTLS, phaseServer, phaseTransfer, phaseTotal}
+	waterfallHTTPS = `  DNS Lookup   TCP Connection   TLS Handshake   Server Processing   Content Transfer
+[   %s  |     %s    |    %s    |      %s      |      %s     ]
+             |                |               |                   |                  |
+    namelookup:%s        |               |                   |                  |
+                        connect:%s       |                   |                  |
+                                    pretransfer:%s           |                  |
+                                                      starttransfer:%s          |
+                                                                                 total:%s
+`
+	waterfallHTTP = `  DNS Lookup   TCP Connection   Server Processing   Content Transfer
+[   %s  |     %s    |      %s      |      %s     ]
+             |                |                   |                  |
+    namelookup:%s        |                   |                  |
+                        connect:%s           |                  |
+                                      starttransfer:%s          |
+                                                                 total:%s
+`
+	waterfallTCP = `  DNS Lookup   TCP Connection
+[   %s  |     %s    ]
+             |                |
+    namelookup:%s        |
+                        connect:%s
+`
+)
+
+// probeResult is the outcome of a single probe.
+type probeResult struct {
+	m   probers.Measurement
+	err error
+}
+
+// line returns a one-line description of the probe.
+func (r probeResult) line(p painter) string {
+	if r.err != nil {
+		return p.paint(colorRed, fmt.Sprintf("FAIL %s: %v", metrics.CategorizeError(r.err), r.err))
+	}
+
+	if r.m.StatusCode == "" {
+		return fmt.Sprintf("connected to %s in %s", r.m.RemoteAddr, report.FormatSeconds(r.m.Total.Seconds()))
+	}
+
+	return fmt.Sprintf("%s from %s in %s", r.m.StatusCode, r.m.RemoteAddr, report.FormatSeconds(r.m.Total.Seconds()))
+}
+
+// certificateJSON describes the leaf certificate of a TLS connection.
+type certificateJSON struct {
+	Subject  string    `json:"subject"`
+	Issuer   string    `json:"issuer"`
+	DNSNames []string  `json:"dns_names,omitempty"`
+	NotAfter time.Time `json:"not_after"`
+}
+
+// tlsJSON describes a negotiated TLS connection.
+type tlsJSON struct {
+	Version     string           `json:"version"`
+	CipherSuite string           `json:"cipher_suite"`
+	ALPN        string           `json:"alpn,omitempty"`
+	ServerName  string           `json:"server_name,omitempty"`
+	Certificate *certificateJSON `json:"certificate,omitempty"`
+}
+
+func newTLSJSON(state *tls.ConnectionState) *tlsJSON {
+	if state == nil {
+		return nil
+	}
+
+	t := &tlsJSON{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		ALPN:        state.NegotiatedProtocol,
+		ServerName:  state.ServerName,
+	}
+
+	if len(state.PeerCertificates) > 0 {
+		cert := state.PeerCertificates[0]
+		t.Certificate = &certificateJSON{
+			Subject:  certName(cert.Subject.CommonName, cert.Subject.String()),
+			Issuer:   certName(cert.Issuer.CommonName, cert.Issuer.String()),
+			DNSNames: cert.DNSNames,
+			NotAfter: cert.NotAfter,
+		}
+	}
+
+	return t
+}
+
+// certName returns the common name of a certificate subject, falling back
+// to its full distinguished name.
+func certName(commonName, dn string) string {
+	if commonName != "" {
+		return commonName
+	}
+
+	return dn
+}
+
+// probeJSON is a single probe of the JSON output. Phase latencies are in
+// seconds.
+type probeJSON struct {
+	Success                 bool     `json:"success"`
+	Error                   string   `json:"error,omitempty"`
+	ErrorCategory           string   `json:"error_category,omitempty"`
+	StatusCode              string   `json:"status_code,omitempty"`
+	RemoteAddr              string   `json:"remote_addr,omitempty"`
+	TLS                     *tlsJSON `json:"tls,omitempty"`
+	DNSSeconds              float64  `json:"dns_seconds"`
+	ConnectSeconds          float64  `json:"connect_seconds"`
+	TLSSeconds              float64  `json:"tls_seconds"`
+	ServerProcessingSeconds float64  `json:"server_processing_seconds"`
+	TransferSeconds         float64  `json:"transfer_seconds"`
+	TotalSeconds            float64  `json:"total_seconds"`
+}
+
+// phaseStats summarizes a phase across the successful probes, in seconds.
+type phaseStats struct {
+	MinSeconds float64 `json:"min_seconds"`
+	AvgSeconds float64 `json:"avg_seconds"`
+	P95Seconds float64 `json:"p95_seconds"`
+}
+
+// probeSummary is the outcome of every probe of a run.
+type probeSummary struct {
+	URL        string                `json:"url"`
+	ProberType string                `json:"prober_type"`
+	Probes     []probeJSON           `json:"probes"`
+	Total      int                   `json:"total"`
+	Failed     int                   `json:"failed"`
+	Phases     map[string]phaseStats `json:"phases,omitempty"`
+
+	phases    []phase
+	waterfall string
+	results   []probeResult
+}
+
+func newProbeSummary(opts probeOptions, results []probeResult) probeSummary {
+	s := probeSummary{
+		URL:        opts.url,
+		ProberType: opts.proberType,
+		Probes:     make([]probeJSON, 0, len(results)),
+		Total:      len(results),
+		phases:     phasesTCP,
+		waterfall:  waterfallTCP,
+		results:    results,
+	}
+
+	if opts.proberType == proberHTTPTrace {
+		s.phases, s.waterfall = phasesHTTP, waterfallHTTP
+		if strings.HasPrefix(opts.endpoint, "https://") {
+			s.phases, s.waterfall = phasesHTTPS, waterfallHTTPS
+		}
+	}
+
+	var succeeded []probers.Measurement
+
+	for _, r := range results {
+		entry := probeJSON{
+			Success:                 r.err == nil,
+			StatusCode:              r.m.StatusCode,
+			RemoteAddr:              r.m.RemoteAddr,
+			TLS:                     newTLSJSON(r.m.TLSState),
+			DNSSeconds:              r.m.DNS.Seconds(),
+			ConnectSeconds:          r.m.Connect.Seconds(),
+			TLSSeconds:              r.m.TLS.Seconds(),
+			ServerProcessingSeconds: r.m.ServerProcessing.Seconds(),
+			TransferSeconds:         r.m.Transfer.Seconds(),
+			TotalSeconds:            r.m.Total.Seconds(),
+		}
+
+		if r.err != nil {
+			entry.Error = r.err.Error()
+			entry.ErrorCategory = metrics.CategorizeError(r.err)
+			s.Failed++
+		} else {
+			succeeded = append(succeeded, r.m)
+		}
+
+		s.Probes = append(s.Probes, entry)
+	}
+
+	if len(succeeded) > 0 {
+		s.Phases = make(map[string]phaseStats, len(s.phases))
+		for _, ph := range s.phases {
+			s.Phases[ph.key] = newPhaseStats(succeeded, ph)
+		}
+	}
+
+	return s
+}
+
+// newPhaseStats returns the minimum, average and nearest-rank 95th
+// percentile of a phase.
+func newPhaseStats(ms []probers.Measurement, ph phase) phaseStats {
+	values := make([]time.Duration, 0, len(ms))
+
+	var sum time.Duration
+
+	for _, m := range ms {
+		values = append(values, ph.value(m))
+		sum += ph.value(m)
+	}
+
+	slices.Sort(values)
+
+	rank := int(math.Ceil(0.95*float64(len(values)))) - 1
+
+	return phaseStats{
+		MinSeconds: values[0].Seconds(),
+		AvgSeconds: (sum / time.Duration(len(values))).Seconds(),
+		P95Seconds: values[rank].Seconds(),
+	}
+}
+
+func (s probeSummary) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(s); err != nil {
+		return fmt.Errorf("encoding results failed: %w", err)
+	}
+
+	return nil
+}
+
+// writeText writes the details and waterfall of a single probe, or the
+// details of the first successful probe and a summary of every phase when
+// probing repeatedly.
+func (s probeSummary) writeText(w io.Writer, p painter) error {
+	var b strings.Builder
+
+	if s.Total == 1 {
+		r := s.results[0]
+
+		s.writeDetails(&b, r, p)
+
+		if r.err != nil {
+			fmt.Fprintf(&b, "%s\n", r.line(p))
+		} else {
+			s.writeWaterfall(&b, r.m, p)
+		}
+
+		if _, err := io.WriteString(w, b.String()); err != nil {
+			return fmt.Errorf("writing results failed: %w", err)
+		}
+
+		return nil
+	}
+
+	if i := slices.IndexFunc(s.results, func(r probeResult) bool { return r.err == nil }); i >= 0 {
+		b.WriteString("\n")
+		s.writeDetails(&b, s.results[i], p)
+	}
+
+	if s.Phases != nil {
+		tw := tabwriter.NewWriter(&b, 0, 0, 3, ' ', 0)
+
+		fmt.Fprintln(tw, "\tmin\tavg\tp95")
+
+		for _, ph := range s.phases {
+			stats := s.Phases[ph.key]
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", ph.name,
+				report.FormatSeconds(stats.MinSeconds), report.FormatSeconds(stats.AvgSeconds), report.FormatSeconds(stats.P95Seconds))
+		}
+
+		if err := tw.Flush(); err != nil {
+			return fmt.Errorf("writing results failed: %w", err)
+		}
+	}
+
+	summary := fmt.Sprintf("%d/%d probes succeeded", s.Total-s.Failed, s.Total)
+	if s.Failed > 0 {
+		summary = p.paint(colorRed, summary)
+	}
+
+	fmt.Fprintf(&b, "\n%s\n", summary)
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("writing results failed: %w", err)
+	}
+
+	return nil
+}
+
+// writeDetails writes the peer, TLS connection and status of a probe.
+func (s probeSummary) writeDetails(b *strings.Builder, r probeResult, p painter) {
+	start := b.Len()
+
+	if r.m.RemoteAddr != "" {
+		fmt.Fprintf(b, "Connected to %s\n", p.paint(colorCyan, r.m.RemoteAddr))
+	}
+
+	if state := r.m.TLSState; state != nil {
+		fmt.Fprintf(b, "%s, %s", tls.VersionName(state.Version), tls.CipherSuiteName(state.CipherSuite))
+
+		if state.NegotiatedProtocol != "" {
+			fmt.Fprintf(b, ", ALPN %s", state.NegotiatedProtocol)
+		}
+
+		b.WriteString("\n")
+
+		if len(state.PeerCertificates) > 0 {
+			writeCertificate(b, state.PeerCertificates[0])
+		}
+	}
+
+	if r.m.StatusCode != "" {
+		color := colorGreen
+		if r.m.StatusCode >= "400" {
+			color = colorRed
+		}
+
+		fmt.Fprintf(b, "HTTP %s\n", p.paint(color, r.m.StatusCode))
+	}
+
+	if b.Len() > start {
+		b.WriteString("\n")
+	}
+}
+
+// maxDNSNames is the number of certificate names listed before the rest
+// are elided.
+const maxDNSNames = 3
+
+// writeCertificate writes the subject, names, issuer and expiry of a leaf
+// certificate.
+func writeCertificate(b *strings.Builder, cert *x509.Certificate) {
+	fmt.Fprintf(b, "Certificate %s", certName(cert.Subject.CommonName, cert.Subject.String()))
+
+	if names := cert.DNSNames; len(names) > 0 {
+		if len(names) > maxDNSNames {
+			fmt.Fprintf(b, " (%s and %d more)", strings.Join(names[:maxDNSNames], ", "), len(names)-maxDNSNames)
+		} else {
+			fmt.Fprintf(b, " (%s)", strings.Join(names, ", "))
+		}
+	}
+
+	days := int(time.Until(cert.NotAfter).Hours() / 24)
+	fmt.Fprintf(b, ", issued by %s, expires %s (%d days)\n",
+		certName(cert.Issuer.CommonName, cert.Issuer.String()), cert.NotAfter.Format(time.DateOnly), days)
+}
+
+// writeWaterfall writes the phases of a probe as boxes with the time
+// elapsed since the start of the request at the end of each beneath, like
+// httpstat.
+func (s probeSummary) writeWaterfall(b *strings.Builder, m probers.Measurement, p painter) {
+	box := func(d time.Duration) string {
+		v := milliseconds(d)
+		pad := 7 - len(v)
+		left := max(pad/2, 0)
+
+		return p.paint(colorCyan, strings.Repeat(" ", left)+v+strings.Repeat(" ", max(pad-left, 0)))
+	}
+
+	mark := func(d time.Duration) string {
+		return p.paint(colorCyan, fmt.Sprintf("%-7s", milliseconds(d)))
+	}
+
+	var boxes, marks []any
+
+	var elapsed time.Duration
+
+	for _, ph := range s.phases {
+		if ph.key == phaseTotal.key {
+			continue
+		}
+
+		elapsed += ph.value(m)
+		boxes = append(boxes, box(ph.value(m)))
+		marks = append(marks, mark(elapsed))
+	}
+
+	fmt.Fprintf(b, s.waterfall, append(boxes, marks...)...)
+}
+
+// milliseconds formats d in whole milliseconds.
+func milliseconds(d time.Duration) string {
+	return fmt.Sprintf("%dms", d.Round(time.Millisecond).Milliseconds())
+}
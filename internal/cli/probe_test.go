@@ -0,0 +1,153 @@
+package cli_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dntosas/astrolavos/internal/cli"
+)
+
+func runProbe(t *testing.T, args ...string) (int, string) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+
+	code := cli.Probe(context.Background(), args, &stdout, &stderr)
+	t.Logf("astrolavos probe %s:\n%s%s", strings.Join(args, " "), stdout.String(), stderr.String())
+
+	return code, stdout.String()
+}
+
+func TestProbe_HTTPS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	code, out := runProbe(t, "-k", srv.URL)
+	if code != cli.ExitOK {
+		t.Fatalf("expected exit code %d, got %d", cli.ExitOK, code)
+	}
+
+	for _, want := range []string{
+		"Connected to " + srv.Listener.Addr().String(),
+		"TLS 1.3, TLS_",
+		"Certificate O=Acme Co (example.com, *.example.com), issued by O=Acme Co",
+		"HTTP 202",
+		"DNS Lookup   TCP Connection   TLS Handshake   Server Processing   Content Transfer",
+		"pretransfer:",
+		"total:",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output", want)
+		}
+	}
+
+	if strings.Contains(out, "\x1b[") {
+		t.Error("expected no colors when not writing to a terminal")
+	}
+}
+
+func TestProbe_RepeatJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {}))
+	defer srv.Close()
+
+	code, out := runProbe(t, srv.URL, "-n", "3", "-json", "-H", "X-Probe: on-call")
+	if code != cli.ExitOK {
+		t.Fatalf("expected exit code %d, got %d", cli.ExitOK, code)
+	}
+
+	var res struct {
+		ProberType string `json:"prober_type"`
+		Probes     []struct {
+			Success      bool    `json:"success"`
+			StatusCode   string  `json:"status_code"`
+			TotalSeconds float64 `json:"total_seconds"`
+		} `json:"probes"`
+		Total  int                           `json:"total"`
+		Failed int                           `json:"failed"`
+		Phases map[string]map[string]float64 `json:"phases"`
+	}
+
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatalf("failed to decode output: %v", err)
+	}
+
+	if res.ProberType != "httpTrace" || res.Total != 3 || res.Failed != 0 || len(res.Probes) != 3 {
+		t.Fatalf("unexpected results %+v", res)
+	}
+
+	for _, p := range res.Probes {
+		if !p.Success || p.StatusCode != "200" || p.TotalSeconds <= 0 {
+			t.Errorf("unexpected probe %+v", p)
+		}
+	}
+
+	total, ok := res.Phases["total"]
+	if !ok {
+		t.Fatalf("expected a total summary, got %v", res.Phases)
+	}
+
+	// With 3 probes the 95th percentile is the slowest
+	if total["min_seconds"] > total["avg_seconds"] || total["avg_seconds"] > total["p95_seconds"] {
+		t.Errorf("unexpected total summary %v", total)
+	}
+
+	if _, ok := res.Phases["tls"]; ok {
+		t.Error("expected no TLS summary for plain http")
+	}
+}
+
+func TestProbe_TCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	code, out := runProbe(t, "-n", "2", ln.Addr().String())
+	if code != cli.ExitOK {
+		t.Fatalf("expected exit code %d, got %d", cli.ExitOK, code)
+	}
+
+	for _, want := range []string{"probe 2: connected to " + ln.Addr().String(), "TCP Connection", "p95", "2/2 probes succeeded"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output", want)
+		}
+	}
+
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	code, out = runProbe(t, "tcp://"+addr)
+	if code != cli.ExitFailed {
+		t.Fatalf("expected exit code %d, got %d", cli.ExitFailed, code)
+	}
+
+	if !strings.Contains(out, "FAIL connection_refused") {
+		t.Errorf("expected a connection refused failure, got %q", out)
+	}
+}
+
+func TestProbe_Usage(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"http://a.example", "http://b.example"},
+		{"-n", "0", "http://a.example"},
+		{"ftp://a.example"},
+		{"a.example"},
+		{"-type", "httpTrace", "a.example:80"},
+		{"-type", "dns", "http://a.example"},
+		{"-H", "no-colon", "http://a.example"},
+	} {
+		if code, _ := runProbe(t, args...); code != cli.ExitUsage {
+			t.Errorf("%v: expected exit code %d, got %d", args, cli.ExitUsage, code)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dntosas/astrolavos/internal/config"
+)
+
+// Validate runs the validate subcommand, which loads a configuration in
+// strict mode and lists every problem found, and returns its exit code:
+// ExitFailed if the configuration is invalid and ExitUsage for invalid
+// arguments.
+func Validate(_ context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	configPath := fs.String("config-path", "/etc/astrolavos", "Specify the path of the config file.")
+
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: astrolavos validate [flags]")
+		fmt.Fprintln(stderr, "\nValidates the config file and the ASTROLAVOS_* settings of the environment, rejecting unknown fields and invalid endpoints.")
+		fmt.Fprintln(stderr, "\nFlags:")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+
+		return ExitUsage
+	}
+
+	if fs.NArg() > 0 {
+		fs.Usage()
+		fmt.Fprintf(stderr, "astrolavos validate: unexpected arguments %v\n", fs.Args())
+
+		return ExitUsage
+	}
+
+	cfg, err := config.Validate(*configPath)
+	if err != nil {
+		errs := config.Errors(err)
+
+		var b strings.Builder
+
+		fmt.Fprintf(&b, "%s: %d %s found\n", *configPath, len(errs), plural(len(errs), "problem"))
+
+		for _, e := range errs {
+			fmt.Fprintf(&b, "  %v\n", e)
+		}
+
+		if _, err = io.WriteString(stdout, b.String()); err != nil {
+			fmt.Fprintf(stderr, "astrolavos validate: %v\n", err)
+		}
+
+		return ExitFailed
+	}
+
+	fmt.Fprintf(stdout, "%s: valid, %d %s\n", *configPath, len(cfg.Endpoints), plural(len(cfg.Endpoints), "endpoint"))
+
+	return ExitOK
+}
+
+// plural returns noun, suffixed with an s unless n is one.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return noun
+	}
+
+	return noun + "s"
+}
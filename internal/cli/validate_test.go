@@ -0,0 +1,63 @@
+package cli_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dntosas/astrolavos/internal/cli"
+)
+
+func runValidate(t *testing.T, config string) (int, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(config), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	code := cli.Validate(context.Background(), []string{"-config-path", dir}, &stdout, &stderr)
+
+	return code, strings.ReplaceAll(stdout.String(), dir, "<dir>")
+}
+
+func TestValidate(t *testing.T) {
+	code, out := runValidate(t, `endpoints:
+  - domain: one.example.com
+    reuseConnections: true
+    interval: 100ms
+  - domain: two.example.com
+    expect:
+      statusCode: ["200"]
+      bodyRegex: "("
+`)
+	if code != cli.ExitFailed {
+		t.Fatalf("expected exit code %d, got %d", cli.ExitFailed, code)
+	}
+
+	for _, want := range []string{
+		"<dir>: 4 problems found",
+		"  endpoints[0].reuseconnections: unknown field",
+		"  endpoints[1].expect.statuscode: unknown field",
+		"  endpoints[0].interval: interval cannot be less than 1 second",
+		"  endpoints[1].expect: invalid expect block for two.example.com",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+
+	code, out = runValidate(t, "endpoints:\n  - domain: one.example.com\n")
+	if code != cli.ExitOK {
+		t.Fatalf("expected exit code %d, got %d: %s", cli.ExitOK, code, out)
+	}
+
+	if want := "<dir>: valid, 1 endpoint\n"; out != want {
+		t.Errorf("expected %q, got %q", want, out)
+	}
+}
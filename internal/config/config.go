@@ -3,13 +3,31 @@
 package config
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
+	"text/template"
 	"time"
 
+	"github.com/dntosas/astrolavos/internal/jsonpath"
+	"github.com/dntosas/astrolavos/internal/mesh"
 	"github.com/dntosas/astrolavos/internal/model"
 
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/spf13/viper"
 
 	log "github.com/sirupsen/logrus"
@@ -18,22 +36,36 @@ import (
 // YamlEndpoints encapsulates the top-level YAML configuration containing
 // the list of endpoints to monitor.
 type YamlEndpoints struct {
-	Endpoints []YamlEndpoint `yaml:"endpoints"`
+	Endpoints      []YamlEndpoint    `yaml:"endpoints"`
+	ExternalLabels map[string]string `yaml:"externalLabels"`
+	Mesh           *YamlMesh         `yaml:"mesh"`
+	Alerting       *YamlAlerting     `yaml:"alerting"`
 }
 
 // getCleanEndpoints validates and converts YAML endpoint configurations
 // into application-ready Endpoint structs.
 func (r *YamlEndpoints) getCleanEndpoints() ([]*model.Endpoint, error) {
+	if len(r.Endpoints) == 0 && r.Mesh != nil {
+		return []*model.Endpoint{}, nil
+	}
+
 	if len(r.Endpoints) == 0 {
 		return []*model.Endpoint{}, errors.New("YAML configuration is empty or malformed: no endpoints defined")
 	}
 
 	cleanEndpoints := []*model.Endpoint{}
+	seen := map[model.Key]int{}
 
-	for _, req := range r.Endpoints {
+	for i, req := range r.Endpoints {
 		c, err := req.getCleanEndpoint()
+		if err == nil {
+			err = checkDuplicate(seen, i, c)
+		}
+
 		if err != nil {
-			log.Error(err.Error())
+			for _, e := range Errors(atField(fmt.Sprintf("endpoints[%d]", i), err)) {
+				log.Errorf("Skipping invalid endpoint: %v", e)
+			}
 
 			continue
 		}
@@ -48,17 +80,478 @@ func (r *YamlEndpoints) getCleanEndpoints() ([]*model.Endpoint, error) {
 	return cleanEndpoints, nil
 }
 
+// getStrictEndpoints converts every endpoint and fails if any of them is
+// invalid, so a typo in a reloaded file cannot silently stop a prober.
+func (r *YamlEndpoints) getStrictEndpoints() ([]*model.Endpoint, error) {
+	if len(r.Endpoints) == 0 && r.Mesh != nil {
+		return []*model.Endpoint{}, nil
+	}
+
+	if len(r.Endpoints) == 0 {
+		return nil, errors.New("YAML configuration is empty or malformed: no endpoints defined")
+	}
+
+	cleanEndpoints := make([]*model.Endpoint, 0, len(r.Endpoints))
+
+	var errs []error
+
+	seen := map[model.Key]int{}
+
+	for i, req := range r.Endpoints {
+		c, err := req.getCleanEndpoint()
+		if err == nil {
+			err = checkDuplicate(seen, i, c)
+		}
+
+		if err != nil {
+			errs = append(errs, atField(fmt.Sprintf("endpoints[%d]", i), err))
+
+			continue
+		}
+
+		cleanEndpoints = append(cleanEndpoints, c)
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return cleanEndpoints, nil
+}
+
+// checkDuplicate fails if an earlier endpoint in seen has the key of the
+// endpoint at index i, as both would export the same series, and records it
+// otherwise. Endpoints differing only in settings such as the record type or
+// the method need their own tag.
+func checkDuplicate(seen map[model.Key]int, i int, e *model.Endpoint) error {
+	if j, ok := seen[e.Key()]; ok {
+		return atField("tag", fmt.Errorf("%s endpoint %s is already probed by endpoints[%d] with this tag", e.ProberType, e.URI, j))
+	}
+
+	seen[e.Key()] = i
+
+	return nil
+}
+
+// FieldError is an invalid setting of the configuration file, located by
+// its path in the file such as endpoints[2].method.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// atField locates err at field. Errors already located within the field,
+// including joined ones, keep their own path beneath it.
+func atField(field string, err error) error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs := make([]error, 0, len(joined.Unwrap()))
+		for _, e := range joined.Unwrap() {
+			errs = append(errs, atField(field, e))
+		}
+
+		return errors.Join(errs...)
+	}
+
+	if fe, ok := err.(*FieldError); ok {
+		return &FieldError{Field: field + "." + fe.Field, Err: fe.Err}
+	}
+
+	return &FieldError{Field: field, Err: err}
+}
+
+// Errors splits an error of NewConfig or Validate into the problems it
+// reports.
+func Errors(err error) []error {
+	if err == nil {
+		return nil
+	}
+
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+
+	var errs []error
+	for _, e := range joined.Unwrap() {
+		errs = append(errs, Errors(e)...)
+	}
+
+	return errs
+}
+
 // YamlEndpoint represents a single endpoint configuration from the YAML file.
 type YamlEndpoint struct {
-	Domain              string         `yaml:"domain"`
-	Interval            *time.Duration `yaml:"interval"`
-	HTTPS               bool           `yaml:"https"`
-	Tag                 string         `yaml:"tag"`
-	Retries             *int           `yaml:"retries"`
-	Prober              string         `yaml:"prober"`
-	ReuseConnection     bool           `yaml:"reuseConnection"`
-	SkipTLSVerification bool           `yaml:"skipTLSVerification"`
-	TCPTimeout          *time.Duration `yaml:"tcpTimeout"`
+	Domain              string             `yaml:"domain"`
+	Interval            *time.Duration     `yaml:"interval"`
+	HTTPS               bool               `yaml:"https"`
+	Tag                 string             `yaml:"tag"`
+	Labels              map[string]string  `yaml:"labels"`
+	Retries             *int               `yaml:"retries"`
+	Prober              string             `yaml:"prober"`
+	ReuseConnection     bool               `yaml:"reuseConnection"`
+	SkipTLSVerification bool               `yaml:"skipTLSVerification"`
+	TCPTimeout          *time.Duration     `yaml:"tcpTimeout"`
+	RecordType          string             `yaml:"recordType"`
+	Resolver            string             `yaml:"resolver"`
+	ExpectedAnswers     []string           `yaml:"expectedAnswers"`
+	AnswerPattern       string             `yaml:"answerPattern"`
+	ServerName          string             `yaml:"serverName"`
+	CAFile              string             `yaml:"caFile"`
+	Method              string             `yaml:"method"`
+	Headers             map[string]string  `yaml:"headers"`
+	Body                string             `yaml:"body"`
+	BodyFile            string             `yaml:"bodyFile"`
+	Expect              *YamlExpect        `yaml:"expect"`
+	Timeout             *time.Duration     `yaml:"timeout"`
+	PhaseTimeouts       *YamlPhaseTimeouts `yaml:"phaseTimeouts"`
+	SLO                 *YamlSLO           `yaml:"slo"`
+}
+
+// YamlSLO represents the optional service level objective of an endpoint.
+type YamlSLO struct {
+	Window            *time.Duration `yaml:"window"`
+	LatencyPercentile *float64       `yaml:"latencyPercentile"`
+	LatencyThreshold  time.Duration  `yaml:"latencyThreshold"`
+	SuccessRatio      float64        `yaml:"successRatio"`
+}
+
+// getCleanSLO validates an SLO and fills in defaults. The window must span
+// several probes for its ratios to be meaningful.
+func (r *YamlSLO) getCleanSLO(interval time.Duration) (*model.SLO, error) {
+	s := &model.SLO{
+		Window:            time.Hour,
+		LatencyPercentile: 95,
+		LatencyThreshold:  r.LatencyThreshold,
+		SuccessRatio:      r.SuccessRatio,
+	}
+
+	if r.Window != nil {
+		s.Window = *r.Window
+	}
+
+	if r.LatencyPercentile != nil {
+		s.LatencyPercentile = *r.LatencyPercentile
+	}
+
+	if s.LatencyThreshold == 0 && s.SuccessRatio == 0 {
+		return nil, errors.New("slo requires a latencyThreshold or a successRatio")
+	}
+
+	if s.Window < 2*interval {
+		return nil, fmt.Errorf("slo window %v must be at least twice the interval", s.Window)
+	}
+
+	if s.LatencyThreshold < 0 {
+		return nil, errors.New("slo latencyThreshold cannot be negative")
+	}
+
+	if s.LatencyPercentile <= 0 || s.LatencyPercentile >= 100 {
+		return nil, errors.New("slo latencyPercentile must be between 0 and 100, exclusive")
+	}
+
+	if s.SuccessRatio < 0 || s.SuccessRatio >= 1 {
+		return nil, errors.New("slo successRatio must be between 0 and 1, e.g. 0.999 for 99.9%")
+	}
+
+	return s, nil
+}
+
+// YamlPhaseTimeouts represents the optional per-phase deadlines of an httpTrace endpoint.
+type YamlPhaseTimeouts struct {
+	DNS       time.Duration `yaml:"dns"`
+	Connect   time.Duration `yaml:"connect"`
+	TLS       time.Duration `yaml:"tls"`
+	FirstByte time.Duration `yaml:"firstByte"`
+}
+
+// YamlExpect represents the response assertions of an httpTrace endpoint.
+type YamlExpect struct {
+	StatusCodes  []string          `yaml:"statusCodes"`
+	BodyContains string            `yaml:"bodyContains"`
+	BodyRegex    string            `yaml:"bodyRegex"`
+	JSONPath     []YamlJSONPath    `yaml:"jsonPath"`
+	Headers      map[string]string `yaml:"headers"`
+	MaxBodySize  int64             `yaml:"maxBodySize"`
+}
+
+// YamlJSONPath represents a single JSONPath equality assertion.
+type YamlJSONPath struct {
+	Path   string `yaml:"path"`
+	Equals string `yaml:"equals"`
+}
+
+// YamlMesh represents the optional mesh mode, probing peer instances
+// discovered through DNS.
+type YamlMesh struct {
+	Discovery       string         `yaml:"discovery"`
+	RecordType      string         `yaml:"recordType"`
+	Port            int            `yaml:"port"`
+	RefreshInterval *time.Duration `yaml:"refreshInterval"`
+	Source          string         `yaml:"source"`
+	PayloadSize     int            `yaml:"payloadSize"`
+	Interval        *time.Duration `yaml:"interval"`
+	Tag             string         `yaml:"tag"`
+	Retries         *int           `yaml:"retries"`
+	Timeout         *time.Duration `yaml:"timeout"`
+}
+
+// getCleanMesh validates the mesh settings and fills in defaults. Peers are
+// assumed to listen on the same port as this instance unless set otherwise.
+func (r *YamlMesh) getCleanMesh(appPort int) (*model.Mesh, error) {
+	if r.Discovery == "" {
+		return nil, errors.New("mesh discovery name is required")
+	}
+
+	m := &model.Mesh{
+		Discovery:       r.Discovery,
+		RecordType:      strings.ToUpper(r.RecordType),
+		Port:            r.Port,
+		RefreshInterval: 30 * time.Second,
+		Source:          r.Source,
+		PayloadSize:     r.PayloadSize,
+		Interval:        5 * time.Second,
+		Tag:             r.Tag,
+		Retries:         1,
+		Timeout:         10 * time.Second,
+	}
+
+	if m.RecordType == "" {
+		m.RecordType = "A"
+	}
+
+	if m.RecordType != "A" && m.RecordType != "SRV" {
+		return nil, fmt.Errorf("invalid mesh record type '%s': must be one of ['A', 'SRV']", r.RecordType)
+	}
+
+	if m.Port == 0 {
+		m.Port = appPort
+	}
+
+	if m.Port < 1 || m.Port > 65535 {
+		return nil, fmt.Errorf("invalid mesh port %d", m.Port)
+	}
+
+	if r.RefreshInterval != nil {
+		m.RefreshInterval = *r.RefreshInterval
+	}
+
+	if r.Interval != nil {
+		m.Interval = *r.Interval
+	}
+
+	if m.RefreshInterval < time.Second || m.Interval < time.Second {
+		return nil, errors.New("mesh intervals cannot be less than 1 second")
+	}
+
+	if m.PayloadSize < 0 {
+		return nil, errors.New("mesh payloadSize cannot be negative")
+	}
+
+	if r.Retries != nil {
+		m.Retries = *r.Retries
+	}
+
+	if r.Timeout != nil {
+		m.Timeout = *r.Timeout
+	}
+
+	if m.Tag == "" {
+		m.Tag = "mesh"
+	}
+
+	if m.Source == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("mesh source is not set and the hostname is unavailable: %w", err)
+		}
+
+		m.Source = hostname
+	}
+
+	return m, nil
+}
+
+// YamlAlerting represents the optional webhook notifications sent when an
+// endpoint goes down or comes back up.
+type YamlAlerting struct {
+	FailureThreshold *int          `yaml:"failureThreshold"`
+	SuccessThreshold *int          `yaml:"successThreshold"`
+	Webhooks         []YamlWebhook `yaml:"webhooks"`
+}
+
+// YamlWebhook represents a single notification receiver.
+type YamlWebhook struct {
+	URL      string            `yaml:"url"`
+	Headers  map[string]string `yaml:"headers"`
+	Format   string            `yaml:"format"`
+	Template string            `yaml:"template"`
+	Retries  *int              `yaml:"retries"`
+	Timeout  *time.Duration    `yaml:"timeout"`
+}
+
+// webhookFormats lists the built-in webhook body formats.
+var webhookFormats = []string{"json", "slack"}
+
+// getCleanAlerting validates the alerting settings and fills in defaults.
+// Secret references are resolved in webhook URLs and header values, as
+// incoming webhook URLs usually embed a token.
+func (r *YamlAlerting) getCleanAlerting() (*model.Alerting, error) {
+	if len(r.Webhooks) == 0 {
+		return nil, errors.New("alerting requires at least one webhook")
+	}
+
+	a := &model.Alerting{FailureThreshold: 3, SuccessThreshold: 1}
+
+	if r.FailureThreshold != nil {
+		a.FailureThreshold = *r.FailureThreshold
+	}
+
+	if r.SuccessThreshold != nil {
+		a.SuccessThreshold = *r.SuccessThreshold
+	}
+
+	if a.FailureThreshold < 1 || a.SuccessThreshold < 1 {
+		return nil, errors.New("alerting thresholds must be at least 1")
+	}
+
+	for i, w := range r.Webhooks {
+		webhook, err := w.getCleanWebhook()
+		if err != nil {
+			return nil, fmt.Errorf("webhook %d: %w", i, err)
+		}
+
+		a.Webhooks = append(a.Webhooks, webhook)
+	}
+
+	return a, nil
+}
+
+// getCleanWebhook validates a webhook and fills in defaults.
+func (r *YamlWebhook) getCleanWebhook() (model.Webhook, error) {
+	w := model.Webhook{
+		Format:  strings.ToLower(r.Format),
+		Retries: 3,
+		Timeout: 10 * time.Second,
+	}
+
+	url, err := resolveSecretRefs(r.URL)
+	if err != nil {
+		return w, fmt.Errorf("url: %w", err)
+	}
+
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return w, errors.New("url must start with http:// or https://")
+	}
+
+	w.URL = url
+
+	if len(r.Headers) > 0 {
+		w.Headers = make(map[string]string, len(r.Headers))
+
+		for name, value := range r.Headers {
+			if w.Headers[name], err = resolveSecretRefs(value); err != nil {
+				return w, fmt.Errorf("header %s: %w", name, err)
+			}
+		}
+	}
+
+	if w.Format == "" {
+		w.Format = "json"
+	}
+
+	if !slices.Contains(webhookFormats, w.Format) {
+		return w, fmt.Errorf("invalid format '%s': must be one of ['json', 'slack']", r.Format)
+	}
+
+	if r.Template != "" {
+		if w.Template, err = template.New("webhook").Option("missingkey=error").Parse(r.Template); err != nil {
+			return w, fmt.Errorf("invalid template: %w", err)
+		}
+	}
+
+	if r.Retries != nil {
+		w.Retries = *r.Retries
+	}
+
+	if w.Retries < 1 {
+		return w, errors.New("retries must be at least 1")
+	}
+
+	if r.Timeout != nil {
+		w.Timeout = *r.Timeout
+	}
+
+	if w.Timeout <= 0 {
+		return w, errors.New("timeout must be positive")
+	}
+
+	return w, nil
+}
+
+// proberTypes lists every prober type accepted in the configuration.
+var proberTypes = []string{"tcp", "httpTrace", "dns", "tls"}
+
+// httpMethods lists the request methods the httpTrace prober can send.
+var httpMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// secretRefPattern matches ${env:NAME} and ${file:/path} references in header values.
+var secretRefPattern = regexp.MustCompile(`\$\{(env|file):([^}]+)\}`)
+
+// dnsRecordTypes lists the record types the dns prober can query.
+var dnsRecordTypes = []string{"A", "AAAA", "CNAME", "SRV", "TXT"}
+
+// labelNamePattern matches valid Prometheus label names.
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// reservedLabelNames are set by Astrolavos itself and cannot be used as custom labels.
+var reservedLabelNames = []string{
+	"domain", "tag", "prober_type", "status_code", "error", "record_type",
+	"rcode", "depth", "common_name", "version", "cipher_suite", "objective",
+	"phase", "statistic",
+}
+
+// validateLabels checks that every label name is a valid, non-reserved Prometheus label name.
+func validateLabels(labels map[string]string) error {
+	for _, name := range slices.Sorted(maps.Keys(labels)) {
+		if !labelNamePattern.MatchString(name) || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+
+		if slices.Contains(reservedLabelNames, name) {
+			return fmt.Errorf("label name %q is reserved", name)
+		}
+	}
+
+	return nil
+}
+
+// endpointLabelNames returns the sorted union of custom label names across
+// endpoints. Every metric vector is created with this fixed set.
+func endpointLabelNames(endpoints []*model.Endpoint) []string {
+	names := []string{}
+
+	for _, e := range endpoints {
+		for name := range e.Labels {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+
+	slices.Sort(names)
+
+	return names
 }
 
 // getCleanEndpoint validates and converts a YAML endpoint into an application Endpoint.
@@ -75,12 +568,22 @@ func (r *YamlEndpoint) getCleanEndpoint() (*model.Endpoint, error) {
 		r.Prober = "httpTrace"
 	}
 
+	var errs []error
+
+	if r.Domain == "" {
+		errs = append(errs, atField("domain", errors.New("domain is required")))
+	}
+
 	if *r.Interval < 1000*time.Millisecond {
-		return nil, errors.New("interval cannot be less than 1 second")
+		errs = append(errs, atField("interval", errors.New("interval cannot be less than 1 second")))
+	}
+
+	if !slices.Contains(proberTypes, r.Prober) {
+		errs = append(errs, atField("prober", fmt.Errorf("invalid prober type '%s': must be one of ['tcp', 'httpTrace', 'dns', 'tls']", r.Prober)))
 	}
 
-	if r.Prober != "tcp" && r.Prober != "httpTrace" {
-		return nil, fmt.Errorf("invalid prober type '%s': must be one of ['tcp', 'httpTrace']", r.Prober)
+	if err := validateLabels(r.Labels); err != nil {
+		errs = append(errs, atField("labels", err))
 	}
 
 	uri := r.Domain
@@ -107,6 +610,7 @@ func (r *YamlEndpoint) getCleanEndpoint() (*model.Endpoint, error) {
 		URI:                 uri,
 		Interval:            *r.Interval,
 		Tag:                 r.Tag,
+		Labels:              r.Labels,
 		Retries:             defaultRetries,
 		ProberType:          r.Prober,
 		ReuseConnection:     r.ReuseConnection,
@@ -114,78 +618,931 @@ func (r *YamlEndpoint) getCleanEndpoint() (*model.Endpoint, error) {
 		TCPTimeout:          defaultTCPTimeout,
 	}
 
-	return ep, nil
-}
+	if r.SLO != nil {
+		slo, err := r.SLO.getCleanSLO(ep.Interval)
+		if err != nil {
+			errs = append(errs, atField("slo", fmt.Errorf("invalid slo for %s: %w", r.Domain, err)))
+		}
 
-// Config holds all application configuration.
-type Config struct {
-	AppPort         int
-	MaxPayloadSize  int
-	LogLevel        string
-	PromPushGateway string
-	Endpoints       []*model.Endpoint
-}
+		ep.SLO = slo
+	}
 
-// NewConfig loads and validates configuration from the given path.
-func NewConfig(path string) (*Config, error) {
-	initViper(path)
+	var err error
 
-	r, err := getYamlConfig()
-	if err != nil {
-		return nil, fmt.Errorf("failed to load YAML config: %w", err)
+	switch r.Prober {
+	case "httpTrace":
+		err = r.setHTTPOptions(ep)
+	case "dns":
+		err = r.setDNSOptions(ep)
+	case "tls":
+		err = r.setTLSOptions(ep)
 	}
 
-	cleanEndpoints, err := r.getCleanEndpoints()
 	if err != nil {
-		return nil, fmt.Errorf("failed to validate endpoints: %w", err)
+		errs = append(errs, err)
 	}
 
-	port := viper.GetString("app_port")
-
-	intPort, err := strconv.Atoi(port)
-	if err != nil {
-		return nil, fmt.Errorf("invalid ASTROLAVOS_PORT value %q: %w", port, err)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
 	}
 
-	return &Config{
-		AppPort:         intPort,
-		MaxPayloadSize:  viper.GetInt("max_payload_size"),
-		LogLevel:        viper.GetString("log_level"),
-		PromPushGateway: viper.GetString("prom_push_gw"),
-		Endpoints:       cleanEndpoints,
-	}, nil
+	return ep, nil
 }
 
-// initViper initializes Viper configuration with defaults and env variable support.
-func initViper(path string) {
-	// Set global options
-	viper.AddConfigPath(path)
-	viper.AddConfigPath(".")
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.SetEnvPrefix("astrolavos")
+// setHTTPOptions validates the httpTrace request settings and copies them
+// into the endpoint, resolving secret references in header values.
+func (r *YamlEndpoint) setHTTPOptions(ep *model.Endpoint) error {
+	method := strings.ToUpper(r.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
 
-	// Set defaults for environment variables
-	viper.SetDefault("APP_PORT", "3000")
-	viper.SetDefault("LOG_LEVEL", "DEBUG")
-	viper.SetDefault("PROM_PUSH_GW", "localhost")
-	viper.SetDefault("MAX_PAYLOAD_SIZE", 0) // 0 means use handler's default (10MB)
+	if !slices.Contains(httpMethods, method) {
+		return atField("method", fmt.Errorf("invalid method '%s' for %s: must be one of %v", r.Method, r.Domain, httpMethods))
+	}
 
-	// Enable VIPER to read Environment Variables
-	viper.AutomaticEnv()
-}
+	if r.Body != "" && r.BodyFile != "" {
+		return atField("bodyFile", fmt.Errorf("body and bodyFile are mutually exclusive for %s", r.Domain))
+	}
 
-// getYamlConfig reads and parses the YAML configuration file.
-func getYamlConfig() (*YamlEndpoints, error) {
-	if err := viper.ReadInConfig(); err != nil {
-		return nil, fmt.Errorf("error reading config file: %w", err)
+	if len(r.Headers) > 0 {
+		ep.Headers = make(map[string]string, len(r.Headers))
+
+		for name, value := range r.Headers {
+			resolved, err := resolveSecretRefs(value)
+			if err != nil {
+				return atField("headers", fmt.Errorf("invalid header %q for %s: %w", name, r.Domain, err))
+			}
+
+			ep.Headers[name] = resolved
+		}
 	}
 
-	var ye YamlEndpoints
+	switch {
+	case r.BodyFile != "":
+		body, err := os.ReadFile(r.BodyFile)
+		if err != nil {
+			return atField("bodyFile", fmt.Errorf("unable to read body file for %s: %w", r.Domain, err))
+		}
 
-	if err := viper.Unmarshal(&ye); err != nil {
-		return nil, fmt.Errorf("unable to decode config YAML into struct: %w", err)
+		ep.Body = body
+	case r.Body != "":
+		ep.Body = []byte(r.Body)
 	}
 
-	return &ye, nil
+	var defaultTimeout = 10 * time.Second
+
+	if r.Timeout != nil {
+		defaultTimeout = *r.Timeout
+	}
+
+	if defaultTimeout <= 0 {
+		return atField("timeout", fmt.Errorf("timeout for %s must be greater than zero", r.Domain))
+	}
+
+	if r.PhaseTimeouts != nil {
+		phases := model.PhaseTimeouts(*r.PhaseTimeouts)
+
+		for _, phase := range []struct {
+			name    string
+			timeout time.Duration
+		}{
+			{"dns", phases.DNS},
+			{"connect", phases.Connect},
+			{"tls", phases.TLS},
+			{"firstByte", phases.FirstByte},
+		} {
+			if phase.timeout < 0 || phase.timeout > defaultTimeout {
+				return atField("phaseTimeouts."+phase.name, fmt.Errorf("%s phase timeout for %s must be between 0 and the total timeout %v", phase.name, r.Domain, defaultTimeout))
+			}
+		}
+
+		ep.PhaseTimeouts = phases
+	}
+
+	ep.Timeout = defaultTimeout
+
+	if r.Expect != nil {
+		expect, err := r.Expect.getCleanExpectations()
+		if err != nil {
+			return atField("expect", fmt.Errorf("invalid expect block for %s: %w", r.Domain, err))
+		}
+
+		ep.Expect = expect
+	}
+
+	ep.Method = method
+
+	return nil
+}
+
+// getCleanExpectations validates and compiles the response assertions.
+func (r *YamlExpect) getCleanExpectations() (*model.Expectations, error) {
+	e := &model.Expectations{
+		BodyContains: r.BodyContains,
+		MaxBodySize:  r.MaxBodySize,
+	}
+
+	if r.MaxBodySize < 0 {
+		return nil, errors.New("maxBodySize cannot be negative")
+	}
+
+	for _, code := range r.StatusCodes {
+		sr, err := parseStatusCodeRange(code)
+		if err != nil {
+			return nil, err
+		}
+
+		e.StatusCodes = append(e.StatusCodes, sr)
+	}
+
+	if r.BodyRegex != "" {
+		re, err := regexp.Compile(r.BodyRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bodyRegex: %w", err)
+		}
+
+		e.BodyRegex = re
+	}
+
+	for _, jp := range r.JSONPath {
+		path, err := jsonpath.Parse(jp.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		e.JSONPaths = append(e.JSONPaths, model.JSONPathExpectation{Path: path, Equals: jp.Equals})
+	}
+
+	if len(r.Headers) > 0 {
+		e.Headers = make(map[string]*regexp.Regexp, len(r.Headers))
+
+		for name, pattern := range r.Headers {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern for header %q: %w", name, err)
+			}
+
+			e.Headers[name] = re
+		}
+	}
+
+	return e, nil
+}
+
+// parseStatusCodeRange parses an accepted status code given as a single
+// code ("200"), a class ("2xx") or an inclusive range ("200-299").
+func parseStatusCodeRange(code string) (model.StatusCodeRange, error) {
+	invalid := fmt.Errorf("invalid status code %q: must be a code, a class like 2xx or a range like 200-299", code)
+
+	if len(code) == 3 && strings.HasSuffix(strings.ToLower(code), "xx") && code[0] >= '1' && code[0] <= '5' {
+		class := int(code[0]-'0') * 100
+
+		return model.StatusCodeRange{Min: class, Max: class + 99}, nil
+	}
+
+	lo, hi, isRange := strings.Cut(code, "-")
+	if !isRange {
+		hi = lo
+	}
+
+	minCode, err := strconv.Atoi(strings.TrimSpace(lo))
+	if err != nil {
+		return model.StatusCodeRange{}, invalid
+	}
+
+	maxCode, err := strconv.Atoi(strings.TrimSpace(hi))
+	if err != nil || minCode < 100 || maxCode > 599 || minCode > maxCode {
+		return model.StatusCodeRange{}, invalid
+	}
+
+	return model.StatusCodeRange{Min: minCode, Max: maxCode}, nil
+}
+
+// resolveSecretRefs replaces ${env:NAME} references with the value of the
+// environment variable and ${file:/path} references with the contents of
+// the file (without trailing newlines), so tokens never live in the config.
+func resolveSecretRefs(value string) (string, error) {
+	var resolveErr error
+
+	resolved := secretRefPattern.ReplaceAllStringFunc(value, func(ref string) string {
+		m := secretRefPattern.FindStringSubmatch(ref)
+
+		if m[1] == "env" {
+			v, ok := os.LookupEnv(m[2])
+			if !ok {
+				resolveErr = fmt.Errorf("environment variable %s is not set", m[2])
+			}
+
+			return v
+		}
+
+		b, err := os.ReadFile(m[2])
+		if err != nil {
+			resolveErr = fmt.Errorf("unable to read secret file: %w", err)
+		}
+
+		return strings.TrimRight(string(b), "\r\n")
+	})
+
+	return resolved, resolveErr
+}
+
+// setDNSOptions validates the dns prober settings and copies them into the endpoint.
+func (r *YamlEndpoint) setDNSOptions(ep *model.Endpoint) error {
+	recordType := strings.ToUpper(r.RecordType)
+	if recordType == "" {
+		recordType = "A"
+	}
+
+	if !slices.Contains(dnsRecordTypes, recordType) {
+		return atField("recordType", fmt.Errorf("invalid record type '%s' for %s: must be one of %v", r.RecordType, r.Domain, dnsRecordTypes))
+	}
+
+	resolver := r.Resolver
+	if resolver != "" {
+		if _, _, err := net.SplitHostPort(resolver); err != nil {
+			resolver = net.JoinHostPort(resolver, "53")
+		}
+	}
+
+	if r.AnswerPattern != "" {
+		re, err := regexp.Compile(r.AnswerPattern)
+		if err != nil {
+			return atField("answerPattern", fmt.Errorf("invalid answer pattern for %s: %w", r.Domain, err))
+		}
+
+		ep.AnswerPattern = re
+	}
+
+	// tcpTimeout used to bound dns queries and is still honored without a timeout
+	timeout := ep.TCPTimeout
+
+	if r.Timeout != nil {
+		timeout = *r.Timeout
+	}
+
+	if timeout <= 0 {
+		return atField("timeout", fmt.Errorf("timeout for %s must be greater than zero", r.Domain))
+	}
+
+	ep.Timeout = timeout
+	ep.RecordType = recordType
+	ep.Resolver = resolver
+	ep.ExpectedAnswers = r.ExpectedAnswers
+
+	return nil
+}
+
+// setTLSOptions validates the tls prober settings and copies them into the endpoint.
+// Endpoints without a port default to 443.
+func (r *YamlEndpoint) setTLSOptions(ep *model.Endpoint) error {
+	if _, _, err := net.SplitHostPort(r.Domain); err != nil {
+		ep.URI = net.JoinHostPort(r.Domain, "443")
+	}
+
+	if r.CAFile != "" {
+		pem, err := os.ReadFile(r.CAFile)
+		if err != nil {
+			return atField("caFile", fmt.Errorf("unable to read CA bundle for %s: %w", r.Domain, err))
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return atField("caFile", fmt.Errorf("no valid certificates found in CA bundle %s", r.CAFile))
+		}
+
+		ep.RootCAs = pool
+	}
+
+	ep.ServerName = r.ServerName
+
+	return nil
+}
+
+// Config holds all application configuration.
+type Config struct {
+	AppPort        int
+	MaxPayloadSize int
+	LogLevel       string
+	// PushGateway configures pushing metrics to a Prometheus push gateway.
+	PushGateway *model.PushGateway
+	Endpoints   []*model.Endpoint
+	// LabelNames is the sorted union of custom endpoint label names.
+	LabelNames []string
+	// ExternalLabels are added to every exported series.
+	ExternalLabels map[string]string
+	// Mesh enables peer discovery when set.
+	Mesh *model.Mesh
+	// ResultsWindow is the rolling window of the latency matrix.
+	ResultsWindow time.Duration
+	// ResultsHistory is the number of recent results kept per endpoint.
+	ResultsHistory int
+	// Alerting enables webhook notifications when set.
+	Alerting *model.Alerting
+	// OTLP enables the export of metrics or traces to an OpenTelemetry
+	// collector when set.
+	OTLP *model.OTLP
+	// DogStatsD enables sending probe measurements to a DogStatsD server
+	// when set.
+	DogStatsD *model.DogStatsD
+	// Strict rejects a configuration with unknown fields or any invalid
+	// endpoint instead of ignoring them.
+	Strict bool
+
+	file string
+}
+
+// reloadMu serializes reloads, as the global viper instance is not safe for
+// concurrent use.
+var reloadMu sync.Mutex
+
+// NewConfig loads and validates configuration from the given path. Invalid
+// endpoints are logged and skipped and unknown fields are logged, unless
+// ASTROLAVOS_STRICT is set, in which case either fails the configuration.
+func NewConfig(path string) (*Config, error) {
+	initViper(path)
+
+	return load(viper.GetBool("strict"))
+}
+
+// Validate loads the configuration from the given path in strict mode and
+// reports every problem found rather than only the first. Use Errors to
+// list them.
+func Validate(path string) (*Config, error) {
+	initViper(path)
+
+	return load(true)
+}
+
+// load reads and validates the configuration file and settings of the
+// environment, collecting the errors of every section.
+func load(strict bool) (*Config, error) {
+	r, err := getYamlConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load YAML config: %w", err)
+	}
+
+	var errs []error
+
+	if err = unknownFields(); err != nil {
+		if strict {
+			errs = append(errs, err)
+		} else {
+			for _, e := range Errors(err) {
+				log.Warnf("Ignoring configuration: %v", e)
+			}
+		}
+	}
+
+	getEndpoints := r.getCleanEndpoints
+	if strict {
+		getEndpoints = r.getStrictEndpoints
+	}
+
+	cleanEndpoints, err := getEndpoints()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	port := viper.GetString("app_port")
+
+	intPort, err := strconv.Atoi(port)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid ASTROLAVOS_PORT value %q: %w", port, err))
+	}
+
+	labelNames := endpointLabelNames(cleanEndpoints)
+
+	var meshCfg *model.Mesh
+
+	if r.Mesh != nil {
+		if meshCfg, err = r.Mesh.getCleanMesh(intPort); err != nil {
+			errs = append(errs, fmt.Errorf("failed to validate mesh: %w", err))
+		}
+
+		for _, name := range []string{mesh.SourceLabel, mesh.DestinationLabel} {
+			if !slices.Contains(labelNames, name) {
+				labelNames = append(labelNames, name)
+			}
+		}
+
+		slices.Sort(labelNames)
+	}
+
+	if err = validateLabels(r.ExternalLabels); err != nil {
+		errs = append(errs, fmt.Errorf("failed to validate externalLabels: %w", err))
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(r.ExternalLabels)) {
+		if slices.Contains(labelNames, name) {
+			errs = append(errs, fmt.Errorf("external label %q is also set as an endpoint label", name))
+		}
+	}
+
+	var alerting *model.Alerting
+
+	if r.Alerting != nil {
+		if alerting, err = r.Alerting.getCleanAlerting(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to validate alerting: %w", err))
+		}
+	}
+
+	resultsWindow := viper.GetDuration("results_window")
+	if resultsWindow <= 0 {
+		errs = append(errs, fmt.Errorf("invalid ASTROLAVOS_RESULTS_WINDOW value %q: must be a positive duration", viper.GetString("results_window")))
+	}
+
+	resultsHistory := viper.GetInt("results_history")
+	if resultsHistory <= 0 {
+		errs = append(errs, fmt.Errorf("invalid ASTROLAVOS_RESULTS_HISTORY value %q: must be a positive number", viper.GetString("results_history")))
+	}
+
+	otlp, err := getCleanOTLP()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to validate OTLP settings: %w", err))
+	}
+
+	pushGateway, err := getCleanPushGateway(r.ExternalLabels)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to validate push gateway settings: %w", err))
+	}
+
+	dogStatsD, err := getCleanDogStatsD()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to validate DogStatsD settings: %w", err))
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return &Config{
+		AppPort:        intPort,
+		MaxPayloadSize: viper.GetInt("max_payload_size"),
+		LogLevel:       viper.GetString("log_level"),
+		PushGateway:    pushGateway,
+		Endpoints:      cleanEndpoints,
+		LabelNames:     labelNames,
+		ExternalLabels: r.ExternalLabels,
+		Mesh:           meshCfg,
+		ResultsWindow:  resultsWindow,
+		ResultsHistory: resultsHistory,
+		Alerting:       alerting,
+		OTLP:           otlp,
+		DogStatsD:      dogStatsD,
+		Strict:         strict,
+		file:           viper.ConfigFileUsed(),
+	}, nil
+}
+
+// otlpProtocols lists the supported OTLP transports.
+var otlpProtocols = []string{"grpc", "http"}
+
+// getCleanOTLP reads the OTLP exporter settings from the environment, or
+// returns nil when neither a metrics nor a traces endpoint is set. Headers
+// are comma separated name=value pairs whose values may hold secret
+// references.
+func getCleanOTLP() (*model.OTLP, error) {
+	endpoint := viper.GetString("otlp_endpoint")
+	tracesEndpoint := viper.GetString("otlp_traces_endpoint")
+
+	if endpoint == "" && tracesEndpoint == "" {
+		return nil, nil //nolint:nilnil // OTLP export is disabled
+	}
+
+	o := &model.OTLP{
+		Protocol: strings.ToLower(viper.GetString("otlp_protocol")),
+		Interval: viper.GetDuration("otlp_interval"),
+	}
+
+	if !slices.Contains(otlpProtocols, o.Protocol) {
+		return nil, fmt.Errorf("invalid ASTROLAVOS_OTLP_PROTOCOL value %q: must be one of %v", o.Protocol, otlpProtocols)
+	}
+
+	var err error
+
+	if o.Endpoint, err = otlpURL(endpoint, o.Protocol, "/v1/metrics"); err != nil {
+		return nil, fmt.Errorf("invalid ASTROLAVOS_OTLP_ENDPOINT value %q: %w", endpoint, err)
+	}
+
+	if o.TracesEndpoint, err = otlpURL(tracesEndpoint, o.Protocol, "/v1/traces"); err != nil {
+		return nil, fmt.Errorf("invalid ASTROLAVOS_OTLP_TRACES_ENDPOINT value %q: %w", tracesEndpoint, err)
+	}
+
+	if o.Interval <= 0 {
+		return nil, fmt.Errorf("invalid ASTROLAVOS_OTLP_INTERVAL value %q: must be a positive duration", viper.GetString("otlp_interval"))
+	}
+
+	for pair := range strings.SplitSeq(viper.GetString("otlp_headers"), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid ASTROLAVOS_OTLP_HEADERS entry %q: must be name=value", pair)
+		}
+
+		resolved, err := resolveSecretRefs(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid ASTROLAVOS_OTLP_HEADERS entry %q: %w", name, err)
+		}
+
+		if o.Headers == nil {
+			o.Headers = map[string]string{}
+		}
+
+		o.Headers[strings.TrimSpace(name)] = resolved
+	}
+
+	return o, nil
+}
+
+// pushGatewayMethods lists the supported push semantics: "push" replaces
+// the whole group and "add" only the pushed series.
+var pushGatewayMethods = []string{"push", "add"}
+
+// getCleanPushGateway reads the push gateway settings from the environment,
+// or returns nil when ASTROLAVOS_PROM_PUSH_GW is set to an empty value. The
+// default gateway, used when it is not set, is optional.
+// Without explicit grouping labels, metrics are grouped by the hostname as
+// instance and by the cluster external label, if set, so instances do not
+// overwrite each other's group.
+func getCleanPushGateway(externalLabels map[string]string) (*model.PushGateway, error) {
+	g := &model.PushGateway{
+		URL:      viper.GetString("prom_push_gw"),
+		Interval: viper.GetDuration("push_gw_interval"),
+		Retries:  viper.GetInt("push_gw_retries"),
+	}
+
+	// Viper falls back to the default for empty variables
+	url, explicit := os.LookupEnv("ASTROLAVOS_PROM_PUSH_GW")
+	if explicit && url == "" {
+		return nil, nil //nolint:nilnil // pushing is disabled
+	}
+
+	g.Optional = !explicit
+
+	grouping, err := parsePairs(viper.GetString("push_gw_grouping"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ASTROLAVOS_PUSH_GW_GROUPING value: %w", err)
+	}
+
+	for name := range grouping {
+		if !labelNamePattern.MatchString(name) || name == "job" {
+			return nil, fmt.Errorf("invalid ASTROLAVOS_PUSH_GW_GROUPING label name %q", name)
+		}
+	}
+
+	if grouping == nil {
+		grouping = map[string]string{}
+
+		if hostname, err := os.Hostname(); err == nil {
+			grouping["instance"] = hostname
+		}
+
+		if cluster, ok := externalLabels["cluster"]; ok {
+			grouping["cluster"] = cluster
+		}
+	}
+
+	g.Grouping = grouping
+
+	if g.Username, err = resolveSecretRefs(viper.GetString("push_gw_username")); err != nil {
+		return nil, fmt.Errorf("invalid ASTROLAVOS_PUSH_GW_USERNAME value: %w", err)
+	}
+
+	if g.Password, err = resolveSecretRefs(viper.GetString("push_gw_password")); err != nil {
+		return nil, fmt.Errorf("invalid ASTROLAVOS_PUSH_GW_PASSWORD value: %w", err)
+	}
+
+	if g.BearerToken, err = resolveSecretRefs(viper.GetString("push_gw_bearer_token")); err != nil {
+		return nil, fmt.Errorf("invalid ASTROLAVOS_PUSH_GW_BEARER_TOKEN value: %w", err)
+	}
+
+	if g.Username != "" && g.BearerToken != "" {
+		return nil, errors.New("ASTROLAVOS_PUSH_GW_USERNAME and ASTROLAVOS_PUSH_GW_BEARER_TOKEN are mutually exclusive")
+	}
+
+	if g.TLS, err = getCleanPushGatewayTLS(); err != nil {
+		return nil, err
+	}
+
+	method := strings.ToLower(viper.GetString("push_gw_method"))
+	if !slices.Contains(pushGatewayMethods, method) {
+		return nil, fmt.Errorf("invalid ASTROLAVOS_PUSH_GW_METHOD value %q: must be one of %v", method, pushGatewayMethods)
+	}
+
+	g.Add = method == "add"
+
+	if g.Interval < 0 {
+		return nil, fmt.Errorf("invalid ASTROLAVOS_PUSH_GW_INTERVAL value %q: must not be negative", viper.GetString("push_gw_interval"))
+	}
+
+	if g.Retries < 1 {
+		return nil, fmt.Errorf("invalid ASTROLAVOS_PUSH_GW_RETRIES value %q: must be at least 1", viper.GetString("push_gw_retries"))
+	}
+
+	return g, nil
+}
+
+// getCleanPushGatewayTLS builds the TLS settings of the push gateway
+// connection, or returns nil to keep the defaults.
+func getCleanPushGatewayTLS() (*tls.Config, error) {
+	caFile := viper.GetString("push_gw_ca_file")
+	certFile := viper.GetString("push_gw_cert_file")
+	keyFile := viper.GetString("push_gw_key_file")
+	insecure := viper.GetBool("push_gw_insecure_skip_verify")
+
+	if caFile == "" && certFile == "" && keyFile == "" && !insecure {
+		return nil, nil //nolint:nilnil // the default TLS settings apply
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: insecure} //nolint:gosec // skipping verification is opt-in
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read ASTROLAVOS_PUSH_GW_CA_FILE: %w", err)
+		}
+
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid certificates found in ASTROLAVOS_PUSH_GW_CA_FILE %s", caFile)
+		}
+	}
+
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("ASTROLAVOS_PUSH_GW_CERT_FILE and ASTROLAVOS_PUSH_GW_KEY_FILE must be set together")
+	}
+
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load push gateway client certificate: %w", err)
+		}
+
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// parsePairs parses comma separated name=value pairs, or returns nil when
+// there are none.
+func parsePairs(value string) (map[string]string, error) {
+	var pairs map[string]string
+
+	for pair := range strings.SplitSeq(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		name, v, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("entry %q must be name=value", pair)
+		}
+
+		if pairs == nil {
+			pairs = map[string]string{}
+		}
+
+		pairs[strings.TrimSpace(name)] = strings.TrimSpace(v)
+	}
+
+	return pairs, nil
+}
+
+// dogStatsDLatencyTypes lists the metric types latencies can be sent as.
+var dogStatsDLatencyTypes = []string{"distribution", "timing"}
+
+// getCleanDogStatsD reads the DogStatsD sink settings from the environment,
+// or returns nil when no address is set.
+func getCleanDogStatsD() (*model.DogStatsD, error) {
+	address := viper.GetString("dogstatsd_address")
+	if address == "" {
+		return nil, nil //nolint:nilnil // the DogStatsD sink is disabled
+	}
+
+	d := &model.DogStatsD{
+		Address:     address,
+		LatencyType: strings.ToLower(viper.GetString("dogstatsd_latency_type")),
+	}
+
+	if path, ok := strings.CutPrefix(address, "unix://"); ok {
+		if path == "" {
+			return nil, fmt.Errorf("invalid ASTROLAVOS_DOGSTATSD_ADDRESS value %q: missing socket path", address)
+		}
+	} else if _, _, err := net.SplitHostPort(address); err != nil {
+		return nil, fmt.Errorf("invalid ASTROLAVOS_DOGSTATSD_ADDRESS value %q: must be host:port or unix:///path", address)
+	}
+
+	if !slices.Contains(dogStatsDLatencyTypes, d.LatencyType) {
+		return nil, fmt.Errorf("invalid ASTROLAVOS_DOGSTATSD_LATENCY_TYPE value %q: must be one of %v", d.LatencyType, dogStatsDLatencyTypes)
+	}
+
+	return d, nil
+}
+
+// otlpURL validates a collector URL. An http endpoint without a path gets
+// the standard one of its signal.
+func otlpURL(endpoint, protocol, signalPath string) (string, error) {
+	if endpoint == "" {
+		return "", nil
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.New("must be an http:// or https:// URL")
+	}
+
+	if protocol == "http" && strings.Trim(u.Path, "/") == "" {
+		u.Path = signalPath
+	}
+
+	return u.String(), nil
+}
+
+// Reload re-reads the configuration file and returns its endpoints. Unlike
+// NewConfig, a single invalid endpoint rejects the whole file so the caller
+// can keep running the previous set. Only endpoints are reloaded; the other
+// settings require a restart, as does introducing an endpoint label name the
+// metric vectors were not created with.
+func (c *Config) Reload() ([]*model.Endpoint, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	r, err := getYamlConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load YAML config: %w", err)
+	}
+
+	if err = unknownFields(); err != nil {
+		if c.Strict {
+			return nil, err
+		}
+
+		for _, e := range Errors(err) {
+			log.Warnf("Ignoring configuration: %v", e)
+		}
+	}
+
+	endpoints, err := r.getStrictEndpoints()
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate endpoints: %w", err)
+	}
+
+	for _, name := range endpointLabelNames(endpoints) {
+		if !slices.Contains(c.LabelNames, name) {
+			return nil, fmt.Errorf("new endpoint label name %q requires a restart", name)
+		}
+	}
+
+	return endpoints, nil
+}
+
+// Watch calls onChange whenever the configuration file is written or, as with
+// a Kubernetes ConfigMap update, its symlink target is swapped. It blocks
+// until ctx is canceled.
+func (c *Config) Watch(ctx context.Context, onChange func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create config watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	file := filepath.Clean(c.file)
+
+	// Watch the directory to catch atomic saves and symlink swaps
+	if err = watcher.Add(filepath.Dir(file)); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", filepath.Dir(file), err)
+	}
+
+	realFile, _ := filepath.EvalSymlinks(file)
+
+	log.WithField("file", file).Info("Watching configuration for changes")
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+
+			currentFile, _ := filepath.EvalSymlinks(file)
+
+			written := filepath.Clean(event.Name) == file && (event.Has(fsnotify.Write) || event.Has(fsnotify.Create))
+			if written || (currentFile != "" && currentFile != realFile) {
+				realFile = currentFile
+
+				log.WithField("file", file).Debugf("Configuration changed: %s", event.Op)
+				onChange()
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+
+			log.WithError(err).Warn("Configuration watcher error")
+		}
+	}
+}
+
+// initViper initializes Viper configuration with defaults and env variable support.
+func initViper(path string) {
+	// Start over so only the given path is searched when loading again
+	viper.Reset()
+
+	// Set global options
+	viper.AddConfigPath(path)
+	viper.AddConfigPath(".")
+	viper.SetConfigName("config")
+	viper.SetConfigType("yaml")
+	viper.SetEnvPrefix("astrolavos")
+
+	// Set defaults for environment variables
+	viper.SetDefault("APP_PORT", "3000")
+	viper.SetDefault("LOG_LEVEL", "DEBUG")
+	viper.SetDefault("PROM_PUSH_GW", "localhost")
+	viper.SetDefault("PUSH_GW_GROUPING", "")
+	viper.SetDefault("PUSH_GW_USERNAME", "")
+	viper.SetDefault("PUSH_GW_PASSWORD", "")
+	viper.SetDefault("PUSH_GW_BEARER_TOKEN", "")
+	viper.SetDefault("PUSH_GW_CA_FILE", "")
+	viper.SetDefault("PUSH_GW_CERT_FILE", "")
+	viper.SetDefault("PUSH_GW_KEY_FILE", "")
+	viper.SetDefault("PUSH_GW_INSECURE_SKIP_VERIFY", false)
+	viper.SetDefault("PUSH_GW_METHOD", "push")
+	viper.SetDefault("PUSH_GW_INTERVAL", "0s")
+	viper.SetDefault("PUSH_GW_RETRIES", 3)
+	viper.SetDefault("MAX_PAYLOAD_SIZE", 0) // 0 means use handler's default (10MB)
+	viper.SetDefault("RESULTS_WINDOW", "5m")
+	viper.SetDefault("RESULTS_HISTORY", 100)
+	viper.SetDefault("OTLP_ENDPOINT", "")
+	viper.SetDefault("OTLP_TRACES_ENDPOINT", "")
+	viper.SetDefault("OTLP_PROTOCOL", "grpc")
+	viper.SetDefault("OTLP_HEADERS", "")
+	viper.SetDefault("OTLP_INTERVAL", "30s")
+	viper.SetDefault("DOGSTATSD_ADDRESS", "")
+	viper.SetDefault("DOGSTATSD_LATENCY_TYPE", "distribution")
+	viper.SetDefault("STRICT", false)
+
+	// Enable VIPER to read Environment Variables
+	viper.AutomaticEnv()
+}
+
+// getYamlConfig reads and parses the YAML configuration file.
+func getYamlConfig() (*YamlEndpoints, error) {
+	if err := viper.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("error reading config file: %w", err)
+	}
+
+	var ye YamlEndpoints
+
+	if err := viper.Unmarshal(&ye); err != nil {
+		return nil, fmt.Errorf("unable to decode config YAML into struct: %w", err)
+	}
+
+	return &ye, nil
+}
+
+// errUnknownField is reported for keys of the configuration file that
+// match no setting, as viper.Unmarshal ignores them.
+var errUnknownField = errors.New("unknown field")
+
+// unknownFields returns an error locating every key of the configuration
+// file that matches no setting, such as a misspelled one.
+func unknownFields() error {
+	// Read the file alone, as the defaults of the global instance are no
+	// settings of the file
+	v := viper.New()
+	v.SetConfigFile(viper.ConfigFileUsed())
+
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+
+	var md mapstructure.Metadata
+
+	// Name the fields in paths as in the file, matching keys the same way
+	decoderConfig := func(c *mapstructure.DecoderConfig) {
+		c.Metadata = &md
+		c.TagName = "yaml"
+	}
+
+	if err := v.Unmarshal(&YamlEndpoints{}, decoderConfig); err != nil {
+		return fmt.Errorf("unable to decode config YAML into struct: %w", err)
+	}
+
+	slices.Sort(md.Unused)
+
+	errs := make([]error, 0, len(md.Unused))
+	for _, key := range md.Unused {
+		errs = append(errs, &FieldError{Field: key, Err: errUnknownField})
+	}
+
+	return errors.Join(errs...)
 }
@@ -1,6 +1,12 @@
 package config //nolint:testpackage // tests access unexported methods for thorough validation
 
 import (
+	"context"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 )
@@ -151,6 +157,44 @@ func TestGetCleanEndpoints_MixedValid(t *testing.T) {
 	}
 }
 
+func TestGetCleanEndpoints_Duplicates(t *testing.T) {
+	ye := &YamlEndpoints{
+		Endpoints: []YamlEndpoint{
+			{Domain: "example.com", Prober: "dns", RecordType: "A"},
+			{Domain: "example.com", Prober: "dns", RecordType: "AAAA"},
+			{Domain: "example.com", Prober: "dns", RecordType: "AAAA", Tag: "ipv6"},
+			{Domain: "example.com", Method: "GET"},
+			{Domain: "example.com", Method: "HEAD"},
+		},
+	}
+
+	// The record type and method are not part of the series, so the second
+	// endpoint of each pair would overwrite the series of the first
+	_, err := ye.getStrictEndpoints()
+
+	var fields []string
+
+	for _, e := range Errors(err) {
+		var fe *FieldError
+		if errors.As(e, &fe) {
+			fields = append(fields, fe.Field)
+		}
+	}
+
+	if want := []string{"endpoints[1].tag", "endpoints[4].tag"}; !slices.Equal(fields, want) {
+		t.Errorf("expected errors for %v, got %v", want, fields)
+	}
+
+	endpoints, err := ye.getCleanEndpoints()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(endpoints) != 3 || endpoints[1].RecordType != "AAAA" || endpoints[1].Tag != "ipv6" {
+		t.Errorf("expected the duplicates to be skipped, got %+v", endpoints)
+	}
+}
+
 func TestGetCleanEndpoint_ReuseConnection(t *testing.T) {
 	ye := &YamlEndpoint{
 		Domain:          "example.com",
@@ -182,3 +226,779 @@ func TestGetCleanEndpoint_SkipTLSVerification(t *testing.T) {
 		t.Error("expected SkipTLSVerification to be true")
 	}
 }
+
+func TestGetCleanEndpoint_DNS(t *testing.T) {
+	timeout := 2 * time.Second
+
+	ye := &YamlEndpoint{
+		Domain:          "example.com",
+		Prober:          "dns",
+		RecordType:      "aaaa",
+		Resolver:        "10.0.0.10",
+		ExpectedAnswers: []string{"2001:db8::1"},
+		AnswerPattern:   `^2001:db8::`,
+		Timeout:         &timeout,
+	}
+
+	ep, err := ye.getCleanEndpoint()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if ep.URI != "example.com" {
+		t.Errorf("expected URI 'example.com', got %q", ep.URI)
+	}
+
+	if ep.RecordType != "AAAA" {
+		t.Errorf("expected record type 'AAAA', got %q", ep.RecordType)
+	}
+
+	if ep.Resolver != "10.0.0.10:53" {
+		t.Errorf("expected resolver '10.0.0.10:53', got %q", ep.Resolver)
+	}
+
+	if ep.AnswerPattern == nil || !ep.AnswerPattern.MatchString("2001:db8::1") {
+		t.Error("expected compiled answer pattern")
+	}
+
+	if ep.Timeout != timeout {
+		t.Errorf("expected timeout %v, got %v", timeout, ep.Timeout)
+	}
+}
+
+func TestGetCleanEndpoint_DNSDefaults(t *testing.T) {
+	ye := &YamlEndpoint{
+		Domain: "example.com",
+		Prober: "dns",
+	}
+
+	ep, err := ye.getCleanEndpoint()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if ep.RecordType != "A" {
+		t.Errorf("expected default record type 'A', got %q", ep.RecordType)
+	}
+
+	if ep.Resolver != "" {
+		t.Errorf("expected system resolver, got %q", ep.Resolver)
+	}
+
+	if ep.Timeout != 10*time.Second {
+		t.Errorf("expected the default tcpTimeout as timeout, got %v", ep.Timeout)
+	}
+}
+
+func TestGetCleanEndpoint_DNSInvalidRecordType(t *testing.T) {
+	ye := &YamlEndpoint{
+		Domain:     "example.com",
+		Prober:     "dns",
+		RecordType: "MX",
+	}
+
+	_, err := ye.getCleanEndpoint()
+	if err == nil {
+		t.Fatal("expected error for unsupported record type")
+	}
+}
+
+func TestGetCleanEndpoint_DNSInvalidPattern(t *testing.T) {
+	ye := &YamlEndpoint{
+		Domain:        "example.com",
+		Prober:        "dns",
+		AnswerPattern: "(",
+	}
+
+	_, err := ye.getCleanEndpoint()
+	if err == nil {
+		t.Fatal("expected error for invalid answer pattern")
+	}
+}
+
+func TestGetCleanEndpoint_TLSDefaultPort(t *testing.T) {
+	ye := &YamlEndpoint{
+		Domain:     "example.com",
+		Prober:     "tls",
+		ServerName: "www.example.com",
+	}
+
+	ep, err := ye.getCleanEndpoint()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if ep.URI != "example.com:443" {
+		t.Errorf("expected URI 'example.com:443', got %q", ep.URI)
+	}
+
+	if ep.ServerName != "www.example.com" {
+		t.Errorf("expected server name 'www.example.com', got %q", ep.ServerName)
+	}
+}
+
+func TestGetCleanEndpoint_TLSMissingCAFile(t *testing.T) {
+	ye := &YamlEndpoint{
+		Domain: "example.com:8443",
+		Prober: "tls",
+		CAFile: "/nonexistent/ca.pem",
+	}
+
+	_, err := ye.getCleanEndpoint()
+	if err == nil {
+		t.Fatal("expected error for missing CA bundle")
+	}
+}
+
+func TestGetCleanEndpoint_HTTPRequestOptions(t *testing.T) {
+	t.Setenv("ASTROLAVOS_TEST_TOKEN", "s3cret")
+
+	secretFile := filepath.Join(t.TempDir(), "api-key")
+	if err := os.WriteFile(secretFile, []byte("key-from-file\n"), 0o600); err != nil {
+		t.Fatalf("failed to write secret file: %v", err)
+	}
+
+	ye := &YamlEndpoint{
+		Domain: "example.com/health",
+		Method: "post",
+		Headers: map[string]string{
+			"authorization": "Bearer ${env:ASTROLAVOS_TEST_TOKEN}",
+			"x-api-key":     "${file:" + secretFile + "}",
+		},
+		Body: `{"ping":true}`,
+	}
+
+	ep, err := ye.getCleanEndpoint()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if ep.Method != "POST" {
+		t.Errorf("expected method 'POST', got %q", ep.Method)
+	}
+
+	if ep.Headers["authorization"] != "Bearer s3cret" {
+		t.Errorf("expected env reference to be resolved, got %q", ep.Headers["authorization"])
+	}
+
+	if ep.Headers["x-api-key"] != "key-from-file" {
+		t.Errorf("expected file reference to be resolved, got %q", ep.Headers["x-api-key"])
+	}
+
+	if string(ep.Body) != `{"ping":true}` {
+		t.Errorf("unexpected body %q", ep.Body)
+	}
+}
+
+func TestGetCleanEndpoint_HTTPDefaultMethod(t *testing.T) {
+	ye := &YamlEndpoint{Domain: "example.com"}
+
+	ep, err := ye.getCleanEndpoint()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if ep.Method != "GET" {
+		t.Errorf("expected default method 'GET', got %q", ep.Method)
+	}
+}
+
+func TestGetCleanEndpoint_HTTPInvalidOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		ye   YamlEndpoint
+	}{
+		{"invalid method", YamlEndpoint{Domain: "example.com", Method: "FETCH"}},
+		{"body and bodyFile", YamlEndpoint{Domain: "example.com", Body: "x", BodyFile: "/tmp/body"}},
+		{"missing body file", YamlEndpoint{Domain: "example.com", BodyFile: "/nonexistent/body"}},
+		{"unset env reference", YamlEndpoint{Domain: "example.com", Headers: map[string]string{"authorization": "${env:ASTROLAVOS_UNSET_VARIABLE}"}}},
+		{"missing secret file", YamlEndpoint{Domain: "example.com", Headers: map[string]string{"authorization": "${file:/nonexistent/token}"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.ye.getCleanEndpoint(); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestGetCleanEndpoint_Expect(t *testing.T) {
+	ye := &YamlEndpoint{
+		Domain: "example.com",
+		Expect: &YamlExpect{
+			StatusCodes: []string{"200", "3xx", "400-404"},
+			BodyRegex:   `"status":\s*"up"`,
+			JSONPath:    []YamlJSONPath{{Path: "$.status", Equals: "up"}},
+			Headers:     map[string]string{"content-type": "^application/json"},
+			MaxBodySize: 2048,
+		},
+	}
+
+	ep, err := ye.getCleanEndpoint()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []struct{ min, max int }{{200, 200}, {300, 399}, {400, 404}}
+	if len(ep.Expect.StatusCodes) != len(expected) {
+		t.Fatalf("expected %d status code ranges, got %d", len(expected), len(ep.Expect.StatusCodes))
+	}
+
+	for i, r := range ep.Expect.StatusCodes {
+		if r.Min != expected[i].min || r.Max != expected[i].max {
+			t.Errorf("range %d: expected %d-%d, got %d-%d", i, expected[i].min, expected[i].max, r.Min, r.Max)
+		}
+	}
+
+	if ep.Expect.MaxBodySize != 2048 {
+		t.Errorf("expected maxBodySize 2048, got %d", ep.Expect.MaxBodySize)
+	}
+}
+
+func TestGetCleanEndpoint_ExpectInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		expect YamlExpect
+	}{
+		{"status code out of range", YamlExpect{StatusCodes: []string{"700"}}},
+		{"status code class", YamlExpect{StatusCodes: []string{"6xx"}}},
+		{"reversed range", YamlExpect{StatusCodes: []string{"299-200"}}},
+		{"body regex", YamlExpect{BodyRegex: "("}},
+		{"json path", YamlExpect{JSONPath: []YamlJSONPath{{Path: "status", Equals: "up"}}}},
+		{"header pattern", YamlExpect{Headers: map[string]string{"content-type": "("}}},
+		{"negative max body size", YamlExpect{MaxBodySize: -1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ye := &YamlEndpoint{Domain: "example.com", Expect: &tt.expect}
+			if _, err := ye.getCleanEndpoint(); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestGetCleanEndpoint_Timeouts(t *testing.T) {
+	ye := &YamlEndpoint{Domain: "example.com"}
+
+	ep, err := ye.getCleanEndpoint()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if ep.Timeout != 10*time.Second {
+		t.Errorf("expected default timeout 10s, got %v", ep.Timeout)
+	}
+
+	timeout := 2 * time.Second
+	ye = &YamlEndpoint{
+		Domain:        "example.com",
+		Timeout:       &timeout,
+		PhaseTimeouts: &YamlPhaseTimeouts{DNS: 100 * time.Millisecond, FirstByte: time.Second},
+	}
+
+	ep, err = ye.getCleanEndpoint()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if ep.Timeout != timeout || ep.PhaseTimeouts.DNS != 100*time.Millisecond || ep.PhaseTimeouts.FirstByte != time.Second {
+		t.Errorf("unexpected timeouts: %v %+v", ep.Timeout, ep.PhaseTimeouts)
+	}
+}
+
+func TestGetCleanEndpoint_PhaseTimeoutExceedsTotal(t *testing.T) {
+	timeout := time.Second
+	ye := &YamlEndpoint{
+		Domain:        "example.com",
+		Timeout:       &timeout,
+		PhaseTimeouts: &YamlPhaseTimeouts{TLS: 2 * time.Second},
+	}
+
+	if _, err := ye.getCleanEndpoint(); err == nil {
+		t.Fatal("expected error for phase timeout exceeding the total timeout")
+	}
+}
+
+func TestGetStrictEndpoints_RejectsInvalid(t *testing.T) {
+	ye := &YamlEndpoints{
+		Endpoints: []YamlEndpoint{
+			{Domain: "valid.com"},
+			{Domain: "invalid.com", Prober: "ftp"},
+		},
+	}
+
+	if _, err := ye.getStrictEndpoints(); err == nil {
+		t.Fatal("expected error when any endpoint is invalid")
+	}
+}
+
+func TestGetCleanEndpoint_FieldErrors(t *testing.T) {
+	interval := 500 * time.Millisecond
+	ye := &YamlEndpoint{Interval: &interval, Method: "FETCH", Labels: map[string]string{"domain": "x"}}
+
+	_, err := ye.getCleanEndpoint()
+
+	var fields []string
+
+	for _, e := range Errors(err) {
+		var fe *FieldError
+		if !errors.As(e, &fe) {
+			t.Fatalf("expected a field error, got %v", e)
+		}
+
+		fields = append(fields, fe.Field)
+	}
+
+	if want := []string{"domain", "interval", "labels", "method"}; !slices.Equal(fields, want) {
+		t.Errorf("expected errors for %v, got %v", want, fields)
+	}
+}
+
+// writeStrictTestConfig writes a configuration with an unknown field and an
+// invalid endpoint next to a valid one.
+func writeStrictTestConfig(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	content := `endpoints:
+  - domain: one.example.com
+    reuseConnections: true
+  - domain: two.example.com
+    prober: ftp
+  - domain: three.example.com:443
+    prober: tcp
+`
+
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	return dir
+}
+
+func TestValidate(t *testing.T) {
+	_, err := Validate(writeStrictTestConfig(t))
+
+	var got []string
+	for _, e := range Errors(err) {
+		got = append(got, e.Error())
+	}
+
+	want := []string{
+		"endpoints[0].reuseconnections: unknown field",
+		"endpoints[1].prober: invalid prober type 'ftp': must be one of ['tcp', 'httpTrace', 'dns', 'tls']",
+	}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("expected errors %q, got %q", want, got)
+	}
+}
+
+func TestNewConfig_Strict(t *testing.T) {
+	dir := writeStrictTestConfig(t)
+
+	cfg, err := NewConfig(dir)
+	if err != nil {
+		t.Fatalf("expected invalid endpoints to be skipped, got %v", err)
+	}
+
+	if len(cfg.Endpoints) != 2 || cfg.Strict {
+		t.Errorf("expected 2 endpoints in lenient mode, got %d", len(cfg.Endpoints))
+	}
+
+	t.Setenv("ASTROLAVOS_STRICT", "true")
+
+	if _, err = NewConfig(dir); err == nil {
+		t.Fatal("expected strict mode to reject the configuration")
+	}
+}
+
+func TestConfig_ReloadAndWatch(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+
+	writeConfig := func(content string) {
+		t.Helper()
+
+		if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+	}
+
+	writeConfig("endpoints:\n  - domain: one.example.com\n")
+
+	cfg, err := NewConfig(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changed := make(chan struct{}, 10)
+
+	go func() {
+		_ = cfg.Watch(ctx, func() { changed <- struct{}{} })
+	}()
+
+	// Give the watcher time to register before writing
+	time.Sleep(100 * time.Millisecond)
+	writeConfig("endpoints:\n  - domain: one.example.com\n  - domain: two.example.com:443\n    prober: tcp\n")
+
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for config change notification")
+	}
+
+	endpoints, err := cfg.Reload()
+	if err != nil {
+		t.Fatalf("unexpected reload error: %v", err)
+	}
+
+	if len(endpoints) != 2 || endpoints[1].URI != "two.example.com:443" {
+		t.Fatalf("unexpected reloaded endpoints: %+v", endpoints)
+	}
+
+	writeConfig("endpoints:\n  - domain: one.example.com\n  - domain: two.example.com\n    prober: ftp\n")
+
+	if _, err = cfg.Reload(); err == nil {
+		t.Fatal("expected reload of an invalid config to fail")
+	}
+}
+
+func TestGetCleanEndpoint_Labels(t *testing.T) {
+	ye := &YamlEndpoint{
+		Domain: "example.com",
+		Labels: map[string]string{"team": "payments", "region": "eu"},
+	}
+
+	ep, err := ye.getCleanEndpoint()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if ep.Labels["team"] != "payments" || ep.Labels["region"] != "eu" {
+		t.Errorf("unexpected labels: %v", ep.Labels)
+	}
+
+	tests := []map[string]string{
+		{"1team": "payments"},
+		{"team-name": "payments"},
+		{"__name": "payments"},
+		{"domain": "payments"},
+		{"prober_type": "payments"},
+	}
+
+	for _, labels := range tests {
+		ye = &YamlEndpoint{Domain: "example.com", Labels: labels}
+		if _, err = ye.getCleanEndpoint(); err == nil {
+			t.Errorf("expected error for labels %v", labels)
+		}
+	}
+}
+
+func TestEndpointLabelNames(t *testing.T) {
+	ye := &YamlEndpoints{
+		Endpoints: []YamlEndpoint{
+			{Domain: "a.com", Labels: map[string]string{"team": "payments"}},
+			{Domain: "b.com", Labels: map[string]string{"region": "eu", "team": "search"}},
+			{Domain: "c.com"},
+		},
+	}
+
+	endpoints, err := ye.getCleanEndpoints()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	names := endpointLabelNames(endpoints)
+	if len(names) != 2 || names[0] != "region" || names[1] != "team" {
+		t.Errorf("expected [region team], got %v", names)
+	}
+}
+
+func TestGetCleanMesh(t *testing.T) {
+	ym := &YamlMesh{Discovery: "astrolavos.monitoring.svc", Source: "eu-1"}
+
+	m, err := ym.getCleanMesh(3000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if m.RecordType != "A" || m.Port != 3000 || m.Tag != "mesh" || m.Retries != 1 {
+		t.Errorf("unexpected defaults: %+v", m)
+	}
+
+	if m.RefreshInterval != 30*time.Second || m.Interval != 5*time.Second || m.Timeout != 10*time.Second {
+		t.Errorf("unexpected default intervals: %+v", m)
+	}
+
+	short := 100 * time.Millisecond
+
+	tests := []*YamlMesh{
+		{},
+		{Discovery: "a.svc", RecordType: "MX"},
+		{Discovery: "a.svc", Port: 70000},
+		{Discovery: "a.svc", RefreshInterval: &short},
+		{Discovery: "a.svc", PayloadSize: -1},
+	}
+
+	for _, tt := range tests {
+		if _, err = tt.getCleanMesh(3000); err == nil {
+			t.Errorf("expected error for mesh %+v", tt)
+		}
+	}
+}
+
+func TestGetCleanEndpoints_MeshOnly(t *testing.T) {
+	ye := &YamlEndpoints{Mesh: &YamlMesh{Discovery: "astrolavos.monitoring.svc"}}
+
+	endpoints, err := ye.getCleanEndpoints()
+	if err != nil || len(endpoints) != 0 {
+		t.Errorf("expected no endpoints and no error in mesh-only mode, got %v, %v", endpoints, err)
+	}
+}
+
+func TestGetCleanAlerting(t *testing.T) {
+	t.Setenv("ASTROLAVOS_TEST_SLACK_URL", "https://hooks.slack.com/services/T0/B0/secret")
+
+	ya := &YamlAlerting{Webhooks: []YamlWebhook{
+		{URL: "${env:ASTROLAVOS_TEST_SLACK_URL}", Format: "Slack"},
+		{URL: "http://alerts.internal/hook", Template: `{"text": "{{.Endpoint}} is {{.State}}"}`},
+	}}
+
+	a, err := ya.getCleanAlerting()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if a.FailureThreshold != 3 || a.SuccessThreshold != 1 {
+		t.Errorf("unexpected default thresholds: %+v", a)
+	}
+
+	slack := a.Webhooks[0]
+	if slack.URL != "https://hooks.slack.com/services/T0/B0/secret" || slack.Format != "slack" ||
+		slack.Retries != 3 || slack.Timeout != 10*time.Second {
+		t.Errorf("unexpected webhook: %+v", slack)
+	}
+
+	if a.Webhooks[1].Format != "json" || a.Webhooks[1].Template == nil {
+		t.Errorf("expected templated json webhook, got %+v", a.Webhooks[1])
+	}
+
+	zero := 0
+
+	tests := []*YamlAlerting{
+		{},
+		{FailureThreshold: &zero, Webhooks: []YamlWebhook{{URL: "http://a"}}},
+		{Webhooks: []YamlWebhook{{URL: "alerts.internal"}}},
+		{Webhooks: []YamlWebhook{{URL: "http://a", Format: "xml"}}},
+		{Webhooks: []YamlWebhook{{URL: "http://a", Template: "{{.Endpoint"}}},
+		{Webhooks: []YamlWebhook{{URL: "http://a", Retries: &zero}}},
+		{Webhooks: []YamlWebhook{{URL: "${env:ASTROLAVOS_TEST_UNSET_URL}"}}},
+	}
+
+	for _, tt := range tests {
+		if _, err = tt.getCleanAlerting(); err == nil {
+			t.Errorf("expected error for alerting %+v", tt)
+		}
+	}
+}
+
+func TestGetCleanSLO(t *testing.T) {
+	interval := 5 * time.Second
+
+	s, err := (&YamlSLO{LatencyThreshold: 300 * time.Millisecond, SuccessRatio: 0.999}).getCleanSLO(interval)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if s.Window != time.Hour || s.LatencyPercentile != 95 || s.LatencyThreshold != 300*time.Millisecond || s.SuccessRatio != 0.999 {
+		t.Errorf("unexpected slo: %+v", s)
+	}
+
+	short := 5 * time.Second
+	hundred := 100.0
+
+	tests := []*YamlSLO{
+		{},
+		{Window: &short, SuccessRatio: 0.99},
+		{LatencyThreshold: time.Second, LatencyPercentile: &hundred},
+		{SuccessRatio: 99.9},
+		{LatencyThreshold: -time.Second},
+	}
+
+	for _, tt := range tests {
+		if _, err = tt.getCleanSLO(interval); err == nil {
+			t.Errorf("expected an error for %+v", tt)
+		}
+	}
+}
+
+func TestGetCleanPushGateway(t *testing.T) {
+	initViper(t.TempDir())
+
+	hostname, _ := os.Hostname()
+
+	g, err := getCleanPushGateway(map[string]string{"cluster": "eu-1", "region": "eu"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if g.URL != "localhost" || !g.Optional || g.Add || g.Interval != 0 || g.Retries != 3 || g.TLS != nil {
+		t.Errorf("unexpected defaults: %+v", g)
+	}
+
+	if want := map[string]string{"cluster": "eu-1", "instance": hostname}; !maps.Equal(g.Grouping, want) {
+		t.Errorf("expected grouping %v, got %v", want, g.Grouping)
+	}
+
+	t.Setenv("ASTROLAVOS_PROM_PUSH_GW", "")
+
+	if g, err := getCleanPushGateway(nil); err != nil || g != nil {
+		t.Errorf("expected an empty gateway to disable pushing, got %+v, %v", g, err)
+	}
+
+	t.Setenv("ASTROLAVOS_PROM_PUSH_GW", "https://pushgateway.monitoring:9091")
+	t.Setenv("ASTROLAVOS_TEST_PUSH_GW_TOKEN", "s3cret")
+	t.Setenv("ASTROLAVOS_PUSH_GW_GROUPING", "team=net, instance=cron")
+	t.Setenv("ASTROLAVOS_PUSH_GW_BEARER_TOKEN", "${env:ASTROLAVOS_TEST_PUSH_GW_TOKEN}")
+	t.Setenv("ASTROLAVOS_PUSH_GW_METHOD", "ADD")
+	t.Setenv("ASTROLAVOS_PUSH_GW_INTERVAL", "1m")
+	t.Setenv("ASTROLAVOS_PUSH_GW_INSECURE_SKIP_VERIFY", "true")
+
+	g, err = getCleanPushGateway(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := map[string]string{"team": "net", "instance": "cron"}; !maps.Equal(g.Grouping, want) {
+		t.Errorf("expected grouping %v, got %v", want, g.Grouping)
+	}
+
+	if g.BearerToken != "s3cret" || g.Optional || !g.Add || g.Interval != time.Minute || g.TLS == nil || !g.TLS.InsecureSkipVerify {
+		t.Errorf("unexpected settings: %+v", g)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"ASTROLAVOS_PUSH_GW_GROUPING", "job=other"},
+		{"ASTROLAVOS_PUSH_GW_GROUPING", "cluster"},
+		{"ASTROLAVOS_PUSH_GW_USERNAME", "astrolavos"},
+		{"ASTROLAVOS_PUSH_GW_METHOD", "replace"},
+		{"ASTROLAVOS_PUSH_GW_INTERVAL", "-1m"},
+		{"ASTROLAVOS_PUSH_GW_RETRIES", "0"},
+		{"ASTROLAVOS_PUSH_GW_CA_FILE", "/nonexistent/ca.pem"},
+		{"ASTROLAVOS_PUSH_GW_CERT_FILE", "/nonexistent/cert.pem"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+"="+tt.value, func(t *testing.T) {
+			t.Setenv(tt.name, tt.value)
+
+			if _, err := getCleanPushGateway(nil); err == nil {
+				t.Errorf("expected an error for %s=%s", tt.name, tt.value)
+			}
+		})
+	}
+}
+
+func TestGetCleanDogStatsD(t *testing.T) {
+	initViper(t.TempDir())
+
+	d, err := getCleanDogStatsD()
+	if err != nil || d != nil {
+		t.Fatalf("expected DogStatsD to be disabled by default, got %+v, %v", d, err)
+	}
+
+	t.Setenv("ASTROLAVOS_DOGSTATSD_ADDRESS", "unix:///var/run/datadog/dsd.socket")
+
+	d, err = getCleanDogStatsD()
+	if err != nil || d.Address != "unix:///var/run/datadog/dsd.socket" || d.LatencyType != "distribution" {
+		t.Errorf("unexpected DogStatsD settings: %+v, %v", d, err)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"ASTROLAVOS_DOGSTATSD_ADDRESS", "datadog-agent"},
+		{"ASTROLAVOS_DOGSTATSD_ADDRESS", "unix://"},
+		{"ASTROLAVOS_DOGSTATSD_LATENCY_TYPE", "histogram"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+"="+tt.value, func(t *testing.T) {
+			t.Setenv(tt.name, tt.value)
+
+			if _, err := getCleanDogStatsD(); err == nil {
+				t.Errorf("expected an error for %s=%s", tt.name, tt.value)
+			}
+		})
+	}
+}
+
+func TestGetCleanOTLP(t *testing.T) {
+	initViper(t.TempDir())
+
+	o, err := getCleanOTLP()
+	if err != nil || o != nil {
+		t.Fatalf("expected OTLP to be disabled by default, got %+v, %v", o, err)
+	}
+
+	t.Setenv("ASTROLAVOS_TEST_OTLP_TOKEN", "s3cret")
+	t.Setenv("ASTROLAVOS_OTLP_ENDPOINT", "http://collector:4318")
+	t.Setenv("ASTROLAVOS_OTLP_PROTOCOL", "HTTP")
+	t.Setenv("ASTROLAVOS_OTLP_HEADERS", "authorization=Bearer ${env:ASTROLAVOS_TEST_OTLP_TOKEN}, x-tenant=astrolavos")
+
+	o, err = getCleanOTLP()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if o.Endpoint != "http://collector:4318/v1/metrics" || o.Protocol != "http" || o.Interval != 30*time.Second {
+		t.Errorf("unexpected OTLP settings: %+v", o)
+	}
+
+	if o.Headers["authorization"] != "Bearer s3cret" || o.Headers["x-tenant"] != "astrolavos" {
+		t.Errorf("unexpected headers: %v", o.Headers)
+	}
+
+	if o.TracesEndpoint != "" {
+		t.Errorf("expected traces not to be exported by default, got %q", o.TracesEndpoint)
+	}
+
+	t.Setenv("ASTROLAVOS_OTLP_TRACES_ENDPOINT", "http://collector:4318")
+
+	if o, err = getCleanOTLP(); err != nil || o.TracesEndpoint != "http://collector:4318/v1/traces" {
+		t.Errorf("unexpected traces endpoint: %+v, %v", o, err)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"ASTROLAVOS_OTLP_PROTOCOL", "thrift"},
+		{"ASTROLAVOS_OTLP_ENDPOINT", "collector:4317"},
+		{"ASTROLAVOS_OTLP_TRACES_ENDPOINT", "collector:4317"},
+		{"ASTROLAVOS_OTLP_INTERVAL", "0s"},
+		{"ASTROLAVOS_OTLP_HEADERS", "authorization"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.name, tt.value)
+
+			if _, err := getCleanOTLP(); err == nil {
+				t.Errorf("expected an error for %s=%s", tt.name, tt.value)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+// Package dashboard serves the built-in web dashboard. It is a static page
+// embedded in the binary that renders the agent's own status and results
+// APIs, so it works without Grafana or any external asset.
+package dashboard
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed static
+var static embed.FS
+
+// Handler returns a handler serving the dashboard, to be mounted under
+// prefix, e.g. "/dashboard/".
+func Handler(prefix string) http.Handler {
+	files, err := fs.Sub(static, "static")
+	if err != nil {
+		// The embedded directory is fixed at build time
+		panic(err)
+	}
+
+	return http.StripPrefix(prefix, http.FileServerFS(files))
+}
@@ -0,0 +1,51 @@
+package dashboard_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dntosas/astrolavos/internal/dashboard"
+)
+
+func TestHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/dashboard/", dashboard.Handler("/dashboard/"))
+
+	tests := []struct {
+		path        string
+		contentType string
+		contains    string
+	}{
+		{"/dashboard/", "text/html", `<script src="app.js">`},
+		{"/dashboard/app.js", "javascript", "../api/v1/stream"},
+		{"/dashboard/style.css", "text/css", ".sparkline"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected status %d, got %d", tt.path, http.StatusOK, w.Code)
+
+			continue
+		}
+
+		if ct := w.Header().Get("Content-Type"); !strings.Contains(ct, tt.contentType) {
+			t.Errorf("%s: unexpected Content-Type %q", tt.path, ct)
+		}
+
+		if !strings.Contains(w.Body.String(), tt.contains) {
+			t.Errorf("%s: expected body to contain %q", tt.path, tt.contains)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/dashboard/missing.js", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected missing asset to return %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
@@ -0,0 +1,247 @@
+// Astrolavos dashboard: renders /status and /api/v1/results, then follows
+// /api/v1/stream for live updates. Paths are relative so the dashboard also
+// works behind a proxy prefix.
+"use strict";
+
+const HISTORY = 60;
+const REFRESH_MS = 60000;
+
+const PHASE_COLORS = {
+  DNS: "#8e7cc3",
+  Connect: "#e69138",
+  TLS: "#c27ba0",
+  Server: "#3d85c6",
+  Transfer: "#6aa84f",
+  Query: "#3d85c6",
+  Other: "#999999",
+};
+
+// endpoints maps an endpoint key to its status entry and recent results,
+// newest first.
+let endpoints = new Map();
+
+function key(proberType, uri, tag) {
+  return proberType + "|" + uri + "|" + (tag || "");
+}
+
+function esc(s) {
+  return String(s ?? "").replace(/[&<>"']/g, (c) => ({
+    "&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;", "'": "&#39;",
+  })[c]);
+}
+
+function ms(seconds) {
+  const v = seconds * 1000;
+  return (v >= 100 ? v.toFixed(0) : v.toFixed(1)) + " ms";
+}
+
+function ago(time) {
+  if (!time) return "never";
+  const s = Math.max(0, Math.round((Date.now() - new Date(time)) / 1000));
+  if (s < 60) return s + "s ago";
+  if (s < 3600) return Math.round(s / 60) + "m ago";
+  return Math.round(s / 3600) + "h ago";
+}
+
+async function getJSON(path) {
+  const resp = await fetch(path, { cache: "no-store" });
+  if (!resp.ok) throw new Error(path + ": " + resp.status);
+  return resp.json();
+}
+
+async function load() {
+  const status = await getJSON("../status");
+  document.getElementById("version").textContent = "version " + status.version;
+
+  const next = new Map();
+
+  await Promise.all(status.endpoints.map(async (e) => {
+    const k = key(e.prober_type, e.uri, e.tag);
+    const entry = { info: e, results: [], lastSuccess: e.last_success };
+
+    if (e.last_result) {
+      const params = new URLSearchParams({
+        endpoint: e.uri, prober_type: e.prober_type, tag: e.tag || "", limit: HISTORY,
+      });
+
+      try {
+        const resp = await getJSON("../api/v1/results?" + params);
+        entry.results = resp.endpoints.length ? resp.endpoints[0].results : [];
+      } catch (err) {
+        entry.results = [e.last_result];
+      }
+    }
+
+    next.set(k, entry);
+  }));
+
+  endpoints = new Map([...next.entries()].sort(([a], [b]) => a.localeCompare(b)));
+  render();
+}
+
+function state(entry) {
+  const last = entry.results[0];
+  if (!last) return "unknown";
+  return last.success ? "up" : "down";
+}
+
+// phases splits a result into consecutive phases like httpstat does.
+function phases(r, proberType) {
+  const out = [];
+  let accounted = 0;
+
+  for (const [name, v] of [["DNS", r.dns_seconds], ["Connect", r.connect_seconds], ["TLS", r.tls_seconds]]) {
+    if (v > 0) {
+      out.push([name, v]);
+      accounted += v;
+    }
+  }
+
+  if (r.got_conn_seconds > 0) {
+    out.push(["Server", Math.max(0, r.first_byte_seconds - r.got_conn_seconds)]);
+    out.push(["Transfer", Math.max(0, r.total_seconds - r.first_byte_seconds)]);
+  } else if (r.total_seconds - accounted > 0.00005) {
+    out.push([proberType === "dns" ? "Query" : "Other", r.total_seconds - accounted]);
+  }
+
+  return out;
+}
+
+function sparkline(results) {
+  const w = 240, h = 40, pad = 3;
+  const points = results.slice().reverse();
+  if (points.length < 2) return "";
+
+  const max = Math.max(...points.filter((r) => r.success).map((r) => r.total_seconds), 0.000001);
+  const x = (i) => pad + (i * (w - 2 * pad)) / (points.length - 1);
+  const y = (v) => h - pad - (v / max) * (h - 2 * pad);
+
+  const line = points
+    .map((r, i) => (r.success ? x(i).toFixed(1) + "," + y(r.total_seconds).toFixed(1) : null))
+    .filter(Boolean)
+    .join(" ");
+  const failures = points
+    .map((r, i) => (r.success ? "" : '<circle cx="' + x(i).toFixed(1) + '" cy="' + (h - pad) + '" r="2.5"/>'))
+    .join("");
+
+  return '<svg class="sparkline" viewBox="0 0 ' + w + " " + h + '" preserveAspectRatio="none">' +
+    '<polyline points="' + line + '"/>' + failures + "</svg>";
+}
+
+function phaseBreakdown(results, proberType) {
+  const r = results.find((res) => res.success);
+  if (!r) return "";
+
+  const parts = phases(r, proberType);
+  const total = parts.reduce((sum, [, v]) => sum + v, 0) || 1;
+
+  const bar = parts
+    .map(([name, v]) => '<span style="width:' + ((v / total) * 100).toFixed(2) + "%;background:" +
+      PHASE_COLORS[name] + '" title="' + name + " " + ms(v) + '"></span>')
+    .join("");
+  const legend = parts
+    .map(([name, v]) => '<span><i style="background:' + PHASE_COLORS[name] + '"></i>' + name + " " + ms(v) + "</span>")
+    .join("");
+
+  return '<div class="phases"><div class="bar">' + bar + '</div><div class="legend">' + legend + "</div></div>";
+}
+
+function errorCategories(results) {
+  const counts = {};
+  for (const r of results) {
+    if (!r.success) counts[r.error_category || "unknown"] = (counts[r.error_category || "unknown"] || 0) + 1;
+  }
+
+  const entries = Object.entries(counts).sort((a, b) => b[1] - a[1]);
+  if (!entries.length) return "";
+
+  return '<div class="errors">Errors in last ' + results.length + ": " +
+    entries.map(([c, n]) => '<span class="badge">' + esc(c) + " × " + n + "</span>").join("") + "</div>";
+}
+
+function card(entry) {
+  const e = entry.info;
+  const st = state(entry);
+  const last = entry.results[0];
+  const labels = Object.entries(e.labels || {})
+    .map(([k, v]) => '<span class="badge">' + esc(k) + "=" + esc(v) + "</span>")
+    .join("");
+
+  let latest = "–";
+  if (last && last.success) latest = ms(last.total_seconds);
+  else if (last) latest = esc(last.error_category || "error");
+
+  return '<div class="card ' + st + '">' +
+    "<h2>" + esc(e.uri) + "</h2>" +
+    '<div class="meta"><span class="badge">' + esc(e.prober_type) + "</span>" +
+    (e.tag ? '<span class="badge">' + esc(e.tag) + "</span>" : "") + labels +
+    " every " + esc(e.interval) + "</div>" +
+    '<div class="meta"><span class="status ' + st + '">' + st.toUpperCase() + "</span>" +
+    " · last probe " + ago(last && last.time) + " · last success " + ago(entry.lastSuccess) +
+    (last && last.status_code ? " · HTTP " + esc(last.status_code) : "") +
+    (last && last.remote_addr ? " · <code>" + esc(last.remote_addr) + "</code>" : "") + "</div>" +
+    '<div class="row"><div class="latency">' + latest + "</div>" + sparkline(entry.results) + "</div>" +
+    phaseBreakdown(entry.results, e.prober_type) +
+    errorCategories(entry.results) +
+    (last && !last.success ? '<div class="last-error">' + esc(last.error) + "</div>" : "") +
+    "</div>";
+}
+
+function render() {
+  const all = [...endpoints.values()];
+  const down = all.filter((e) => state(e) === "down").length;
+
+  document.getElementById("summary").textContent =
+    all.length + " endpoints, " + down + " down";
+  document.getElementById("endpoints").innerHTML = all.length
+    ? all.map(card).join("")
+    : "<p>No endpoints are probed.</p>";
+}
+
+function follow() {
+  const live = document.getElementById("live");
+  const source = new EventSource("../api/v1/stream");
+
+  source.onopen = () => {
+    live.textContent = "live";
+    live.classList.add("on");
+  };
+
+  source.onerror = () => {
+    live.textContent = "reconnecting";
+    live.classList.remove("on");
+  };
+
+  let pending = false;
+
+  source.addEventListener("result", (msg) => {
+    const r = JSON.parse(msg.data);
+    const entry = endpoints.get(key(r.prober_type, r.uri, r.tag));
+
+    // An endpoint added by a reload, fetch the configuration again
+    if (!entry) {
+      load().catch(console.error);
+      return;
+    }
+
+    entry.results.unshift(r);
+    entry.results.length = Math.min(entry.results.length, HISTORY);
+    if (r.success) entry.lastSuccess = r.time;
+
+    if (!pending) {
+      pending = true;
+      requestAnimationFrame(() => {
+        pending = false;
+        render();
+      });
+    }
+  });
+}
+
+load()
+  .catch((err) => {
+    document.getElementById("summary").textContent = "Failed to load status: " + err.message;
+  })
+  .finally(follow);
+
+setInterval(() => load().catch(console.error), REFRESH_MS);
@@ -5,8 +5,11 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/dntosas/astrolavos/internal/model"
+	"github.com/dntosas/astrolavos/internal/results"
+	"github.com/dntosas/astrolavos/internal/slo"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -72,13 +75,58 @@ func NewLatencyHandler(maxPayloadSize int) http.HandlerFunc {
 	}
 }
 
+// redactedValue replaces header values in the status response so secrets
+// referenced from the configuration are never exposed.
+const redactedValue = "<redacted>"
+
 // statusEndpoint represents a single endpoint in the status response.
 type statusEndpoint struct {
-	URI        string `json:"uri"`
-	ProberType string `json:"prober_type"`
-	Interval   string `json:"interval"`
-	Retries    int    `json:"retries"`
-	Tag        string `json:"tag,omitempty"`
+	URI        string            `json:"uri"`
+	ProberType string            `json:"prober_type"`
+	Interval   string            `json:"interval"`
+	Retries    int               `json:"retries"`
+	Tag        string            `json:"tag,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Method     string            `json:"method,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	// LastResult and LastSuccess are omitted until the endpoint is probed
+	// and until it succeeds, respectively.
+	LastResult  *apiResult `json:"last_result,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	SLO         *statusSLO `json:"slo,omitempty"`
+}
+
+// statusSLO holds the objectives of an endpoint's SLO and their evaluation
+// over its window. Unset objectives are omitted.
+type statusSLO struct {
+	Window                  string             `json:"window"`
+	LatencyPercentile       float64            `json:"latency_percentile,omitempty"`
+	LatencyThresholdSeconds float64            `json:"latency_threshold_seconds,omitempty"`
+	TargetSuccessRatio      float64            `json:"target_success_ratio,omitempty"`
+	Compliant               bool               `json:"compliant"`
+	Samples                 int                `json:"samples"`
+	SuccessRatio            float64            `json:"success_ratio"`
+	LatencyRatio            float64            `json:"latency_ratio"`
+	ErrorBudgetRemaining    map[string]float64 `json:"error_budget_remaining"`
+}
+
+func newStatusSLO(s *model.SLO, st slo.Status) *statusSLO {
+	res := &statusSLO{
+		Window:               s.Window.String(),
+		TargetSuccessRatio:   s.SuccessRatio,
+		Compliant:            st.Compliant,
+		Samples:              st.Samples,
+		SuccessRatio:         st.SuccessRatio,
+		LatencyRatio:         st.LatencyRatio,
+		ErrorBudgetRemaining: st.ErrorBudgetRemaining,
+	}
+
+	if s.LatencyThreshold > 0 {
+		res.LatencyPercentile = s.LatencyPercentile
+		res.LatencyThresholdSeconds = s.LatencyThreshold.Seconds()
+	}
+
+	return res
 }
 
 // statusResponse is the JSON structure returned by the /status endpoint.
@@ -87,18 +135,41 @@ type statusResponse struct {
 	Endpoints []statusEndpoint `json:"endpoints"`
 }
 
-// NewStatusHandler creates a handler that returns the current configuration as JSON.
-func NewStatusHandler(version string, endpoints []*model.Endpoint) http.HandlerFunc {
+// NewStatusHandler creates a handler that returns the current configuration
+// as JSON, along with the last result and the SLO status of every endpoint.
+// The endpoints are fetched on every request so reloads are reflected.
+func NewStatusHandler(version string, endpoints func() []*model.Endpoint, store *results.Store, slos *slo.Tracker) http.HandlerFunc {
 	return func(w http.ResponseWriter, _ *http.Request) {
-		eps := make([]statusEndpoint, 0, len(endpoints))
-		for _, e := range endpoints {
-			eps = append(eps, statusEndpoint{
+		current := endpoints()
+
+		eps := make([]statusEndpoint, 0, len(current))
+		for _, e := range current {
+			se := statusEndpoint{
 				URI:        e.URI,
 				ProberType: e.ProberType,
 				Interval:   e.Interval.String(),
 				Retries:    e.Retries,
 				Tag:        e.Tag,
-			})
+				Labels:     e.Labels,
+				Method:     e.Method,
+				Headers:    redactHeaders(e.Headers),
+			}
+
+			if sum, ok := store.Summary(e.Key()); ok {
+				last := newAPIResult(sum.Latest)
+				se.LastResult = &last
+
+				if !sum.LastSuccess.IsZero() {
+					lastSuccess := sum.LastSuccess.UTC()
+					se.LastSuccess = &lastSuccess
+				}
+			}
+
+			if st, ok := slos.Status(e); ok {
+				se.SLO = newStatusSLO(e.SLO, st)
+			}
+
+			eps = append(eps, se)
 		}
 
 		resp := statusResponse{
@@ -114,3 +185,17 @@ func NewStatusHandler(version string, endpoints []*model.Endpoint) http.HandlerF
 		}
 	}
 }
+
+// redactHeaders returns the header names with every value redacted.
+func redactHeaders(headers map[string]string) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	redacted := make(map[string]string, len(headers))
+	for name := range headers {
+		redacted[name] = redactedValue
+	}
+
+	return redacted
+}
@@ -2,13 +2,17 @@ package handlers_test
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/dntosas/astrolavos/internal/handlers"
 	"github.com/dntosas/astrolavos/internal/model"
+	"github.com/dntosas/astrolavos/internal/results"
+	"github.com/dntosas/astrolavos/internal/slo"
 )
 
 func TestOKHandler(t *testing.T) {
@@ -125,6 +129,7 @@ func TestStatusHandler(t *testing.T) {
 			Interval:   5 * time.Second,
 			Retries:    3,
 			Tag:        "prod",
+			SLO:        &model.SLO{Window: time.Hour, LatencyPercentile: 95, LatencyThreshold: 300 * time.Millisecond},
 		},
 		{
 			URI:        "db.internal:5432",
@@ -134,7 +139,15 @@ func TestStatusHandler(t *testing.T) {
 		},
 	}
 
-	handler := handlers.NewStatusHandler("v1.0.0", endpoints)
+	store := results.NewStore(0, 0)
+	lastSuccess := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	store.Record(endpoints[0].Key(), model.Result{Time: lastSuccess, StatusCode: "200", Total: time.Second})
+	store.Record(endpoints[0].Key(), model.Result{Time: lastSuccess.Add(time.Minute), Err: errors.New("timeout")})
+
+	slos := slo.NewTracker()
+	slos.Observe(endpoints[0], model.Result{Time: time.Now(), Total: time.Second})
+
+	handler := handlers.NewStatusHandler("v1.0.0", func() []*model.Endpoint { return endpoints }, store, slos)
 
 	req := httptest.NewRequest(http.MethodGet, "/status", nil)
 	w := httptest.NewRecorder()
@@ -164,6 +177,77 @@ func TestStatusHandler(t *testing.T) {
 	}
 
 	if len(eps) != 2 {
-		t.Errorf("expected 2 endpoints, got %d", len(eps))
+		t.Fatalf("expected 2 endpoints, got %d", len(eps))
+	}
+
+	probed, _ := eps[0].(map[string]interface{})
+	if probed["prober_type"] != "httpTrace" {
+		t.Errorf("expected prober_type 'httpTrace', got %v", probed["prober_type"])
+	}
+
+	last, ok := probed["last_result"].(map[string]interface{})
+	if !ok || last["success"] != false || last["error"] != "timeout" {
+		t.Errorf("expected last result to be the failure, got %v", probed["last_result"])
+	}
+
+	if probed["last_success"] != "2026-01-02T03:04:05Z" {
+		t.Errorf("unexpected last success: %v", probed["last_success"])
+	}
+
+	objective, ok := probed["slo"].(map[string]interface{})
+	if !ok || objective["compliant"] != false || objective["latency_threshold_seconds"] != 0.3 || objective["latency_ratio"] != 0.0 {
+		t.Errorf("expected a broken latency objective, got %v", probed["slo"])
+	}
+
+	unprobed, _ := eps[1].(map[string]interface{})
+	if _, ok := unprobed["last_result"]; ok {
+		t.Errorf("expected no last result for an endpoint without results")
+	}
+
+	if _, ok := unprobed["slo"]; ok {
+		t.Errorf("expected no slo for an endpoint without objectives")
+	}
+}
+
+func TestStatusHandler_RedactsHeaders(t *testing.T) {
+	endpoints := []*model.Endpoint{
+		{
+			URI:        "https://api.example.com/health",
+			ProberType: "httpTrace",
+			Interval:   5 * time.Second,
+			Retries:    1,
+			Method:     http.MethodPost,
+			Headers:    map[string]string{"authorization": "Bearer secret-token"},
+		},
+	}
+
+	handler := handlers.NewStatusHandler("v1.0.0", func() []*model.Endpoint { return endpoints }, results.NewStore(0, 0), slo.NewTracker())
+
+	req := httptest.NewRequest(http.MethodGet, "/status", nil)
+	w := httptest.NewRecorder()
+
+	handler(w, req)
+
+	if strings.Contains(w.Body.String(), "secret-token") {
+		t.Fatal("status response leaks header value")
+	}
+
+	var resp struct {
+		Endpoints []struct {
+			Method  string            `json:"method"`
+			Headers map[string]string `json:"headers"`
+		} `json:"endpoints"`
+	}
+
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse JSON response: %v", err)
+	}
+
+	if resp.Endpoints[0].Method != http.MethodPost {
+		t.Errorf("expected method POST, got %q", resp.Endpoints[0].Method)
+	}
+
+	if resp.Endpoints[0].Headers["authorization"] != "<redacted>" {
+		t.Errorf("expected redacted authorization header, got %q", resp.Endpoints[0].Headers["authorization"])
 	}
 }
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/mesh"
+	"github.com/dntosas/astrolavos/internal/model"
+	"github.com/dntosas/astrolavos/internal/results"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// matrixEndpoint is the latency summary of a single endpoint. Latencies are
+// in seconds, like the exported metrics.
+type matrixEndpoint struct {
+	URI              string            `json:"uri"`
+	Labels           map[string]string `json:"labels,omitempty"`
+	Samples          int               `json:"samples"`
+	LatestSeconds    *float64          `json:"latest_seconds"`
+	LatestStatusCode string            `json:"latest_status_code,omitempty"`
+	LatestError      string            `json:"latest_error,omitempty"`
+	P50Seconds       float64           `json:"p50_seconds"`
+	P95Seconds       float64           `json:"p95_seconds"`
+	P99Seconds       float64           `json:"p99_seconds"`
+	ErrorRate        float64           `json:"error_rate"`
+}
+
+// matrixGroup holds the endpoints sharing a tag and prober type.
+type matrixGroup struct {
+	Tag        string           `json:"tag"`
+	ProberType string           `json:"prober_type"`
+	Endpoints  []matrixEndpoint `json:"endpoints"`
+}
+
+// matrixResponse is the JSON structure returned by the /api/v1/matrix endpoint.
+// Source identifies this instance, the row every group is measured from.
+type matrixResponse struct {
+	Source      string        `json:"source"`
+	Window      string        `json:"window"`
+	GeneratedAt time.Time     `json:"generated_at"`
+	Groups      []matrixGroup `json:"groups"`
+}
+
+// NewMatrixHandler creates a handler that returns the latest and rolling
+// latency percentiles and error rate of every endpoint, grouped by tag and
+// prober type. It responds with an HTML heat map when called with
+// ?format=html and with JSON otherwise.
+func NewMatrixHandler(source string, endpoints func() []*model.Endpoint, store *results.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := buildMatrix(source, endpoints(), store)
+
+		if r.URL.Query().Get("format") == "html" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+
+			if err := matrixTemplate.Execute(w, newHeatMap(resp)); err != nil {
+				log.Error(err)
+			}
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// buildMatrix summarizes the endpoints, grouped by tag and prober type in
+// that order. Endpoints keep their configured order within a group.
+func buildMatrix(source string, endpoints []*model.Endpoint, store *results.Store) matrixResponse {
+	type groupKey struct{ tag, proberType string }
+
+	groups := map[groupKey]*matrixGroup{}
+	keys := []groupKey{}
+
+	for _, e := range endpoints {
+		k := groupKey{e.Tag, e.ProberType}
+
+		g, ok := groups[k]
+		if !ok {
+			g = &matrixGroup{Tag: e.Tag, ProberType: e.ProberType, Endpoints: []matrixEndpoint{}}
+			groups[k] = g
+			keys = append(keys, k)
+		}
+
+		me := matrixEndpoint{URI: e.URI, Labels: e.Labels}
+
+		if sum, ok := store.Summary(e.Key()); ok {
+			me.Samples = sum.Samples
+			me.LatestStatusCode = sum.Latest.StatusCode
+			me.P50Seconds = sum.P50.Seconds()
+			me.P95Seconds = sum.P95.Seconds()
+			me.P99Seconds = sum.P99.Seconds()
+			me.ErrorRate = sum.ErrorRate
+
+			if sum.Latest.Success() {
+				latest := sum.Latest.Total.Seconds()
+				me.LatestSeconds = &latest
+			} else {
+				me.LatestError = sum.Latest.Err.Error()
+			}
+		}
+
+		g.Endpoints = append(g.Endpoints, me)
+	}
+
+	slices.SortFunc(keys, func(a, b groupKey) int {
+		return cmp.Or(cmp.Compare(a.tag, b.tag), cmp.Compare(a.proberType, b.proberType))
+	})
+
+	resp := matrixResponse{
+		Source:      source,
+		Window:      store.Window().String(),
+		GeneratedAt: time.Now().UTC(),
+		Groups:      make([]matrixGroup, 0, len(keys)),
+	}
+
+	for _, k := range keys {
+		resp.Groups = append(resp.Groups, *groups[k])
+	}
+
+	return resp
+}
+
+// heatMap is the view model of the HTML matrix.
+type heatMap struct {
+	Source      string
+	Window      string
+	GeneratedAt time.Time
+	Groups      []heatMapGroup
+}
+
+type heatMapGroup struct {
+	Tag        string
+	ProberType string
+	Cells      []heatMapCell
+}
+
+type heatMapCell struct {
+	Name   string
+	Title  string
+	Text   string
+	Detail string
+	Color  string
+}
+
+// newHeatMap colors every endpoint by its p95 latency relative to the
+// slowest endpoint, or by its error rate when that is worse.
+func newHeatMap(m matrixResponse) heatMap {
+	var slowest float64
+
+	for _, g := range m.Groups {
+		for _, e := range g.Endpoints {
+			slowest = max(slowest, e.P95Seconds)
+		}
+	}
+
+	hm := heatMap{
+		Source:      m.Source,
+		Window:      m.Window,
+		GeneratedAt: m.GeneratedAt,
+		Groups:      make([]heatMapGroup, 0, len(m.Groups)),
+	}
+
+	for _, g := range m.Groups {
+		hg := heatMapGroup{Tag: g.Tag, ProberType: g.ProberType}
+
+		for _, e := range g.Endpoints {
+			cell := heatMapCell{Name: e.URI, Title: e.URI, Text: "no data", Color: "#e0e0e0"}
+			// Mesh peers read better by name than by URI
+			if dest, ok := e.Labels[mesh.DestinationLabel]; ok {
+				cell.Name = dest
+			}
+
+			if e.Samples > 0 {
+				score := e.ErrorRate
+				if slowest > 0 {
+					score = max(score, e.P95Seconds/slowest)
+				}
+
+				cell.Color = heatColor(score)
+				cell.Text = fmt.Sprintf("p95 %.1fms", e.P95Seconds*1000)
+				cell.Detail = fmt.Sprintf("p50 %.1fms · p99 %.1fms · errors %.1f%%",
+					e.P50Seconds*1000, e.P99Seconds*1000, e.ErrorRate*100)
+			}
+
+			hg.Cells = append(hg.Cells, cell)
+		}
+
+		hm.Groups = append(hm.Groups, hg)
+	}
+
+	return hm
+}
+
+// heatColor maps a score between 0 and 1 to a color from green through
+// yellow to red.
+func heatColor(score float64) string {
+	type rgb struct{ r, g, b float64 }
+
+	green, yellow, red := rgb{99, 190, 123}, rgb{255, 235, 132}, rgb{248, 105, 107}
+
+	from, to, f := green, yellow, min(max(score, 0), 1)*2
+	if f > 1 {
+		from, to, f = yellow, red, f-1
+	}
+
+	mix := func(a, b float64) int { return int(a + (b-a)*f) }
+
+	return fmt.Sprintf("#%02x%02x%02x", mix(from.r, to.r), mix(from.g, to.g), mix(from.b, to.b))
+}
+
+var matrixTemplate = template.Must(template.New("matrix").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="30">
+<title>Astrolavos latency matrix</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #fff; padding: 0.5em 0.8em; text-align: center; }
+th { background: #f4f4f4; font-weight: normal; }
+small { display: block; color: #333; }
+</style>
+</head>
+<body>
+<h1>Latency matrix</h1>
+<p>Source <strong>{{.Source}}</strong>, p95 over the last {{.Window}}, generated {{.GeneratedAt.Format "2006-01-02T15:04:05Z07:00"}}.</p>
+{{range .Groups}}
+<h2>{{.ProberType}}{{if .Tag}} · {{.Tag}}{{end}}</h2>
+<table>
+<tr><th></th>{{range .Cells}}<th title="{{.Title}}">{{.Name}}</th>{{end}}</tr>
+<tr><th>{{$.Source}}</th>{{range .Cells}}<td style="background: {{.Color}}">{{.Text}}<small>{{.Detail}}</small></td>{{end}}</tr>
+</table>
+{{else}}
+<p>No endpoints are probed.</p>
+{{end}}
+</body>
+</html>
+`))
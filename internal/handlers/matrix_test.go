@@ -0,0 +1,122 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/handlers"
+	"github.com/dntosas/astrolavos/internal/model"
+	"github.com/dntosas/astrolavos/internal/results"
+)
+
+type matrixResponse struct {
+	Source string `json:"source"`
+	Window string `json:"window"`
+	Groups []struct {
+		Tag        string `json:"tag"`
+		ProberType string `json:"prober_type"`
+		Endpoints  []struct {
+			URI           string   `json:"uri"`
+			Samples       int      `json:"samples"`
+			LatestSeconds *float64 `json:"latest_seconds"`
+			LatestError   string   `json:"latest_error"`
+			P95Seconds    float64  `json:"p95_seconds"`
+			ErrorRate     float64  `json:"error_rate"`
+		} `json:"endpoints"`
+	} `json:"groups"`
+}
+
+func matrixFixture() (func() []*model.Endpoint, *results.Store) {
+	endpoints := []*model.Endpoint{
+		{URI: "http://10.0.0.2:3000/latency", ProberType: "httpTrace", Tag: "mesh", Labels: map[string]string{"destination": "10.0.0.2"}},
+		{URI: "db.internal:5432", ProberType: "tcp", Tag: "prod"},
+		{URI: "https://example.com", ProberType: "httpTrace", Tag: "prod"},
+		{URI: "https://example.org", ProberType: "httpTrace", Tag: "prod"},
+	}
+
+	store := results.NewStore(time.Minute, 0)
+	now := time.Now()
+
+	store.Record(endpoints[0].Key(), model.Result{Time: now, Total: 20 * time.Millisecond})
+	store.Record(endpoints[2].Key(), model.Result{Time: now, Total: 100 * time.Millisecond, StatusCode: "200"})
+	store.Record(endpoints[2].Key(), model.Result{Time: now, Err: errors.New("connection refused")})
+
+	return func() []*model.Endpoint { return endpoints }, store
+}
+
+func TestMatrixHandler(t *testing.T) {
+	endpoints, store := matrixFixture()
+	handler := handlers.NewMatrixHandler("eu-1", endpoints, store)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/matrix", nil)
+	w := httptest.NewRecorder()
+
+	handler(w, req)
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected Content-Type 'application/json', got %q", ct)
+	}
+
+	var resp matrixResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse JSON response: %v", err)
+	}
+
+	if resp.Source != "eu-1" || resp.Window != "1m0s" {
+		t.Errorf("unexpected source or window: %q, %q", resp.Source, resp.Window)
+	}
+
+	// Grouped by tag, then prober type
+	if len(resp.Groups) != 3 {
+		t.Fatalf("expected 3 groups, got %d", len(resp.Groups))
+	}
+
+	got := resp.Groups[1].Tag + "/" + resp.Groups[1].ProberType + " " + resp.Groups[2].Tag + "/" + resp.Groups[2].ProberType
+	if resp.Groups[0].Tag != "mesh" || got != "prod/httpTrace prod/tcp" {
+		t.Errorf("unexpected group order: %s/%s %s", resp.Groups[0].Tag, resp.Groups[0].ProberType, got)
+	}
+
+	failing := resp.Groups[1].Endpoints[0]
+	if failing.Samples != 2 || failing.ErrorRate != 0.5 || failing.P95Seconds != 0.1 {
+		t.Errorf("unexpected summary: %+v", failing)
+	}
+
+	if failing.LatestSeconds != nil || failing.LatestError != "connection refused" {
+		t.Errorf("expected latest result to be the failure: %+v", failing)
+	}
+
+	unprobed := resp.Groups[1].Endpoints[1]
+	if unprobed.URI != "https://example.org" || unprobed.Samples != 0 || unprobed.LatestSeconds != nil {
+		t.Errorf("expected endpoint without results to be listed empty: %+v", unprobed)
+	}
+
+	if latest := resp.Groups[0].Endpoints[0].LatestSeconds; latest == nil || *latest != 0.02 {
+		t.Errorf("unexpected latest latency: %v", latest)
+	}
+}
+
+func TestMatrixHandler_HTML(t *testing.T) {
+	endpoints, store := matrixFixture()
+	handler := handlers.NewMatrixHandler("eu-1", endpoints, store)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/matrix?format=html", nil)
+	w := httptest.NewRecorder()
+
+	handler(w, req)
+
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("expected an HTML Content-Type, got %q", ct)
+	}
+
+	body := w.Body.String()
+	for _, want := range []string{"<th>eu-1</th>", ">10.0.0.2</th>", "p95 100.0ms", "errors 50.0%", "no data", "background: #"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected HTML to contain %q", want)
+		}
+	}
+}
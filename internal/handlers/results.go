@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/metrics"
+	"github.com/dntosas/astrolavos/internal/model"
+	"github.com/dntosas/astrolavos/internal/results"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// apiResult is the JSON representation of a single probe result. Phase
+// latencies are in seconds and zero for phases the prober does not measure.
+type apiResult struct {
+	Time             time.Time `json:"time"`
+	Success          bool      `json:"success"`
+	Error            string    `json:"error,omitempty"`
+	ErrorCategory    string    `json:"error_category,omitempty"`
+	StatusCode       string    `json:"status_code,omitempty"`
+	RemoteAddr       string    `json:"remote_addr,omitempty"`
+	DNSSeconds       float64   `json:"dns_seconds"`
+	ConnectSeconds   float64   `json:"connect_seconds"`
+	TLSSeconds       float64   `json:"tls_seconds"`
+	GotConnSeconds   float64   `json:"got_conn_seconds"`
+	FirstByteSeconds float64   `json:"first_byte_seconds"`
+	TotalSeconds     float64   `json:"total_seconds"`
+}
+
+func newAPIResult(r model.Result) apiResult {
+	res := apiResult{
+		Time:             r.Time.UTC(),
+		Success:          r.Success(),
+		StatusCode:       r.StatusCode,
+		RemoteAddr:       r.RemoteAddr,
+		DNSSeconds:       r.DNS.Seconds(),
+		ConnectSeconds:   r.Connect.Seconds(),
+		TLSSeconds:       r.TLS.Seconds(),
+		GotConnSeconds:   r.GotConn.Seconds(),
+		FirstByteSeconds: r.FirstByte.Seconds(),
+		TotalSeconds:     r.Total.Seconds(),
+	}
+
+	if r.Err != nil {
+		res.Error = r.Err.Error()
+		res.ErrorCategory = metrics.CategorizeError(r.Err)
+	}
+
+	return res
+}
+
+// resultsEndpoint holds the recent results of a single endpoint, newest first.
+type resultsEndpoint struct {
+	URI        string            `json:"uri"`
+	ProberType string            `json:"prober_type"`
+	Tag        string            `json:"tag,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Results    []apiResult       `json:"results"`
+}
+
+// resultsResponse is the JSON structure returned by the /api/v1/results endpoint.
+type resultsResponse struct {
+	Endpoints []resultsEndpoint `json:"endpoints"`
+}
+
+// NewResultsHandler creates a handler that returns the recent results of the
+// endpoints whose URI matches the endpoint query parameter. As the same URI
+// may be probed by several probers, prober_type and tag narrow the match
+// down, and limit caps the number of results per endpoint.
+func NewResultsHandler(endpoints func() []*model.Endpoint, store *results.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		uri := query.Get("endpoint")
+		if uri == "" {
+			http.Error(w, "missing endpoint query parameter", http.StatusBadRequest)
+
+			return
+		}
+
+		limit := 0
+
+		if l := query.Get("limit"); l != "" {
+			var err error
+
+			limit, err = strconv.Atoi(l)
+			if err != nil || limit <= 0 {
+				http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+
+				return
+			}
+		}
+
+		resp := resultsResponse{Endpoints: []resultsEndpoint{}}
+
+		for _, e := range endpoints() {
+			if e.URI != uri ||
+				(query.Has("prober_type") && !strings.EqualFold(query.Get("prober_type"), e.ProberType)) ||
+				(query.Has("tag") && query.Get("tag") != e.Tag) {
+				continue
+			}
+
+			recent, _ := store.Recent(e.Key(), limit)
+
+			re := resultsEndpoint{
+				URI:        e.URI,
+				ProberType: e.ProberType,
+				Tag:        e.Tag,
+				Labels:     e.Labels,
+				Results:    make([]apiResult, 0, len(recent)),
+			}
+
+			for _, res := range recent {
+				re.Results = append(re.Results, newAPIResult(res))
+			}
+
+			resp.Endpoints = append(resp.Endpoints, re)
+		}
+
+		if len(resp.Endpoints) == 0 {
+			http.Error(w, "endpoint is not probed: "+uri, http.StatusNotFound)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Error(err)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/handlers"
+	"github.com/dntosas/astrolavos/internal/model"
+	"github.com/dntosas/astrolavos/internal/results"
+)
+
+type resultsResponse struct {
+	Endpoints []struct {
+		URI        string `json:"uri"`
+		ProberType string `json:"prober_type"`
+		Results    []struct {
+			Time           time.Time `json:"time"`
+			Success        bool      `json:"success"`
+			StatusCode     string    `json:"status_code"`
+			RemoteAddr     string    `json:"remote_addr"`
+			ConnectSeconds float64   `json:"connect_seconds"`
+			TotalSeconds   float64   `json:"total_seconds"`
+		} `json:"results"`
+	} `json:"endpoints"`
+}
+
+func TestResultsHandler(t *testing.T) {
+	endpoints := []*model.Endpoint{
+		{URI: "https://example.com", ProberType: "httpTrace", Tag: "prod"},
+		{URI: "example.com:443", ProberType: "tcp", Tag: "prod"},
+		{URI: "example.com:443", ProberType: "tls", Tag: "prod"},
+	}
+
+	store := results.NewStore(0, 3)
+	start := time.Now()
+
+	for i := range 5 {
+		store.Record(endpoints[0].Key(), model.Result{
+			Time:       start.Add(time.Duration(i) * time.Second),
+			StatusCode: "200",
+			RemoteAddr: "93.184.216.34:443",
+			Connect:    10 * time.Millisecond,
+			Total:      time.Duration(i+1) * 100 * time.Millisecond,
+		})
+	}
+
+	store.Record(endpoints[1].Key(), model.Result{Time: start, Total: time.Millisecond})
+
+	handler := handlers.NewResultsHandler(func() []*model.Endpoint { return endpoints }, store)
+
+	get := func(query string) (*httptest.ResponseRecorder, resultsResponse) {
+		t.Helper()
+
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/api/v1/results"+query, nil))
+
+		var resp resultsResponse
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to parse JSON response: %v", err)
+			}
+		}
+
+		return w, resp
+	}
+
+	// The ring buffer keeps the last 3 results, newest first
+	_, resp := get("?endpoint=https://example.com")
+	if len(resp.Endpoints) != 1 || len(resp.Endpoints[0].Results) != 3 {
+		t.Fatalf("expected 3 kept results, got %+v", resp)
+	}
+
+	// Endpoints report their configured prober, like /status and the matrix
+	if resp.Endpoints[0].ProberType != "httpTrace" {
+		t.Errorf("expected prober_type 'httpTrace', got %q", resp.Endpoints[0].ProberType)
+	}
+
+	first := resp.Endpoints[0].Results[0]
+	if first.TotalSeconds != 0.5 || !first.Success || first.StatusCode != "200" ||
+		first.RemoteAddr != "93.184.216.34:443" || first.ConnectSeconds != 0.01 {
+		t.Errorf("unexpected newest result: %+v", first)
+	}
+
+	if resp.Endpoints[0].Results[2].TotalSeconds != 0.3 {
+		t.Errorf("expected oldest kept result to be the third, got %+v", resp.Endpoints[0].Results[2])
+	}
+
+	if _, resp = get("?endpoint=https://example.com&limit=1"); len(resp.Endpoints[0].Results) != 1 {
+		t.Errorf("expected limit to cap the results, got %d", len(resp.Endpoints[0].Results))
+	}
+
+	// A URI probed by several probers returns each of them unless narrowed down
+	if _, resp = get("?endpoint=example.com:443"); len(resp.Endpoints) != 2 || len(resp.Endpoints[1].Results) != 0 {
+		t.Errorf("expected tcp and tls endpoints, got %+v", resp)
+	}
+
+	if _, resp = get("?endpoint=example.com:443&prober_type=tcp"); len(resp.Endpoints) != 1 || resp.Endpoints[0].ProberType != "tcp" {
+		t.Errorf("expected only the tcp endpoint, got %+v", resp)
+	}
+
+	for _, proberType := range []string{"httptrace", "httpTrace"} {
+		if _, resp = get("?endpoint=https://example.com&prober_type=" + proberType); len(resp.Endpoints) != 1 {
+			t.Errorf("expected prober_type=%s to match the httpTrace endpoint, got %+v", proberType, resp)
+		}
+	}
+
+	for query, code := range map[string]int{
+		"":                                      http.StatusBadRequest,
+		"?endpoint=https://example.com&limit=x": http.StatusBadRequest,
+		"?endpoint=https://example.com&limit=0": http.StatusBadRequest,
+		"?endpoint=https://unknown.example.com": http.StatusNotFound,
+	} {
+		if w, _ := get(query); w.Code != code {
+			t.Errorf("expected status %d for %q, got %d", code, query, w.Code)
+		}
+	}
+}
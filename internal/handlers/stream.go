@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/results"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// streamBuffer is the number of events queued per client before new
+	// ones are dropped.
+	streamBuffer = 64
+	// streamKeepAlive is how often an idle stream sends a comment, so
+	// proxies do not close it.
+	streamKeepAlive = 15 * time.Second
+)
+
+// streamEvent is the data of a result event on the /api/v1/stream endpoint.
+type streamEvent struct {
+	URI        string `json:"uri"`
+	ProberType string `json:"prober_type"`
+	Tag        string `json:"tag,omitempty"`
+	apiResult
+}
+
+// NewStreamHandler creates a handler that streams every probe result as a
+// Server-Sent Event as soon as it completes. The endpoint, tag and
+// prober_type query parameters filter the results. Events a slow client
+// cannot keep up with are dropped and reported in a dropped event.
+func NewStreamHandler(store *results.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		filter := func(e results.Event) bool {
+			return (!query.Has("endpoint") || query.Get("endpoint") == e.Key.URI) &&
+				(!query.Has("tag") || query.Get("tag") == e.Key.Tag) &&
+				(!query.Has("prober_type") || strings.EqualFold(query.Get("prober_type"), e.Key.ProberType))
+		}
+
+		rc := http.NewResponseController(w)
+
+		// Streams outlive the server's write timeout
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			log.WithError(err).Warn("Unable to clear the write deadline of a result stream")
+		}
+
+		sub := store.Subscribe(streamBuffer, filter)
+		defer store.Unsubscribe(sub)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+
+		if err := rc.Flush(); err != nil {
+			log.WithError(err).Error("Result streaming is not supported")
+
+			return
+		}
+
+		keepAlive := time.NewTicker(streamKeepAlive)
+		defer keepAlive.Stop()
+
+		var dropped uint64
+
+		for {
+			var err error
+
+			select {
+			case <-r.Context().Done():
+				return
+			case <-keepAlive.C:
+				_, err = fmt.Fprint(w, ": keep-alive\n\n")
+			case e, ok := <-sub.C:
+				if !ok {
+					return
+				}
+
+				if d := sub.Dropped(); d > dropped {
+					err = writeEvent(w, "dropped", map[string]uint64{"dropped": d - dropped})
+					dropped = d
+				}
+
+				if err == nil {
+					err = writeEvent(w, "result", streamEvent{
+						URI:        e.Key.URI,
+						ProberType: e.Key.ProberType,
+						Tag:        e.Key.Tag,
+						apiResult:  newAPIResult(e.Result),
+					})
+				}
+			}
+
+			if err == nil {
+				err = rc.Flush()
+			}
+
+			if err != nil {
+				log.WithError(err).Debug("Result stream closed")
+
+				return
+			}
+		}
+	}
+}
+
+// writeEvent writes a single Server-Sent Event with JSON data.
+func writeEvent(w http.ResponseWriter, event string, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+
+	return err
+}
@@ -0,0 +1,104 @@
+package handlers_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/handlers"
+	"github.com/dntosas/astrolavos/internal/model"
+	"github.com/dntosas/astrolavos/internal/results"
+)
+
+func TestStreamHandler(t *testing.T) {
+	store := results.NewStore(0, 0)
+	srv := httptest.NewServer(handlers.NewStreamHandler(store))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/v1/stream?tag=prod&prober_type=httpTrace")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected Content-Type 'text/event-stream', got %q", ct)
+	}
+
+	lines := make(chan string)
+
+	go func() {
+		defer close(lines)
+
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	// The subscription exists once the headers are sent
+	store.Record(model.Key{ProberType: "httpTrace", URI: "https://staging.example.com", Tag: "staging"}, model.Result{Time: time.Now()})
+	store.Record(model.Key{ProberType: "tcp", URI: "example.com:443", Tag: "prod"}, model.Result{Time: time.Now()})
+	store.Record(model.Key{ProberType: "httpTrace", URI: "https://example.com", Tag: "prod"}, model.Result{
+		Time:       time.Now(),
+		RemoteAddr: "93.184.216.34:443",
+		Total:      50 * time.Millisecond,
+	})
+
+	var event, data string
+
+	for event == "" || data == "" {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatal("stream ended early")
+			}
+
+			if v, ok := strings.CutPrefix(line, "event: "); ok {
+				event = v
+			}
+
+			if v, ok := strings.CutPrefix(line, "data: "); ok {
+				data = v
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for an event")
+		}
+	}
+
+	var got struct {
+		URI          string  `json:"uri"`
+		ProberType   string  `json:"prober_type"`
+		Tag          string  `json:"tag"`
+		Success      bool    `json:"success"`
+		RemoteAddr   string  `json:"remote_addr"`
+		TotalSeconds float64 `json:"total_seconds"`
+	}
+
+	if err := json.Unmarshal([]byte(data), &got); err != nil {
+		t.Fatalf("failed to parse event data %q: %v", data, err)
+	}
+
+	if event != "result" || got.URI != "https://example.com" || got.ProberType != "httpTrace" || got.Tag != "prod" || !got.Success ||
+		got.RemoteAddr != "93.184.216.34:443" || got.TotalSeconds != 0.05 {
+		t.Errorf("unexpected %s event: %s", event, data)
+	}
+
+	// Closing the store ends the stream
+	store.Close()
+
+	for {
+		select {
+		case _, ok := <-lines:
+			if !ok {
+				return
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected the stream to end after closing the store")
+		}
+	}
+}
@@ -0,0 +1,144 @@
+// Package jsonpath implements the small subset of JSONPath needed to assert
+// on probe responses: a root followed by member and index selectors, as in
+// $.status, $.items[0].name or $['content-type'].
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// segment is a single member name or array index selector.
+type segment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// Path is a parsed JSONPath expression.
+type Path struct {
+	raw      string
+	segments []segment
+}
+
+// Parse compiles a JSONPath expression. Only the root ($), dot member
+// selectors, quoted bracket member selectors and array indexes are supported.
+func Parse(expr string) (Path, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return Path{}, fmt.Errorf("invalid JSONPath %q: must start with '$'", expr)
+	}
+
+	p := Path{raw: expr}
+	rest := expr[1:]
+
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end == -1 {
+				end = len(rest) - 1
+			}
+
+			key := rest[1 : end+1]
+			if key == "" {
+				return Path{}, fmt.Errorf("invalid JSONPath %q: empty member name", expr)
+			}
+
+			p.segments = append(p.segments, segment{key: key})
+			rest = rest[end+1:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return Path{}, fmt.Errorf("invalid JSONPath %q: unterminated '['", expr)
+			}
+
+			sel := rest[1:end]
+			rest = rest[end+1:]
+
+			if len(sel) >= 2 && (sel[0] == '\'' || sel[0] == '"') && sel[len(sel)-1] == sel[0] {
+				p.segments = append(p.segments, segment{key: sel[1 : len(sel)-1]})
+
+				continue
+			}
+
+			i, err := strconv.Atoi(sel)
+			if err != nil || i < 0 {
+				return Path{}, fmt.Errorf("invalid JSONPath %q: bad selector [%s]", expr, sel)
+			}
+
+			p.segments = append(p.segments, segment{index: i, isIndex: true})
+		default:
+			return Path{}, fmt.Errorf("invalid JSONPath %q: unexpected %q", expr, rest[0])
+		}
+	}
+
+	return p, nil
+}
+
+// MustParse is like Parse but panics if the expression cannot be parsed.
+func MustParse(expr string) Path {
+	p, err := Parse(expr)
+	if err != nil {
+		panic(err)
+	}
+
+	return p
+}
+
+// String returns the expression the path was parsed from.
+func (p Path) String() string {
+	return p.raw
+}
+
+// Lookup returns the value selected by the path from a document decoded
+// with encoding/json, and whether it exists.
+func (p Path) Lookup(doc any) (any, bool) {
+	cur := doc
+
+	for _, s := range p.segments {
+		if s.isIndex {
+			arr, ok := cur.([]any)
+			if !ok || s.index >= len(arr) {
+				return nil, false
+			}
+
+			cur = arr[s.index]
+
+			continue
+		}
+
+		obj, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+
+		if cur, ok = obj[s.key]; !ok {
+			return nil, false
+		}
+	}
+
+	return cur, true
+}
+
+// Format renders a selected value for comparison against an expected string:
+// strings as-is, numbers, booleans and null in their JSON form and
+// objects or arrays as compact JSON.
+func Format(v any) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case nil:
+		return "null"
+	default:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprint(val)
+		}
+
+		return string(b)
+	}
+}
@@ -0,0 +1,74 @@
+package jsonpath_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dntosas/astrolavos/internal/jsonpath"
+)
+
+const doc = `{
+	"status": "up",
+	"version": 3,
+	"ready": true,
+	"items": [{"name": "db"}, {"name": "cache"}],
+	"content-type": "json",
+	"nothing": null
+}`
+
+func decode(t *testing.T) any {
+	t.Helper()
+
+	dec := json.NewDecoder(strings.NewReader(doc))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		t.Fatalf("failed to decode document: %v", err)
+	}
+
+	return v
+}
+
+func TestLookup(t *testing.T) {
+	v := decode(t)
+
+	tests := []struct {
+		path     string
+		expected string
+		found    bool
+	}{
+		{"$.status", "up", true},
+		{"$.version", "3", true},
+		{"$.ready", "true", true},
+		{"$.items[1].name", "cache", true},
+		{"$['content-type']", "json", true},
+		{"$.nothing", "null", true},
+		{"$.items[0]", `{"name":"db"}`, true},
+		{"$.items[5].name", "", false},
+		{"$.missing", "", false},
+		{"$.status.nested", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, ok := jsonpath.MustParse(tt.path).Lookup(v)
+			if ok != tt.found {
+				t.Fatalf("Lookup(%q) found = %v, want %v", tt.path, ok, tt.found)
+			}
+
+			if ok && jsonpath.Format(got) != tt.expected {
+				t.Errorf("Lookup(%q) = %q, want %q", tt.path, jsonpath.Format(got), tt.expected)
+			}
+		})
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, expr := range []string{"status", "$.", "$[", "$[-1]", "$[abc]", "$status"} {
+		if _, err := jsonpath.Parse(expr); err == nil {
+			t.Errorf("Parse(%q) expected error", expr)
+		}
+	}
+}
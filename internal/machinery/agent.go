@@ -3,78 +3,409 @@ package machinery
 
 import (
 	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
 
+	"github.com/dntosas/astrolavos/internal/alerting"
 	"github.com/dntosas/astrolavos/internal/metrics"
 	"github.com/dntosas/astrolavos/internal/model"
 	"github.com/dntosas/astrolavos/internal/probers"
+	"github.com/dntosas/astrolavos/internal/results"
+	"github.com/dntosas/astrolavos/internal/slo"
 
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // agent manages a collection of probers and coordinates their lifecycle.
+// Probers run for the union of the static endpoints from the configuration
+// and the ones created for peers discovered in mesh mode.
 type agent struct {
-	probers []probers.Prober
-	wg      *sync.WaitGroup
-	promC   *metrics.PrometheusClient
+	mu        sync.Mutex
+	ctx       context.Context
+	static    []*model.Endpoint
+	mesh      []*model.Endpoint
+	endpoints []*model.Endpoint
+	runners   map[model.Key]*runner
+	isOneOff  bool
+	promC     *metrics.PrometheusClient
+	results   *results.Store
+	slos      *slo.Tracker
+	tracer    trace.Tracer
+	// notifier is set before the agent is started, if alerting is enabled
+	notifier *alerting.Notifier
+	// reconcileMu serializes reloads and mesh updates, which finish
+	// without holding mu
+	reconcileMu sync.Mutex
+}
+
+// runner is a single prober together with the handles needed to stop it.
+type runner struct {
+	endpoint *model.Endpoint
+	prober   probers.Prober
+	wg       sync.WaitGroup
+	cancel   context.CancelFunc
 }
 
 // newAgent creates a new agent with probers for each configured endpoint.
-func newAgent(endpoints []*model.Endpoint, isOneOff bool, promC *metrics.PrometheusClient) *agent {
-	var wg sync.WaitGroup
-
-	probersList := []probers.Prober{}
-
-	for _, e := range endpoints {
-		p := probers.NewProberConfig(probers.ProberOptions{
-			WG:                  &wg,
-			PromClient:          promC,
-			Endpoint:            e.URI,
-			Tag:                 e.Tag,
-			Retries:             e.Retries,
-			Interval:            e.Interval,
-			TCPTimeout:          e.TCPTimeout,
-			IsOneOff:            isOneOff,
-			ReuseConnection:     e.ReuseConnection,
-			SkipTLSVerification: e.SkipTLSVerification,
+// A nil tracer disables the tracing of probes.
+func newAgent(endpoints []*model.Endpoint, isOneOff bool, promC *metrics.PrometheusClient, store *results.Store, tracer trace.Tracer) *agent {
+	a := &agent{
+		static:   endpoints,
+		runners:  map[model.Key]*runner{},
+		isOneOff: isOneOff,
+		promC:    promC,
+		results:  store,
+		slos:     slo.NewTracker(),
+		tracer:   tracer,
+	}
+
+	a.reconcile()
+
+	return a
+}
+
+// newRunner builds the prober for an endpoint without starting it.
+func (a *agent) newRunner(e *model.Endpoint) (*runner, error) {
+	r := &runner{endpoint: e}
+
+	p := probers.NewProberConfig(probers.ProberOptions{
+		WG:                  &r.wg,
+		PromClient:          a.promC,
+		Tracer:              a.tracer,
+		Endpoint:            e.URI,
+		Tag:                 e.Tag,
+		Labels:              e.Labels,
+		Retries:             e.Retries,
+		Interval:            e.Interval,
+		TCPTimeout:          e.TCPTimeout,
+		IsOneOff:            a.isOneOff,
+		ReuseConnection:     e.ReuseConnection,
+		SkipTLSVerification: e.SkipTLSVerification,
+		Method:              e.Method,
+		Headers:             e.Headers,
+		Body:                e.Body,
+		Expect:              e.Expect,
+		Timeout:             e.Timeout,
+		PhaseTimeouts:       e.PhaseTimeouts,
+		RecordType:          e.RecordType,
+		Resolver:            e.Resolver,
+		ExpectedAnswers:     e.ExpectedAnswers,
+		AnswerPattern:       e.AnswerPattern,
+		ServerName:          e.ServerName,
+		RootCAs:             e.RootCAs,
+		OnResult:            func(res model.Result) { a.observe(e, res) },
+	})
+
+	switch e.ProberType {
+	case "tcp":
+		r.prober = probers.NewTCP(p)
+	case "httpTrace":
+		r.prober = probers.NewHTTPTrace(p)
+	case "dns":
+		r.prober = probers.NewDNS(p)
+	case "tls":
+		r.prober = probers.NewTLS(p)
+	default:
+		return nil, fmt.Errorf("unknown prober type: %s", e.ProberType)
+	}
+
+	r.wg.Add(1)
+
+	return r, nil
+}
+
+// start launches all probers as goroutines with the given context.
+func (a *agent) start(ctx context.Context) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.ctx = ctx
+
+	for _, e := range a.endpoints {
+		a.run(a.runners[e.Key()])
+	}
+}
+
+// run starts a single prober under its own cancelable context.
+func (a *agent) run(r *runner) {
+	ctx, cancel := context.WithCancel(a.ctx)
+	r.cancel = cancel
+
+	log.Debugf("Starting goroutine for prober: %s", r.prober)
+
+	go r.prober.Run(ctx)
+}
+
+// stop cancels a prober and reports whether it was started, in which case
+// its goroutine is yet to exit. A prober that was never started is simply
+// dropped.
+func (r *runner) stop() bool {
+	if r.cancel == nil {
+		return false
+	}
+
+	r.cancel()
+
+	return true
+}
+
+// reconciliation is the work left once reconcile updated the runners: the
+// stopped probers to wait for, the cleanups to run once they exited and the
+// probers replacing or adding to them, started last.
+type reconciliation struct {
+	stopped  []*runner
+	cleanups []func()
+	started  []*runner
+	added    int
+	changed  int
+	removed  int
+}
+
+// reload replaces the static endpoints after a configuration reload.
+func (a *agent) reload(endpoints []*model.Endpoint) {
+	a.reconcileMu.Lock()
+	defer a.reconcileMu.Unlock()
+
+	a.mu.Lock()
+	a.static = endpoints
+	rec := a.reconcile()
+	a.mu.Unlock()
+
+	a.finish(rec)
+
+	log.WithFields(log.Fields{
+		"added":   rec.added,
+		"changed": rec.changed,
+		"removed": rec.removed,
+	}).Info("Configuration reloaded")
+}
+
+// setMeshEndpoints replaces the endpoints of discovered mesh peers.
+func (a *agent) setMeshEndpoints(endpoints []*model.Endpoint) {
+	a.reconcileMu.Lock()
+	defer a.reconcileMu.Unlock()
+
+	a.mu.Lock()
+	a.mesh = endpoints
+	rec := a.reconcile()
+	a.mu.Unlock()
+
+	a.finish(rec)
+
+	log.WithFields(log.Fields{
+		"added":   rec.added,
+		"changed": rec.changed,
+		"removed": rec.removed,
+	}).Info("Mesh peers updated")
+}
+
+// finish completes a reconciliation: it waits for the stopped probers, runs
+// the cleanups and starts the new probers. It must be called without
+// holding a.mu, so the endpoints can still be read while a stopped prober
+// finishes its probe.
+func (a *agent) finish(rec reconciliation) {
+	for _, r := range rec.stopped {
+		r.wg.Wait()
+	}
+
+	for _, cleanup := range rec.cleanups {
+		cleanup()
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, r := range rec.started {
+		if r.cancel == nil {
+			a.run(r)
+		}
+	}
+}
+
+// reconcile brings the runners in line with the static and mesh endpoints.
+// Unchanged endpoints keep running untouched, changed ones are restarted
+// and removed ones are stopped with their metric series and recent results
+// deleted. Probers are only canceled here; waiting for them, the deletions
+// and starting the new probers are left to finish, so the new probers
+// never overlap the ones they replace.
+// New probers are only launched once the agent has been started.
+// The caller must hold a.mu, except during construction.
+func (a *agent) reconcile() reconciliation {
+	var rec reconciliation
+
+	next := make(map[model.Key]*model.Endpoint, len(a.static)+len(a.mesh))
+	nextEndpoints := make([]*model.Endpoint, 0, len(a.static)+len(a.mesh))
+
+	for _, e := range slices.Concat(a.static, a.mesh) {
+		if _, ok := next[e.Key()]; ok {
+			log.Warnf("Skipping duplicate %s endpoint %s with tag %q", e.ProberType, e.URI, e.Tag)
+
+			continue
+		}
+
+		next[e.Key()] = e
+		nextEndpoints = append(nextEndpoints, e)
+	}
+
+	for key, r := range a.runners {
+		if _, ok := next[key]; ok {
+			continue
+		}
+
+		if r.stop() {
+			rec.stopped = append(rec.stopped, r)
+		}
+
+		delete(a.runners, key)
+		rec.cleanups = append(rec.cleanups, func() {
+			a.promC.DeleteEndpointMetrics(metricsTarget(r.endpoint))
+			a.results.Remove(key)
+			a.slos.Remove(key)
+
+			if a.notifier != nil {
+				a.notifier.Remove(key)
+			}
 		})
 
-		var o probers.Prober
+		log.Infof("Removed %s endpoint %s", key.ProberType, key.URI)
 
-		switch e.ProberType {
-		case "tcp":
-			o = probers.NewTCP(p)
-		case "httpTrace":
-			o = probers.NewHTTPTrace(p)
-		default:
-			log.Errorf("Unknown prober type: %s", e.ProberType)
+		rec.removed++
+	}
 
+	for _, e := range nextEndpoints {
+		old, exists := a.runners[e.Key()]
+		if exists && old.endpoint.Equal(e) {
 			continue
 		}
 
-		wg.Add(1)
+		r, err := a.newRunner(e)
+		if err != nil {
+			log.Error(err)
 
-		probersList = append(probersList, o)
+			continue
+		}
+
+		if exists {
+			if old.stop() {
+				rec.stopped = append(rec.stopped, old)
+			}
+
+			// Series carrying the previous label values would otherwise linger
+			if !maps.Equal(old.endpoint.Labels, e.Labels) {
+				rec.cleanups = append(rec.cleanups, func() { a.promC.DeleteEndpointMetrics(metricsTarget(old.endpoint)) })
+			}
+
+			if !old.endpoint.SLO.Equal(e.SLO) {
+				rec.cleanups = append(rec.cleanups, func() {
+					a.slos.Remove(e.Key())
+					a.promC.DeleteSLOMetrics(metricsTarget(old.endpoint))
+				})
+			}
+
+			log.Infof("Restarting changed %s endpoint %s", e.ProberType, e.URI)
+
+			rec.changed++
+		} else {
+			log.Debugf("Added %s endpoint %s", e.ProberType, e.URI)
+
+			rec.added++
+		}
+
+		a.runners[e.Key()] = r
+
+		if a.ctx != nil {
+			rec.started = append(rec.started, r)
+		}
 	}
 
-	return &agent{
-		probers: probersList,
-		wg:      &wg,
-		promC:   promC,
+	a.endpoints = make([]*model.Endpoint, 0, len(nextEndpoints))
+	for _, e := range nextEndpoints {
+		if r, ok := a.runners[e.Key()]; ok {
+			a.endpoints = append(a.endpoints, r.endpoint)
+		}
 	}
+
+	return rec
 }
 
-// start launches all probers as goroutines with the given context.
-func (a *agent) start(ctx context.Context) {
-	for _, prober := range a.probers {
-		log.Debugf("Starting goroutine for prober: %s", prober)
-		go prober.Run(ctx)
+// observe hands the result of a probe to the result store, the SLO tracker
+// and the notifier, and updates the endpoint health gauges.
+func (a *agent) observe(e *model.Endpoint, r model.Result) {
+	a.results.Record(e.Key(), r)
+	a.promC.UpdateEndpointUp(metricsTarget(e), r.Success())
+
+	if status, ok := a.slos.Observe(e, r); ok {
+		a.promC.UpdateSLOMetrics(metricsTarget(e), status.Compliant, status.ErrorBudgetRemaining)
+	}
+
+	if a.notifier != nil {
+		a.notifier.Observe(e, r)
+	}
+}
+
+// metricsTarget returns the metrics target an endpoint's prober exports.
+// Probers use their type lowercased as the prober_type label.
+func metricsTarget(e *model.Endpoint) metrics.Target {
+	return metrics.Target{
+		Domain:     e.URI,
+		ProberType: strings.ToLower(e.ProberType),
+		Tag:        e.Tag,
+		Labels:     e.Labels,
 	}
 }
 
-// wait blocks until all prober goroutines have finished.
+// latestResult returns the most recent result of an endpoint, if any.
+func (a *agent) latestResult(key model.Key) (model.Result, bool) {
+	recent, ok := a.results.Recent(key, 1)
+	if !ok || len(recent) == 0 {
+		return model.Result{}, false
+	}
+
+	return recent[0], true
+}
+
+// currentEndpoints returns the endpoints that are currently probed.
+func (a *agent) currentEndpoints() []*model.Endpoint {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.endpoints
+}
+
+// wait blocks until all prober goroutines have finished. It does not hold
+// a.mu while waiting, so a reload arriving during shutdown does not block
+// behind it; the probers such a reload starts are waited for as well.
 func (a *agent) wait() {
 	log.Debug("Waiting for all agent probers to exit")
-	a.wg.Wait()
+
+	waited := make(map[*runner]bool)
+
+	for {
+		a.mu.Lock()
+
+		var pending []*runner
+
+		for _, r := range a.runners {
+			if !waited[r] {
+				pending = append(pending, r)
+			}
+		}
+
+		a.mu.Unlock()
+
+		if len(pending) == 0 {
+			break
+		}
+
+		for _, r := range pending {
+			r.wg.Wait()
+			waited[r] = true
+		}
+	}
+
 	log.Info("All agent probers have stopped")
 }
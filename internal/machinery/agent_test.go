@@ -1,11 +1,18 @@
 package machinery_test
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/dntosas/astrolavos/internal/machinery"
 	"github.com/dntosas/astrolavos/internal/model"
+	"github.com/dntosas/astrolavos/internal/report"
 )
 
 func TestNewAstrolavos(_ *testing.T) {
@@ -25,5 +32,103 @@ func TestNewAstrolavos(_ *testing.T) {
 		},
 	}
 
-	_ = machinery.NewAstrolavos(3000, endpoints, "localhost", "dev", 0, true)
+	_ = machinery.NewAstrolavos(machinery.Options{
+		Port:        3000,
+		Endpoints:   endpoints,
+		PushGateway: &model.PushGateway{URL: "localhost", Retries: 1},
+		Version:     "dev",
+		IsOneOff:    true,
+	})
+}
+
+func TestOneOffReport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {}))
+	defer srv.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	closed := ln.Addr().String()
+	_ = ln.Close()
+
+	endpoints := []*model.Endpoint{
+		{URI: srv.URL, ProberType: "httpTrace", Interval: time.Second, Retries: 1},
+		{URI: closed, ProberType: "tcp", Interval: time.Second, Retries: 1, TCPTimeout: time.Second},
+	}
+
+	tests := []struct {
+		name        string
+		maxFailures report.Threshold
+		wantErr     bool
+	}{
+		{name: "any failure", wantErr: true},
+		{name: "one failure tolerated", maxFailures: report.Threshold{Count: 1}},
+		{name: "half tolerated", maxFailures: report.Threshold{Percent: 50, IsPercent: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+
+			err := machinery.NewAstrolavos(machinery.Options{
+				Endpoints:   endpoints,
+				IsOneOff:    true,
+				Output:      report.FormatJSON,
+				Stdout:      &stdout,
+				MaxFailures: tt.maxFailures,
+			}).Start()
+
+			if got := errors.Is(err, machinery.ErrProbesFailed); got != tt.wantErr {
+				t.Errorf("expected ErrProbesFailed %v, got %v", tt.wantErr, err)
+			}
+
+			var r report.Report
+			if err := json.Unmarshal(stdout.Bytes(), &r); err != nil {
+				t.Fatalf("failed to decode report %q: %v", stdout.String(), err)
+			}
+
+			if r.Total != 2 || r.Failed != 1 {
+				t.Fatalf("expected 1 of 2 probes to fail, got %+v", r)
+			}
+
+			if !r.Endpoints[0].Success || r.Endpoints[0].StatusCode != "200" || r.Endpoints[0].TotalSeconds <= 0 {
+				t.Errorf("unexpected httpTrace result: %+v", r.Endpoints[0])
+			}
+
+			if r.Endpoints[1].Success || r.Endpoints[1].ErrorCategory != "connection_refused" {
+				t.Errorf("unexpected tcp result: %+v", r.Endpoints[1])
+			}
+		})
+	}
+}
+
+func TestOneOffPushGateway(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {}))
+	defer srv.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	unreachable := "http://" + ln.Addr().String()
+	_ = ln.Close()
+
+	endpoints := []*model.Endpoint{{URI: srv.URL, ProberType: "httpTrace", Interval: time.Second, Retries: 1}}
+
+	// Only a gateway that was asked for makes a failed push fail the run
+	for _, optional := range []bool{true, false} {
+		err := machinery.NewAstrolavos(machinery.Options{
+			Endpoints:   endpoints,
+			PushGateway: &model.PushGateway{URL: unreachable, Retries: 1, Optional: optional},
+			IsOneOff:    true,
+			Stdout:      &bytes.Buffer{},
+		}).Start()
+
+		if got := err != nil; got == optional {
+			t.Errorf("optional gateway %v: unexpected error %v", optional, err)
+		}
+	}
 }
@@ -1,21 +1,32 @@
 package machinery
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/dntosas/astrolavos/internal/alerting"
+	"github.com/dntosas/astrolavos/internal/dashboard"
 	"github.com/dntosas/astrolavos/internal/handlers"
 	"github.com/dntosas/astrolavos/internal/health"
+	"github.com/dntosas/astrolavos/internal/mesh"
 	"github.com/dntosas/astrolavos/internal/metrics"
 	"github.com/dntosas/astrolavos/internal/model"
+	"github.com/dntosas/astrolavos/internal/report"
+	"github.com/dntosas/astrolavos/internal/results"
+	"github.com/dntosas/astrolavos/internal/tracing"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -41,41 +52,167 @@ const (
 	// otherwise the server closes the preStop response before the
 	// drain finishes and the kubelet proceeds to SIGTERM prematurely.
 	httpWriteTimeout = preStopDrainDuration + 15*time.Second
+
+	// notifierCloseTimeout bounds how long one-off mode waits for alerting
+	// webhooks to be delivered before exiting.
+	notifierCloseTimeout = 30 * time.Second
+
+	// metricsPushTimeout bounds how long one-off mode waits for its metrics
+	// to be pushed before exiting.
+	metricsPushTimeout = 30 * time.Second
 )
 
+// Reloader provides updated endpoints to a running server.
+type Reloader interface {
+	// Reload returns the current endpoints, or an error if they are invalid.
+	Reload() ([]*model.Endpoint, error)
+	// Watch calls onChange whenever the endpoints may have changed, until ctx is done.
+	Watch(ctx context.Context, onChange func()) error
+}
+
+// Options configures an Astrolavos instance.
+type Options struct {
+	Port      int
+	Endpoints []*model.Endpoint
+	// PushGateway is the Prometheus push gateway metrics are pushed to in
+	// one-off mode, and periodically if configured. Optional.
+	PushGateway    *model.PushGateway
+	LabelNames     []string
+	ExternalLabels map[string]string
+	Version        string
+	MaxPayloadSize int
+	IsOneOff       bool
+	// Reloader enables hot reloads on SIGHUP and on change. Optional.
+	Reloader Reloader
+	// Mesh enables probing of peers discovered through DNS. Optional.
+	Mesh *model.Mesh
+	// ResultsWindow is the rolling window of the latency matrix.
+	ResultsWindow time.Duration
+	// ResultsHistory is the number of recent results kept per endpoint.
+	ResultsHistory int
+	// Alerting enables webhook notifications on endpoint state changes. Optional.
+	Alerting *model.Alerting
+	// OTLP exports metrics, replacing the push gateway in one-off mode, and
+	// probe traces to an OpenTelemetry collector. Optional.
+	OTLP *model.OTLP
+	// DogStatsD sends probe measurements to a DogStatsD server. Optional.
+	DogStatsD *model.DogStatsD
+	// Output is the format of the report printed at the end of a one-off
+	// run, to Stdout.
+	Output string
+	// Stdout receives the one-off report. Defaults to os.Stdout.
+	Stdout io.Writer
+	// MaxFailures is the number of failed probes a one-off run tolerates.
+	MaxFailures report.Threshold
+}
+
+// ErrProbesFailed is returned by one-off runs in which more probes failed
+// than tolerated.
+var ErrProbesFailed = errors.New("probes failed")
+
 // Astrolavos is the main application struct that orchestrates the agent and HTTP server.
 type Astrolavos struct {
 	port           int
 	agent          *agent
-	endpoints      []*model.Endpoint
 	version        string
 	maxPayloadSize int
 	isOneOff       bool
+	reloader       Reloader
+	discoverer     *mesh.Discoverer
+	source         string
 	health         *health.State
+	// tracerProvider is set if probe traces are exported
+	tracerProvider *sdktrace.TracerProvider
+	output         string
+	stdout         io.Writer
+	maxFailures    report.Threshold
+	// pushOptional is set if a failed one-off push is only logged
+	pushOptional bool
 }
 
 // NewAstrolavos creates a new Astrolavos application instance.
-func NewAstrolavos(port int, endpoints []*model.Endpoint, promPushGateway string, version string, maxPayloadSize int, isOneOff bool) *Astrolavos {
-	promC := metrics.NewPrometheusClient(isOneOff, promPushGateway)
-	a := newAgent(endpoints, isOneOff, promC)
+func NewAstrolavos(opts Options) *Astrolavos {
+	var sinks []metrics.Sink
+
+	if opts.DogStatsD != nil {
+		sink, err := metrics.NewDogStatsDSink(*opts.DogStatsD, opts.ExternalLabels)
+		if err != nil {
+			log.WithError(err).Error("Metrics will not be sent to DogStatsD")
+		} else {
+			sinks = append(sinks, sink)
+
+			log.WithField("address", opts.DogStatsD.Address).Info("Metrics setup - sending to DogStatsD")
+		}
+	}
+
+	promC := metrics.NewPrometheusClient(metrics.Options{
+		IsOneOff:       opts.IsOneOff,
+		PushGateway:    opts.PushGateway,
+		LabelNames:     opts.LabelNames,
+		ExternalLabels: opts.ExternalLabels,
+		OTLP:           opts.OTLP,
+		Sinks:          sinks,
+	})
+
+	var (
+		tracerProvider *sdktrace.TracerProvider
+		tracer         trace.Tracer
+	)
+
+	if opts.OTLP != nil && opts.OTLP.TracesEndpoint != "" {
+		tp, err := tracing.NewTracerProvider(*opts.OTLP)
+		if err != nil {
+			log.WithError(err).Error("Probe traces will not be exported")
+		} else {
+			tracerProvider = tp
+			tracer = tp.Tracer(tracing.ScopeName)
+		}
+	}
+
+	a := newAgent(opts.Endpoints, opts.IsOneOff, promC, results.NewStore(opts.ResultsWindow, opts.ResultsHistory), tracer)
+
+	var discoverer *mesh.Discoverer
+
+	source, err := os.Hostname()
+	if err != nil {
+		log.WithError(err).Warn("Unable to get hostname to identify this instance")
+	}
+
+	if opts.Mesh != nil {
+		discoverer = mesh.NewDiscoverer(*opts.Mesh, nil)
+		source = opts.Mesh.Source
+	}
+
+	if opts.Alerting != nil {
+		a.notifier = alerting.NewNotifier(*opts.Alerting, source)
+	}
+
+	if opts.Stdout == nil {
+		opts.Stdout = os.Stdout
+	}
 
 	return &Astrolavos{
-		port:           port,
+		port:           opts.Port,
 		agent:          a,
-		endpoints:      endpoints,
-		version:        version,
-		maxPayloadSize: maxPayloadSize,
-		isOneOff:       isOneOff,
+		version:        opts.Version,
+		maxPayloadSize: opts.MaxPayloadSize,
+		isOneOff:       opts.IsOneOff,
+		reloader:       opts.Reloader,
+		discoverer:     discoverer,
+		source:         source,
 		health:         health.NewState(),
+		tracerProvider: tracerProvider,
+		output:         cmp.Or(opts.Output, report.FormatText),
+		stdout:         opts.Stdout,
+		maxFailures:    opts.MaxFailures,
+		pushOptional:   opts.PushGateway != nil && opts.PushGateway.Optional,
 	}
 }
 
 // Start launches Astrolavos in the configured mode (server or one-off).
 func (a *Astrolavos) Start() error {
 	if a.isOneOff {
-		a.startOneOffMode()
-
-		return nil
+		return a.startOneOffMode()
 	}
 
 	return a.startServerMode()
@@ -96,6 +233,10 @@ func (a *Astrolavos) startServerMode() error {
 	log.Debug("Starting Agent")
 	a.agent.start(ctx)
 
+	if a.discoverer != nil {
+		go a.discoverer.Run(ctx, a.agent.setMeshEndpoints)
+	}
+
 	server := a.newHTTPServer()
 
 	go func() {
@@ -108,29 +249,84 @@ func (a *Astrolavos) startServerMode() error {
 		log.Info("HTTP server stopped")
 	}()
 
+	reloads := make(chan struct{}, 1)
+	go a.reloadLoop(ctx, reloads)
+
+	go a.agent.promC.PushPeriodically(ctx)
+
 	a.health.SetAlive()
 	a.health.SetReady()
 	log.Info("Application is alive and ready")
 
 	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	for s := range sig {
+		if s != syscall.SIGHUP {
+			break
+		}
 
-	<-sig
+		log.Info("SIGHUP received, reloading configuration")
+		requestReload(reloads)
+	}
 
 	log.Info("Shutdown signal received")
 
 	return a.gracefulShutdown(cancel, server)
 }
 
+// requestReload queues a reload unless one is already pending.
+func requestReload(reloads chan<- struct{}) {
+	select {
+	case reloads <- struct{}{}:
+	default:
+	}
+}
+
+// reloadLoop watches for configuration changes and applies queued reloads
+// one at a time. An invalid configuration is logged and the running
+// probers are left untouched.
+func (a *Astrolavos) reloadLoop(ctx context.Context, reloads chan struct{}) {
+	if a.reloader == nil {
+		return
+	}
+
+	go func() {
+		if err := a.reloader.Watch(ctx, func() { requestReload(reloads) }); err != nil {
+			log.WithError(err).Error("Configuration watcher stopped, reload with SIGHUP instead")
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-reloads:
+			endpoints, err := a.reloader.Reload()
+			if err != nil {
+				log.WithError(err).Error("Rejected configuration reload, keeping the current endpoints")
+
+				continue
+			}
+
+			a.agent.reload(endpoints)
+		}
+	}
+}
+
 // newHTTPServer wires up routes and returns a configured *http.Server.
 func (a *Astrolavos) newHTTPServer() *http.Server {
 	mux := http.NewServeMux()
-	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/metrics", promhttp.HandlerFor(a.agent.promC.Registry(), promhttp.HandlerOpts{EnableOpenMetrics: true}))
 	mux.HandleFunc("/live", health.LiveHandler(a.health))
 	mux.HandleFunc("/ready", health.ReadyHandler(a.health))
 	mux.HandleFunc("/prestop", health.PreStopHandler(a.health, preStopDrainDuration))
 	mux.HandleFunc("/latency", handlers.NewLatencyHandler(a.maxPayloadSize))
-	mux.HandleFunc("/status", handlers.NewStatusHandler(a.version, a.endpoints))
+	mux.HandleFunc("/status", handlers.NewStatusHandler(a.version, a.agent.currentEndpoints, a.agent.results, a.agent.slos))
+	mux.HandleFunc("/api/v1/matrix", handlers.NewMatrixHandler(a.source, a.agent.currentEndpoints, a.agent.results))
+	mux.HandleFunc("/api/v1/results", handlers.NewResultsHandler(a.agent.currentEndpoints, a.agent.results))
+	mux.HandleFunc("/api/v1/stream", handlers.NewStreamHandler(a.agent.results))
+	mux.Handle("/dashboard/", dashboard.Handler("/dashboard/"))
 
 	return &http.Server{
 		Addr:              fmt.Sprintf(":%d", a.port),
@@ -156,9 +352,24 @@ func (a *Astrolavos) gracefulShutdown(cancel context.CancelFunc, server *http.Se
 	cancel()
 	a.agent.wait()
 
+	// End result streams, which would otherwise hold the server open
+	a.agent.results.Close()
+
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), httpServerShutdownTimeout)
 	defer shutdownCancel()
 
+	a.closeNotifier(shutdownCtx)
+	a.shutdownTracing(shutdownCtx)
+
+	// Push the measurements made since the last interval
+	if a.agent.promC.PushesPeriodically() {
+		if err := a.agent.promC.PrometheusPush(shutdownCtx); err != nil {
+			log.WithError(err).Error("Failed to push metrics to push gateway")
+		}
+	}
+
+	a.agent.promC.Shutdown(shutdownCtx)
+
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		return fmt.Errorf("HTTP server shutdown failed: %w", err)
 	}
@@ -169,13 +380,72 @@ func (a *Astrolavos) gracefulShutdown(cancel context.CancelFunc, server *http.Se
 	return nil
 }
 
-// startOneOffMode runs all probers once and pushes metrics to the gateway.
-func (a *Astrolavos) startOneOffMode() {
-	defer a.agent.promC.PrometheusPush()
-
+// startOneOffMode runs all probers once, prints the report of their results
+// and pushes metrics to the gateway, or over OTLP when configured. It fails
+// if more probes failed than tolerated or if the push to a gateway that is
+// not optional did.
+func (a *Astrolavos) startOneOffMode() error {
 	ctx := context.Background()
 
+	if a.discoverer != nil {
+		endpoints, err := a.discoverer.Discover(ctx)
+		if err != nil {
+			log.WithError(err).Error("Skipping mesh peers")
+		}
+
+		a.agent.setMeshEndpoints(endpoints)
+	}
+
 	log.Debug("Starting OneOff Agent")
 	a.agent.start(ctx)
 	a.agent.wait()
+
+	closeCtx, closeCancel := context.WithTimeout(ctx, notifierCloseTimeout)
+	defer closeCancel()
+
+	a.closeNotifier(closeCtx)
+
+	r := report.New(a.agent.currentEndpoints(), a.agent.latestResult)
+	if err := r.Write(a.stdout, a.output); err != nil {
+		log.WithError(err).Error("Failed to print the report")
+	}
+
+	pushCtx, pushCancel := context.WithTimeout(ctx, metricsPushTimeout)
+	defer pushCancel()
+
+	a.shutdownTracing(pushCtx)
+
+	var errs []error
+
+	if a.maxFailures.Exceeded(r.Failed, r.Total) {
+		errs = append(errs, fmt.Errorf("%w: %d of %d", ErrProbesFailed, r.Failed, r.Total))
+	}
+
+	if err := a.agent.promC.Push(pushCtx); err != nil {
+		if a.pushOptional {
+			log.WithError(err).Warn("Failed to push metrics to the default push gateway, set ASTROLAVOS_PROM_PUSH_GW to push elsewhere or to an empty value to disable pushing")
+		} else {
+			errs = append(errs, fmt.Errorf("pushing metrics failed: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// closeNotifier waits for pending alerting notifications to be delivered.
+func (a *Astrolavos) closeNotifier(ctx context.Context) {
+	if a.agent.notifier != nil {
+		a.agent.notifier.Close(ctx)
+	}
+}
+
+// shutdownTracing exports the remaining probe spans, if tracing is enabled.
+func (a *Astrolavos) shutdownTracing(ctx context.Context) {
+	if a.tracerProvider == nil {
+		return
+	}
+
+	if err := a.tracerProvider.Shutdown(ctx); err != nil {
+		log.WithError(err).Error("Failed to export probe traces")
+	}
 }
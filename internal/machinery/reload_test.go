@@ -0,0 +1,292 @@
+package machinery //nolint:testpackage // tests inspect the unexported runner set across reloads
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/metrics"
+	"github.com/dntosas/astrolavos/internal/model"
+	"github.com/dntosas/astrolavos/internal/results"
+)
+
+func TestAgentReload(t *testing.T) {
+	kept := &model.Endpoint{URI: "kept.example.com:443", ProberType: "tcp", Interval: time.Hour, Retries: 1}
+	changed := &model.Endpoint{URI: "changed.example.com:443", ProberType: "tcp", Interval: time.Hour, Retries: 1}
+	removed := &model.Endpoint{URI: "http://removed.example.com", ProberType: "httpTrace", Interval: time.Hour, Retries: 1}
+
+	promC := metrics.NewPrometheusClient(metrics.Options{IsOneOff: true})
+	// The hour-long interval never triggers a probe, so seed a series to be deleted
+	promC.UpdateRequestsCounter(metricsTarget(removed), "200")
+
+	store := results.NewStore(0, 0)
+	store.Record(removed.Key(), model.Result{Time: time.Now()})
+
+	a := newAgent([]*model.Endpoint{kept, changed, removed, kept}, false, promC, store, nil)
+
+	if len(a.runners) != 3 {
+		t.Fatalf("expected duplicate endpoint to be skipped, got %d runners", len(a.runners))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a.start(ctx)
+
+	keptRunner := a.runners[kept.Key()]
+	changedRunner := a.runners[changed.Key()]
+
+	keptCopy := *kept
+	changedCopy := *changed
+	changedCopy.Retries = 3
+	added := &model.Endpoint{URI: "added.example.com:443", ProberType: "tcp", Interval: time.Hour, Retries: 1}
+
+	a.reload([]*model.Endpoint{&keptCopy, &changedCopy, added})
+
+	if len(a.runners) != 3 {
+		t.Fatalf("expected 3 runners after reload, got %d", len(a.runners))
+	}
+
+	if a.runners[kept.Key()] != keptRunner {
+		t.Error("expected unchanged endpoint to keep its running prober")
+	}
+
+	if r := a.runners[changed.Key()]; r == changedRunner || r.endpoint.Retries != 3 {
+		t.Error("expected changed endpoint to be restarted with the new settings")
+	}
+
+	if _, ok := a.runners[removed.Key()]; ok {
+		t.Error("expected removed endpoint to be stopped")
+	}
+
+	if _, ok := a.runners[added.Key()]; !ok {
+		t.Error("expected added endpoint to be started")
+	}
+
+	families, err := promC.Registry().Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+
+	for _, mf := range families {
+		if mf.GetName() == "astrolavos_requests_total" && len(mf.GetMetric()) > 0 {
+			t.Error("expected series of removed endpoint to be deleted")
+		}
+	}
+
+	if _, ok := store.Summary(removed.Key()); ok {
+		t.Error("expected results of removed endpoint to be deleted")
+	}
+
+	current := a.currentEndpoints()
+	if len(current) != 3 || current[2] != added {
+		t.Errorf("unexpected current endpoints: %+v", current)
+	}
+
+	cancel()
+	a.wait()
+}
+
+func TestAgentReloadWhileWaiting(t *testing.T) {
+	e := &model.Endpoint{URI: "wait.example.com:443", ProberType: "tcp", Interval: time.Hour, Retries: 1}
+
+	a := newAgent([]*model.Endpoint{e}, false, metrics.NewPrometheusClient(metrics.Options{IsOneOff: true}), results.NewStore(0, 0), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a.start(ctx)
+
+	waited := make(chan struct{})
+
+	go func() {
+		a.wait()
+		close(waited)
+	}()
+
+	// Give wait the time to block on the running prober
+	time.Sleep(50 * time.Millisecond)
+
+	reloaded := make(chan struct{})
+
+	go func() {
+		a.reload(nil)
+		close(reloaded)
+	}()
+
+	select {
+	case <-reloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected reload not to block behind wait")
+	}
+
+	// The reload stopped the only prober
+	select {
+	case <-waited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected wait to return once every prober stopped")
+	}
+}
+
+// blockingProber stands in for a prober in the middle of a probe, which
+// only exits once released.
+type blockingProber struct {
+	wg      *sync.WaitGroup
+	release chan struct{}
+}
+
+func (p blockingProber) String() string { return "blocking prober" }
+
+func (p blockingProber) Run(context.Context) {
+	defer p.wg.Done()
+	<-p.release
+}
+
+func TestAgentReloadDuringProbe(t *testing.T) {
+	e := &model.Endpoint{URI: "slow.example.com:443", ProberType: "tcp", Interval: time.Hour, Retries: 1}
+
+	a := newAgent([]*model.Endpoint{e}, false, metrics.NewPrometheusClient(metrics.Options{IsOneOff: true}), results.NewStore(0, 0), nil)
+
+	r := a.runners[e.Key()]
+	release := make(chan struct{})
+	r.prober = blockingProber{wg: &r.wg, release: release}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a.start(ctx)
+
+	reloaded := make(chan struct{})
+
+	go func() {
+		a.reload(nil)
+		close(reloaded)
+	}()
+
+	// The reload waits for the probe, but the endpoints stay readable
+	read := make(chan []*model.Endpoint)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		read <- a.currentEndpoints()
+	}()
+
+	select {
+	case endpoints := <-read:
+		if len(endpoints) != 0 {
+			t.Errorf("expected the removed endpoint to be gone, got %d endpoints", len(endpoints))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the endpoints to be readable while the reload waits")
+	}
+
+	select {
+	case <-reloaded:
+		t.Fatal("expected the reload to wait for the running probe")
+	default:
+	}
+
+	close(release)
+
+	select {
+	case <-reloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the reload to finish once the probe did")
+	}
+}
+
+func TestAgentSetMeshEndpoints(t *testing.T) {
+	static := &model.Endpoint{URI: "static.example.com:443", ProberType: "tcp", Interval: time.Hour, Retries: 1}
+	peer := func(addr string) *model.Endpoint {
+		return &model.Endpoint{
+			URI:        "http://" + addr + ":3000/latency",
+			ProberType: "httpTrace",
+			Interval:   time.Hour,
+			Retries:    1,
+			Labels:     map[string]string{"source": "eu-1", "destination": addr},
+		}
+	}
+
+	promC := metrics.NewPrometheusClient(metrics.Options{IsOneOff: true, LabelNames: []string{"destination", "source"}})
+	a := newAgent([]*model.Endpoint{static}, false, promC, results.NewStore(0, 0), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a.start(ctx)
+
+	a.setMeshEndpoints([]*model.Endpoint{peer("10.0.0.1"), peer("10.0.0.2")})
+
+	if len(a.runners) != 3 {
+		t.Fatalf("expected static and mesh probers to run together, got %d", len(a.runners))
+	}
+
+	// A configuration reload keeps the mesh peers
+	a.reload([]*model.Endpoint{static})
+
+	a.setMeshEndpoints([]*model.Endpoint{peer("10.0.0.2")})
+
+	if _, ok := a.runners[peer("10.0.0.1").Key()]; ok {
+		t.Error("expected vanished peer to be stopped")
+	}
+
+	if len(a.currentEndpoints()) != 2 {
+		t.Errorf("expected static endpoint and one peer, got %d", len(a.currentEndpoints()))
+	}
+
+	cancel()
+	a.wait()
+}
+
+func TestAgentObserveSLO(t *testing.T) {
+	e := &model.Endpoint{
+		URI:        "slo.example.com:443",
+		ProberType: "tcp",
+		Interval:   time.Hour,
+		Retries:    1,
+		SLO:        &model.SLO{Window: 2 * time.Hour, SuccessRatio: 0.5},
+	}
+
+	promC := metrics.NewPrometheusClient(metrics.Options{IsOneOff: true})
+	a := newAgent([]*model.Endpoint{e}, false, promC, results.NewStore(0, 0), nil)
+
+	a.observe(e, model.Result{Time: time.Now()})
+
+	series := func() map[string]float64 {
+		families, err := promC.Registry().Gather()
+		if err != nil {
+			t.Fatalf("failed to gather metrics: %v", err)
+		}
+
+		values := map[string]float64{}
+
+		for _, mf := range families {
+			for _, m := range mf.GetMetric() {
+				values[mf.GetName()] += m.GetGauge().GetValue()
+			}
+		}
+
+		return values
+	}
+
+	got := series()
+	if got["astrolavos_endpoint_up"] != 1 || got["astrolavos_slo_compliant"] != 1 ||
+		got["astrolavos_slo_error_budget_remaining"] != 1 {
+		t.Errorf("unexpected health gauges: %v", got)
+	}
+
+	withoutSLO := *e
+	withoutSLO.SLO = nil
+
+	a.reload([]*model.Endpoint{&withoutSLO})
+
+	got = series()
+	if _, ok := got["astrolavos_slo_compliant"]; ok {
+		t.Error("expected SLO series to be deleted once the SLO is dropped")
+	}
+
+	if got["astrolavos_endpoint_up"] != 1 {
+		t.Error("expected the up gauge to be kept")
+	}
+}
@@ -0,0 +1,256 @@
+// Package mesh discovers peer astrolavos instances through DNS so they can
+// probe each other without being listed one by one in the configuration.
+package mesh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/model"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// SourceLabel and DestinationLabel are set on every mesh probe's metrics.
+	SourceLabel      = "source"
+	DestinationLabel = "destination"
+)
+
+// Resolver is the subset of *net.Resolver used for discovery, allowing
+// tests to stub DNS.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// Peer is a discovered astrolavos instance.
+type Peer struct {
+	// Name is the peer's identity in the destination label: its address for
+	// A records or its target name for SRV records.
+	Name string
+	Host string
+	Port int
+}
+
+// Discoverer periodically resolves the mesh discovery name into peers.
+type Discoverer struct {
+	cfg        model.Mesh
+	resolver   Resolver
+	localAddrs []net.IP
+	hostname   string
+}
+
+// NewDiscoverer creates a Discoverer. A nil resolver uses the system one.
+func NewDiscoverer(cfg model.Mesh, resolver Resolver) *Discoverer {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.WithError(err).Warn("Unable to get hostname, mesh may probe itself through SRV records")
+	}
+
+	return &Discoverer{
+		cfg:        cfg,
+		resolver:   resolver,
+		localAddrs: localAddrs(),
+		hostname:   hostname,
+	}
+}
+
+// localAddrs returns the addresses of this host, so an instance does not
+// probe itself when it shows up in its own discovery results.
+func localAddrs() []net.IP {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		log.WithError(err).Warn("Unable to list local addresses, mesh may probe itself")
+
+		return nil
+	}
+
+	ips := make([]net.IP, 0, len(addrs))
+
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			ips = append(ips, ipNet.IP)
+		}
+	}
+
+	return ips
+}
+
+// Peers resolves the discovery name. A name that does not exist yields no
+// peers rather than an error, as a headless Service without ready pods
+// returns NXDOMAIN.
+func (d *Discoverer) Peers(ctx context.Context) ([]Peer, error) {
+	var (
+		peers []Peer
+		err   error
+	)
+
+	if d.cfg.RecordType == "SRV" {
+		peers, err = d.srvPeers(ctx)
+	} else {
+		peers, err = d.addrPeers(ctx)
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return []Peer{}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("mesh discovery of %s failed: %w", d.cfg.Discovery, err)
+	}
+
+	slices.SortFunc(peers, func(a, b Peer) int { return strings.Compare(a.Name, b.Name) })
+
+	return peers, nil
+}
+
+// addrPeers returns a peer for every A and AAAA record that is not an
+// address of this host.
+func (d *Discoverer) addrPeers(ctx context.Context) ([]Peer, error) {
+	addrs, err := d.resolver.LookupIPAddr(ctx, d.cfg.Discovery)
+	if err != nil {
+		return nil, err
+	}
+
+	peers := make([]Peer, 0, len(addrs))
+
+	for _, addr := range addrs {
+		if slices.ContainsFunc(d.localAddrs, addr.IP.Equal) {
+			continue
+		}
+
+		peers = append(peers, Peer{Name: addr.IP.String(), Host: addr.IP.String(), Port: d.cfg.Port})
+	}
+
+	return peers, nil
+}
+
+// srvPeers returns a peer for every SRV record whose target is not this
+// host, using the record's port.
+func (d *Discoverer) srvPeers(ctx context.Context) ([]Peer, error) {
+	_, records, err := d.resolver.LookupSRV(ctx, "", "", d.cfg.Discovery)
+	if err != nil {
+		return nil, err
+	}
+
+	peers := make([]Peer, 0, len(records))
+
+	for _, srv := range records {
+		target := strings.TrimSuffix(srv.Target, ".")
+		if d.isLocal(ctx, target) {
+			continue
+		}
+
+		peers = append(peers, Peer{Name: target, Host: target, Port: int(srv.Port)})
+	}
+
+	return peers, nil
+}
+
+// isLocal reports whether an SRV target is this host, by its hostname or
+// its first label, as in the records of a headless Service, or by the
+// addresses it resolves to. A target that does not resolve is kept.
+func (d *Discoverer) isLocal(ctx context.Context, target string) bool {
+	if name, _, _ := strings.Cut(target, "."); d.hostname != "" &&
+		(strings.EqualFold(target, d.hostname) || strings.EqualFold(name, d.hostname)) {
+		return true
+	}
+
+	addrs, err := d.resolver.LookupIPAddr(ctx, target)
+	if err != nil {
+		log.WithError(err).Debugf("Unable to resolve mesh peer %s", target)
+
+		return false
+	}
+
+	return slices.ContainsFunc(addrs, func(addr net.IPAddr) bool {
+		return slices.ContainsFunc(d.localAddrs, addr.IP.Equal)
+	})
+}
+
+// Endpoints returns the httpTrace endpoints probing each peer's /latency
+// handler, labelled with this instance as source and the peer as destination.
+func (d *Discoverer) Endpoints(peers []Peer) []*model.Endpoint {
+	path := "/latency"
+	if d.cfg.PayloadSize > 0 {
+		path += "?payloadSize=" + strconv.Itoa(d.cfg.PayloadSize)
+	}
+
+	endpoints := make([]*model.Endpoint, 0, len(peers))
+
+	for _, p := range peers {
+		endpoints = append(endpoints, &model.Endpoint{
+			URI:        "http://" + net.JoinHostPort(p.Host, strconv.Itoa(p.Port)) + path,
+			Interval:   d.cfg.Interval,
+			Tag:        d.cfg.Tag,
+			Retries:    d.cfg.Retries,
+			ProberType: "httpTrace",
+			TCPTimeout: d.cfg.Timeout,
+			Method:     http.MethodGet,
+			Timeout:    d.cfg.Timeout,
+			Labels: map[string]string{
+				SourceLabel:      d.cfg.Source,
+				DestinationLabel: p.Name,
+			},
+		})
+	}
+
+	return endpoints
+}
+
+// Discover resolves the peers and returns their endpoints.
+func (d *Discoverer) Discover(ctx context.Context) ([]*model.Endpoint, error) {
+	peers, err := d.Peers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.Endpoints(peers), nil
+}
+
+// Run resolves the peers every refresh interval and calls onChange whenever
+// the peer set differs from the previous one. A failed lookup keeps the
+// previous peers so a DNS hiccup does not tear the mesh down. It blocks
+// until ctx is canceled.
+func (d *Discoverer) Run(ctx context.Context, onChange func([]*model.Endpoint)) {
+	ticker := time.NewTicker(d.cfg.RefreshInterval)
+	defer ticker.Stop()
+
+	log.WithField("name", d.cfg.Discovery).Info("Starting mesh discovery")
+
+	var current []Peer
+
+	for {
+		peers, err := d.Peers(ctx)
+
+		switch {
+		case err != nil:
+			log.WithError(err).Warn("Keeping the previous mesh peers")
+		case current == nil || !slices.Equal(peers, current):
+			log.WithField("peers", len(peers)).Info("Mesh peers changed")
+
+			current = peers
+			onChange(d.Endpoints(peers))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package mesh_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/mesh"
+	"github.com/dntosas/astrolavos/internal/model"
+)
+
+// stubResolver answers discovery lookups from fixed records, and lookups
+// of the names in hosts, such as SRV targets, from their own addresses.
+type stubResolver struct {
+	mu    sync.Mutex
+	addrs []string
+	hosts map[string][]string
+	srvs  []*net.SRV
+	err   error
+}
+
+func (s *stubResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	records := s.addrs
+	if hostAddrs, ok := s.hosts[host]; ok {
+		records = hostAddrs
+	}
+
+	addrs := make([]net.IPAddr, 0, len(records))
+	for _, a := range records {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(a)})
+	}
+
+	return addrs, nil
+}
+
+func (s *stubResolver) LookupSRV(_ context.Context, _, _, _ string) (string, []*net.SRV, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return "", s.srvs, s.err
+}
+
+func (s *stubResolver) setAddrs(addrs ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.addrs = addrs
+}
+
+func testMesh() model.Mesh {
+	return model.Mesh{
+		Discovery:       "astrolavos.monitoring.svc",
+		RecordType:      "A",
+		Port:            3000,
+		RefreshInterval: 10 * time.Millisecond,
+		Source:          "eu-1",
+		Interval:        5 * time.Second,
+		Tag:             "mesh",
+		Retries:         1,
+		Timeout:         10 * time.Second,
+	}
+}
+
+func TestDiscover_ARecords(t *testing.T) {
+	resolver := &stubResolver{addrs: []string{"10.0.0.2", "127.0.0.1", "10.0.0.1", "fd00::1"}}
+
+	endpoints, err := mesh.NewDiscoverer(testMesh(), resolver).Discover(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []struct {
+		uri         string
+		destination string
+	}{
+		{"http://10.0.0.1:3000/latency", "10.0.0.1"},
+		{"http://10.0.0.2:3000/latency", "10.0.0.2"},
+		{"http://[fd00::1]:3000/latency", "fd00::1"},
+	}
+
+	if len(endpoints) != len(expected) {
+		t.Fatalf("expected %d endpoints without the local address, got %d", len(expected), len(endpoints))
+	}
+
+	for i, e := range endpoints {
+		if e.URI != expected[i].uri {
+			t.Errorf("expected URI %q, got %q", expected[i].uri, e.URI)
+		}
+
+		if e.ProberType != "httpTrace" || e.Tag != "mesh" || e.Interval != 5*time.Second {
+			t.Errorf("unexpected probe settings: %+v", e)
+		}
+
+		if e.Labels[mesh.SourceLabel] != "eu-1" || e.Labels[mesh.DestinationLabel] != expected[i].destination {
+			t.Errorf("unexpected labels: %v", e.Labels)
+		}
+	}
+}
+
+func TestDiscover_SRVRecords(t *testing.T) {
+	cfg := testMesh()
+	cfg.RecordType = "SRV"
+	cfg.PayloadSize = 1024
+
+	resolver := &stubResolver{srvs: []*net.SRV{
+		{Target: "astrolavos-1.astrolavos.monitoring.svc.", Port: 3001},
+		{Target: "astrolavos-0.astrolavos.monitoring.svc.", Port: 3000},
+	}}
+
+	endpoints, err := mesh.NewDiscoverer(cfg, resolver).Discover(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(endpoints) != 2 {
+		t.Fatalf("expected 2 endpoints, got %d", len(endpoints))
+	}
+
+	if endpoints[0].URI != "http://astrolavos-0.astrolavos.monitoring.svc:3000/latency?payloadSize=1024" {
+		t.Errorf("unexpected URI: %q", endpoints[0].URI)
+	}
+
+	if endpoints[1].Labels[mesh.DestinationLabel] != "astrolavos-1.astrolavos.monitoring.svc" {
+		t.Errorf("unexpected destination: %v", endpoints[1].Labels)
+	}
+}
+
+func TestDiscover_SRVRecordsSkipSelf(t *testing.T) {
+	cfg := testMesh()
+	cfg.RecordType = "SRV"
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Skipf("no hostname: %v", err)
+	}
+
+	resolver := &stubResolver{
+		srvs: []*net.SRV{
+			{Target: hostname + ".astrolavos.monitoring.svc.", Port: 3000},
+			{Target: "astrolavos-local.astrolavos.monitoring.svc.", Port: 3000},
+			{Target: "astrolavos-peer.astrolavos.monitoring.svc.", Port: 3000},
+		},
+		hosts: map[string][]string{
+			"astrolavos-local.astrolavos.monitoring.svc": {"127.0.0.1"},
+			"astrolavos-peer.astrolavos.monitoring.svc":  {"10.0.0.1"},
+		},
+	}
+
+	endpoints, err := mesh.NewDiscoverer(cfg, resolver).Discover(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Neither the target named after this host nor the one resolving to a
+	// local address is probed
+	if len(endpoints) != 1 || endpoints[0].Labels[mesh.DestinationLabel] != "astrolavos-peer.astrolavos.monitoring.svc" {
+		t.Fatalf("expected only the remote peer, got %+v", endpoints)
+	}
+}
+
+func TestDiscover_Errors(t *testing.T) {
+	resolver := &stubResolver{err: &net.DNSError{Err: "no such host", IsNotFound: true}}
+
+	endpoints, err := mesh.NewDiscoverer(testMesh(), resolver).Discover(context.Background())
+	if err != nil || len(endpoints) != 0 {
+		t.Errorf("expected no peers and no error for a missing name, got %v, %v", endpoints, err)
+	}
+
+	resolver = &stubResolver{err: errors.New("server misbehaving")}
+
+	if _, err = mesh.NewDiscoverer(testMesh(), resolver).Discover(context.Background()); err == nil {
+		t.Error("expected lookup failure to be returned")
+	}
+}
+
+func TestDiscoverer_Run(t *testing.T) {
+	resolver := &stubResolver{addrs: []string{"10.0.0.1"}}
+	changes := make(chan []*model.Endpoint, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go mesh.NewDiscoverer(testMesh(), resolver).Run(ctx, func(e []*model.Endpoint) { changes <- e })
+
+	next := func() []*model.Endpoint {
+		t.Helper()
+
+		select {
+		case e := <-changes:
+			return e
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for mesh change")
+
+			return nil
+		}
+	}
+
+	if e := next(); len(e) != 1 {
+		t.Fatalf("expected initial peer set of 1, got %d", len(e))
+	}
+
+	resolver.setAddrs("10.0.0.1", "10.0.0.2")
+
+	if e := next(); len(e) != 2 {
+		t.Fatalf("expected 2 peers after discovery change, got %d", len(e))
+	}
+
+	// Unchanged peers must not trigger a callback
+	select {
+	case e := <-changes:
+		t.Fatalf("unexpected change with identical peers: %d", len(e))
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/dntosas/astrolavos/internal/model"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+)
+
+// Latency types of the DogStatsD sink.
+const (
+	DogStatsDDistribution = "distribution"
+	DogStatsDTiming       = "timing"
+)
+
+// DogStatsDSink sends probe measurements to a DogStatsD server. Metrics are
+// named and tagged like their Prometheus series, so dashboards work with
+// either source.
+type DogStatsDSink struct {
+	client *statsd.Client
+	timing bool
+}
+
+// tagValueReplacer strips the separators of the DogStatsD datagram format
+// from tag values.
+var tagValueReplacer = strings.NewReplacer(",", "_", "|", "_", "#", "_")
+
+// NewDogStatsDSink creates a sink sending to cfg.Address, with constant
+// tags added to every metric.
+func NewDogStatsDSink(cfg model.DogStatsD, constTags map[string]string) (*DogStatsDSink, error) {
+	client, err := statsd.New(cfg.Address, statsd.WithTags(tags(constTags)), statsd.WithoutTelemetry())
+	if err != nil {
+		return nil, fmt.Errorf("creation of DogStatsD client failed: %w", err)
+	}
+
+	return &DogStatsDSink{client: client, timing: cfg.LatencyType == DogStatsDTiming}, nil
+}
+
+// ObserveLatency sends the duration as a distribution in seconds, or as a
+// timer in milliseconds, named astrolavos_<phase>_latency_ms, with the
+// timing latency type.
+func (d *DogStatsDSink) ObserveLatency(t Target, phase string, seconds float64) {
+	if d.timing {
+		_ = d.client.TimeInMilliseconds("astrolavos_"+phase+"_latency_ms", seconds*1000, targetTags(t), 1)
+
+		return
+	}
+
+	_ = d.client.Distribution("astrolavos_"+phase+"_latency_seconds", seconds, targetTags(t), 1)
+}
+
+// CountRequest sends a count of one request.
+func (d *DogStatsDSink) CountRequest(t Target, statusCode string) {
+	_ = d.client.Count("astrolavos_requests_total", 1, targetTags(t, "status_code", BucketStatusCode(statusCode)), 1)
+}
+
+// CountError sends a count of one error.
+func (d *DogStatsDSink) CountError(t Target, category string) {
+	_ = d.client.Count("astrolavos_errors_total", 1, targetTags(t, "error", category), 1)
+}
+
+// Close flushes the buffered metrics and closes the connection.
+func (d *DogStatsDSink) Close() error {
+	if err := d.client.Close(); err != nil {
+		return fmt.Errorf("closing DogStatsD client failed: %w", err)
+	}
+
+	return nil
+}
+
+// targetTags returns the tags of a target followed by the given name and
+// value pairs.
+func targetTags(t Target, pairs ...string) []string {
+	labels := map[string]string{"domain": t.Domain, "tag": t.Tag, "prober_type": t.ProberType}
+	maps.Copy(labels, t.Labels)
+
+	for i := 0; i+1 < len(pairs); i += 2 {
+		labels[pairs[i]] = pairs[i+1]
+	}
+
+	return tags(labels)
+}
+
+// tags formats labels as sorted name:value DogStatsD tags.
+func tags(labels map[string]string) []string {
+	out := make([]string, 0, len(labels))
+
+	for _, name := range slices.Sorted(maps.Keys(labels)) {
+		out = append(out, name+":"+tagValueReplacer.Replace(labels[name]))
+	}
+
+	return out
+}
@@ -0,0 +1,121 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/metrics"
+	"github.com/dntosas/astrolavos/internal/model"
+)
+
+// readDatagrams returns the metric lines received on conn until it stays
+// idle for a moment.
+func readDatagrams(t *testing.T, conn net.PacketConn) []string {
+	t.Helper()
+
+	var lines []string
+
+	buf := make([]byte, 65536)
+
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return lines
+		}
+
+		lines = append(lines, strings.Split(strings.TrimSpace(string(buf[:n])), "\n")...)
+	}
+}
+
+func runDogStatsDSink(t *testing.T, latencyType string) []string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	sink, err := metrics.NewDogStatsDSink(model.DogStatsD{Address: conn.LocalAddr().String(), LatencyType: latencyType}, map[string]string{"cluster": "eu-1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	p := metrics.NewPrometheusClient(metrics.Options{IsOneOff: true, Sinks: []metrics.Sink{sink}})
+	target := metrics.Target{Domain: "https://example.com", Tag: "web", ProberType: "httptrace", Labels: map[string]string{"team": "net"}}
+
+	p.UpdateDNSHistogram(target, 0.002)
+	p.UpdateTotalHistogram(target, 0.25)
+	p.UpdateRequestsCounter(target, "503")
+	p.UpdateErrorsCounter(target, context.DeadlineExceeded)
+	p.Shutdown(context.Background())
+
+	return readDatagrams(t, conn)
+}
+
+func TestDogStatsDSink_Distribution(t *testing.T) {
+	lines := runDogStatsDSink(t, metrics.DogStatsDDistribution)
+
+	tags := "cluster:eu-1,domain:https://example.com,prober_type:httptrace,tag:web,team:net"
+
+	for _, want := range []string{
+		"astrolavos_dns_latency_seconds:0.002|d|#" + tags,
+		"astrolavos_total_latency_seconds:0.25|d|#" + tags,
+		"astrolavos_requests_total:1|c|#cluster:eu-1,domain:https://example.com,prober_type:httptrace,status_code:5xx,tag:web,team:net",
+		"astrolavos_errors_total:1|c|#cluster:eu-1,domain:https://example.com,error:timeout,prober_type:httptrace,tag:web,team:net",
+	} {
+		if !slices.Contains(lines, want) {
+			t.Errorf("expected %q, got %q", want, lines)
+		}
+	}
+}
+
+func TestDogStatsDSink_Timing(t *testing.T) {
+	lines := runDogStatsDSink(t, metrics.DogStatsDTiming)
+
+	want := "astrolavos_total_latency_ms:250.000000|ms|#cluster:eu-1,domain:https://example.com,prober_type:httptrace,tag:web,team:net"
+	if !slices.Contains(lines, want) {
+		t.Errorf("expected %q, got %q", want, lines)
+	}
+}
+
+// recordingSink counts the measurements it receives.
+type recordingSink struct {
+	latencies, requests, errors int
+	closed                      bool
+}
+
+func (r *recordingSink) ObserveLatency(metrics.Target, string, float64) { r.latencies++ }
+func (r *recordingSink) CountRequest(metrics.Target, string)            { r.requests++ }
+func (r *recordingSink) CountError(metrics.Target, string)              { r.errors++ }
+
+func (r *recordingSink) Close() error {
+	r.closed = true
+
+	return nil
+}
+
+func TestSinks_FanOut(t *testing.T) {
+	sinks := []*recordingSink{{}, {}}
+	p := metrics.NewPrometheusClient(metrics.Options{IsOneOff: true, Sinks: []metrics.Sink{sinks[0], sinks[1]}})
+	target := metrics.Target{Domain: "example.com:443", ProberType: "tcp"}
+
+	p.UpdateConnHistogram(target, 0.01)
+	p.UpdateTotalHistogram(target, 0.02)
+	p.UpdateRequestsCounter(target, "")
+	p.UpdateErrorsCounter(target, errors.New("connection refused"))
+	p.Shutdown(context.Background())
+
+	for i, s := range sinks {
+		if s.latencies != 2 || s.requests != 1 || s.errors != 1 || !s.closed {
+			t.Errorf("sink %d: unexpected measurements %+v", i, s)
+		}
+	}
+}
@@ -3,164 +3,560 @@ package metrics
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"fmt"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/model"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/push"
 	log "github.com/sirupsen/logrus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 )
 
-var (
-	// timeBuckets covers the practical latency range (1ms – 5s) with fewer
-	// buckets to limit the number of time series exposed to scrapers.
-	timeBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
-
-	dnsLatencyHistogram = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "astrolavos_dns_latency_seconds",
-			Help:    "Histogram of DNS resolution latency in seconds",
-			Buckets: timeBuckets,
-		},
-		[]string{"domain", "tag", "prober_type"},
-	)
-
-	connLatencyHistogram = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "astrolavos_conn_latency_seconds",
-			Help:    "Histogram of TCP connection latency in seconds",
-			Buckets: timeBuckets,
-		},
-		[]string{"domain", "tag", "prober_type"},
-	)
-
-	tlsLatencyHistogram = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "astrolavos_tls_latency_seconds",
-			Help:    "Histogram of TLS handshake latency in seconds",
-			Buckets: timeBuckets,
-		},
-		[]string{"domain", "tag", "prober_type"},
-	)
-
-	gotConnLatencyHistogram = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "astrolavos_gotconn_latency_seconds",
-			Help:    "Histogram of time to obtain a connection in seconds",
-			Buckets: timeBuckets,
-		},
-		[]string{"domain", "tag", "prober_type"},
-	)
-
-	firstByteLatencyHistogram = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "astrolavos_firstbyte_latency_seconds",
-			Help:    "Histogram of time to first byte in seconds",
-			Buckets: timeBuckets,
-		},
-		[]string{"domain", "tag", "prober_type"},
-	)
-
-	totalLatencyHistogram = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "astrolavos_total_latency_seconds",
-			Help:    "Histogram of total request latency in seconds",
-			Buckets: timeBuckets,
-		},
-		[]string{"domain", "tag", "prober_type"},
-	)
-
-	totalRequestsCounter = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "astrolavos_requests_total",
-			Help: "Total number of probe requests made by Astrolavos",
-		},
-		[]string{"domain", "tag", "status_code", "prober_type"},
-	)
-
-	totalErrorsCounter = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "astrolavos_errors_total",
-			Help: "Total number of probe errors encountered by Astrolavos",
-		},
-		[]string{"domain", "tag", "error", "prober_type"},
-	)
-)
+// timeBuckets covers the practical latency range (1ms – 5s) with fewer
+// buckets to limit the number of time series exposed to scrapers.
+var timeBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
 
 // PrometheusClient holds state needed for Prometheus metric collection and pushing.
+// Each client owns its registry, so several clients can live in one process.
 type PrometheusClient struct {
-	pusher *push.Pusher
-}
-
-// NewPrometheusClient initializes a new Prometheus client and registers all metrics.
-func NewPrometheusClient(_ bool, promPushGateway string) *PrometheusClient {
-	prometheus.MustRegister(dnsLatencyHistogram)
-	prometheus.MustRegister(connLatencyHistogram)
-	prometheus.MustRegister(tlsLatencyHistogram)
-	prometheus.MustRegister(gotConnLatencyHistogram)
-	prometheus.MustRegister(firstByteLatencyHistogram)
-	prometheus.MustRegister(totalLatencyHistogram)
-	prometheus.MustRegister(totalRequestsCounter)
-	prometheus.MustRegister(totalErrorsCounter)
-
-	pusher := push.New(promPushGateway, "astrolavos").
-		Collector(dnsLatencyHistogram).
-		Collector(connLatencyHistogram).
-		Collector(tlsLatencyHistogram).
-		Collector(gotConnLatencyHistogram).
-		Collector(firstByteLatencyHistogram).
-		Collector(totalLatencyHistogram).
-		Collector(totalRequestsCounter).
-		Collector(totalErrorsCounter)
+	registry    *prometheus.Registry
+	pusher      *push.Pusher
+	pushGateway model.PushGateway
+	otlp        *sdkmetric.MeterProvider
+	sinks       []Sink
+	labelNames  []string
+
+	dnsLatencyHistogram       *prometheus.HistogramVec
+	connLatencyHistogram      *prometheus.HistogramVec
+	tlsLatencyHistogram       *prometheus.HistogramVec
+	gotConnLatencyHistogram   *prometheus.HistogramVec
+	firstByteLatencyHistogram *prometheus.HistogramVec
+	totalLatencyHistogram     *prometheus.HistogramVec
+	totalRequestsCounter      *prometheus.CounterVec
+	totalErrorsCounter        *prometheus.CounterVec
+	dnsQueryLatencyHistogram  *prometheus.HistogramVec
+	dnsResponsesCounter       *prometheus.CounterVec
+	dnsAnswersGauge           *prometheus.GaugeVec
+	tlsCertExpiryGauge        *prometheus.GaugeVec
+	tlsInfoGauge              *prometheus.GaugeVec
+	tlsChainVerifiedGauge     *prometheus.GaugeVec
+	tcpRTTHistogram           *prometheus.HistogramVec
+	tcpRTTVarHistogram        *prometheus.HistogramVec
+	tcpRetransmitsCounter     *prometheus.CounterVec
+	tcpCongestionWindowGauge  *prometheus.GaugeVec
+	tcpMSSGauge               *prometheus.GaugeVec
+	endpointUpGauge           *prometheus.GaugeVec
+	sloCompliantGauge         *prometheus.GaugeVec
+	sloErrorBudgetGauge       *prometheus.GaugeVec
+	benchLatencyGauge         *prometheus.GaugeVec
+	benchThroughputGauge      *prometheus.GaugeVec
+}
+
+// Options configures a PrometheusClient.
+type Options struct {
+	IsOneOff bool
+	// PushGateway is the Prometheus push gateway metrics are pushed to.
+	// Optional.
+	PushGateway *model.PushGateway
+	// LabelNames are the custom endpoint label names added to every vector.
+	// Targets without a value for one of them export it empty.
+	LabelNames []string
+	// ExternalLabels are constant labels added to every exported series.
+	ExternalLabels map[string]string
+	// OTLP exports the metrics to an OpenTelemetry collector too. Optional.
+	OTLP *model.OTLP
+	// Sinks receive probe measurements besides the registry. Optional.
+	Sinks []Sink
+}
+
+// Target identifies the endpoint a measurement belongs to.
+type Target struct {
+	Domain     string
+	ProberType string
+	Tag        string
+	Labels     map[string]string
+}
+
+// NewPrometheusClient initializes a new Prometheus client and registers all
+// metrics with its own registry. Outside one-off mode the Go runtime and
+// process collectors are registered too, as the registry is served on /metrics.
+func NewPrometheusClient(opts Options) *PrometheusClient {
+	p := &PrometheusClient{
+		registry:   prometheus.NewRegistry(),
+		sinks:      opts.Sinks,
+		labelNames: opts.LabelNames,
+		dnsLatencyHistogram: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "astrolavos_dns_latency_seconds",
+				Help:    "Histogram of DNS resolution latency in seconds",
+				Buckets: timeBuckets,
+			},
+			append([]string{"domain", "tag", "prober_type"}, opts.LabelNames...),
+		),
+
+		connLatencyHistogram: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "astrolavos_conn_latency_seconds",
+				Help:    "Histogram of TCP connection latency in seconds",
+				Buckets: timeBuckets,
+			},
+			append([]string{"domain", "tag", "prober_type"}, opts.LabelNames...),
+		),
+
+		tlsLatencyHistogram: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "astrolavos_tls_latency_seconds",
+				Help:    "Histogram of TLS handshake latency in seconds",
+				Buckets: timeBuckets,
+			},
+			append([]string{"domain", "tag", "prober_type"}, opts.LabelNames...),
+		),
+
+		gotConnLatencyHistogram: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "astrolavos_gotconn_latency_seconds",
+				Help:    "Histogram of time to obtain a connection in seconds",
+				Buckets: timeBuckets,
+			},
+			append([]string{"domain", "tag", "prober_type"}, opts.LabelNames...),
+		),
+
+		firstByteLatencyHistogram: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "astrolavos_firstbyte_latency_seconds",
+				Help:    "Histogram of time to first byte in seconds",
+				Buckets: timeBuckets,
+			},
+			append([]string{"domain", "tag", "prober_type"}, opts.LabelNames...),
+		),
+
+		totalLatencyHistogram: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "astrolavos_total_latency_seconds",
+				Help:    "Histogram of total request latency in seconds",
+				Buckets: timeBuckets,
+			},
+			append([]string{"domain", "tag", "prober_type"}, opts.LabelNames...),
+		),
+
+		totalRequestsCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "astrolavos_requests_total",
+				Help: "Total number of probe requests made by Astrolavos",
+			},
+			append([]string{"domain", "tag", "status_code", "prober_type"}, opts.LabelNames...),
+		),
+
+		totalErrorsCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "astrolavos_errors_total",
+				Help: "Total number of probe errors encountered by Astrolavos",
+			},
+			append([]string{"domain", "tag", "error", "prober_type"}, opts.LabelNames...),
+		),
+
+		dnsQueryLatencyHistogram: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "astrolavos_dns_query_latency_seconds",
+				Help:    "Histogram of DNS query latency per record type in seconds",
+				Buckets: timeBuckets,
+			},
+			append([]string{"domain", "tag", "record_type"}, opts.LabelNames...),
+		),
+
+		dnsResponsesCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "astrolavos_dns_responses_total",
+				Help: "Total number of DNS responses received by Astrolavos, by response code",
+			},
+			append([]string{"domain", "tag", "record_type", "rcode"}, opts.LabelNames...),
+		),
+
+		dnsAnswersGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "astrolavos_dns_answers",
+				Help: "Number of answers returned by the last DNS query",
+			},
+			append([]string{"domain", "tag", "record_type"}, opts.LabelNames...),
+		),
+
+		tlsCertExpiryGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "astrolavos_tls_cert_expiry_days",
+				Help: "Days until expiry of each certificate presented by the server (depth 0 is the leaf)",
+			},
+			append([]string{"domain", "tag", "prober_type", "depth", "common_name"}, opts.LabelNames...),
+		),
+
+		tlsInfoGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "astrolavos_tls_info",
+				Help: "Negotiated TLS protocol version and cipher suite of the last handshake",
+			},
+			append([]string{"domain", "tag", "prober_type", "version", "cipher_suite"}, opts.LabelNames...),
+		),
+
+		tlsChainVerifiedGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "astrolavos_tls_chain_verified",
+				Help: "Whether the certificate chain of the last handshake verified against the trusted roots (1) or not (0)",
+			},
+			append([]string{"domain", "tag", "prober_type"}, opts.LabelNames...),
+		),
+
+		tcpRTTHistogram: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "astrolavos_tcp_rtt_seconds",
+				Help:    "Histogram of the kernel smoothed round-trip time of probe connections in seconds",
+				Buckets: timeBuckets,
+			},
+			append([]string{"domain", "tag", "prober_type"}, opts.LabelNames...),
+		),
+
+		tcpRTTVarHistogram: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "astrolavos_tcp_rtt_variance_seconds",
+				Help:    "Histogram of the kernel round-trip time variance of probe connections in seconds",
+				Buckets: timeBuckets,
+			},
+			append([]string{"domain", "tag", "prober_type"}, opts.LabelNames...),
+		),
+
+		tcpRetransmitsCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "astrolavos_tcp_retransmits_total",
+				Help: "Total number of TCP segments retransmitted on new probe connections",
+			},
+			append([]string{"domain", "tag", "prober_type"}, opts.LabelNames...),
+		),
+
+		tcpCongestionWindowGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "astrolavos_tcp_congestion_window",
+				Help: "Sending congestion window in segments of the last probe connection",
+			},
+			append([]string{"domain", "tag", "prober_type"}, opts.LabelNames...),
+		),
+
+		tcpMSSGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "astrolavos_tcp_mss_bytes",
+				Help: "Sending maximum segment size in bytes of the last probe connection",
+			},
+			append([]string{"domain", "tag", "prober_type"}, opts.LabelNames...),
+		),
+
+		endpointUpGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "astrolavos_endpoint_up",
+				Help: "Whether the last probe of the endpoint succeeded (1) or not (0)",
+			},
+			append([]string{"domain", "tag", "prober_type"}, opts.LabelNames...),
+		),
+
+		sloCompliantGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "astrolavos_slo_compliant",
+				Help: "Whether the endpoint meets every objective of its SLO over the SLO window (1) or not (0)",
+			},
+			append([]string{"domain", "tag", "prober_type"}, opts.LabelNames...),
+		),
+
+		sloErrorBudgetGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "astrolavos_slo_error_budget_remaining",
+				Help: "Share of the error budget of an SLO objective left over the SLO window, negative once overspent",
+			},
+			append([]string{"domain", "tag", "prober_type", "objective"}, opts.LabelNames...),
+		),
+
+		benchLatencyGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "astrolavos_bench_latency_seconds",
+				Help: "Latency statistic of a phase over the successful probes of the last benchmark in seconds",
+			},
+			append([]string{"domain", "tag", "prober_type", "phase", "statistic"}, opts.LabelNames...),
+		),
+
+		benchThroughputGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "astrolavos_bench_throughput",
+				Help: "Probes completed per second by the last benchmark",
+			},
+			append([]string{"domain", "tag", "prober_type"}, opts.LabelNames...),
+		),
+	}
+
+	registerer := prometheus.WrapRegistererWith(opts.ExternalLabels, p.registry)
+	registerer.MustRegister(p.metricVecs()...)
+
+	if !opts.IsOneOff {
+		registerer.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		)
+	}
+
+	if opts.PushGateway != nil {
+		p.pusher = newPusher(*opts.PushGateway, p.registry)
+		p.pushGateway = *opts.PushGateway
+	}
 
 	log.Info("Metrics setup - scrape /metrics")
 
-	return &PrometheusClient{pusher: pusher}
+	if opts.OTLP != nil && opts.OTLP.Endpoint != "" {
+		provider, err := newOTLPProvider(*opts.OTLP, p.registry)
+		if err != nil {
+			log.WithError(err).Error("Metrics will not be exported over OTLP")
+		} else {
+			p.otlp = provider
+
+			log.WithField("endpoint", opts.OTLP.Endpoint).Infof("Metrics setup - exporting over OTLP/%s", opts.OTLP.Protocol)
+		}
+	}
+
+	return p
+}
+
+// metricVecs returns every metric vector owned by the client.
+func (p *PrometheusClient) metricVecs() []prometheus.Collector {
+	return []prometheus.Collector{
+		p.dnsLatencyHistogram,
+		p.connLatencyHistogram,
+		p.tlsLatencyHistogram,
+		p.gotConnLatencyHistogram,
+		p.firstByteLatencyHistogram,
+		p.totalLatencyHistogram,
+		p.totalRequestsCounter,
+		p.totalErrorsCounter,
+		p.dnsQueryLatencyHistogram,
+		p.dnsResponsesCounter,
+		p.dnsAnswersGauge,
+		p.tlsCertExpiryGauge,
+		p.tlsInfoGauge,
+		p.tlsChainVerifiedGauge,
+		p.tcpRTTHistogram,
+		p.tcpRTTVarHistogram,
+		p.tcpRetransmitsCounter,
+		p.tcpCongestionWindowGauge,
+		p.tcpMSSGauge,
+		p.endpointUpGauge,
+		p.sloCompliantGauge,
+		p.sloErrorBudgetGauge,
+		p.benchLatencyGauge,
+		p.benchThroughputGauge,
+	}
+}
+
+// labelValues returns the given values followed by the target's value for
+// every custom label name, in the order the vectors were created with.
+func (p *PrometheusClient) labelValues(t Target, values ...string) []string {
+	for _, name := range p.labelNames {
+		values = append(values, t.Labels[name])
+	}
+
+	return values
+}
+
+// endpointLabels returns the labels matching every series of the target
+// in vectors carrying a prober_type label.
+func endpointLabels(t Target) prometheus.Labels {
+	return prometheus.Labels{"domain": t.Domain, "tag": t.Tag, "prober_type": t.ProberType}
+}
+
+// Registry returns the registry holding the client's metrics, for serving
+// them over HTTP or gathering them in tests.
+func (p *PrometheusClient) Registry() *prometheus.Registry {
+	return p.registry
 }
 
 // UpdateDNSHistogram records a DNS resolution duration observation.
-func (p *PrometheusClient) UpdateDNSHistogram(domain, proberType, tag string, duration float64) {
-	dnsLatencyHistogram.WithLabelValues(domain, tag, proberType).Observe(duration)
+func (p *PrometheusClient) UpdateDNSHistogram(t Target, duration float64) {
+	p.dnsLatencyHistogram.WithLabelValues(p.labelValues(t, t.Domain, t.Tag, t.ProberType)...).Observe(duration)
+	p.observeLatency(t, PhaseDNS, duration)
 	log.Debug("Updated metric for DNS latency")
 }
 
 // UpdateConnHistogram records a TCP connection duration observation.
-func (p *PrometheusClient) UpdateConnHistogram(domain, proberType, tag string, duration float64) {
-	connLatencyHistogram.WithLabelValues(domain, tag, proberType).Observe(duration)
+func (p *PrometheusClient) UpdateConnHistogram(t Target, duration float64) {
+	p.connLatencyHistogram.WithLabelValues(p.labelValues(t, t.Domain, t.Tag, t.ProberType)...).Observe(duration)
+	p.observeLatency(t, PhaseConn, duration)
 	log.Debug("Updated metric for connection latency")
 }
 
 // UpdateTLSHistogram records a TLS handshake duration observation.
-func (p *PrometheusClient) UpdateTLSHistogram(domain, proberType, tag string, duration float64) {
-	tlsLatencyHistogram.WithLabelValues(domain, tag, proberType).Observe(duration)
+func (p *PrometheusClient) UpdateTLSHistogram(t Target, duration float64) {
+	p.tlsLatencyHistogram.WithLabelValues(p.labelValues(t, t.Domain, t.Tag, t.ProberType)...).Observe(duration)
+	p.observeLatency(t, PhaseTLS, duration)
 	log.Debug("Updated metric for TLS latency")
 }
 
 // UpdateGotConnHistogram records the time to obtain a connection.
-func (p *PrometheusClient) UpdateGotConnHistogram(domain, proberType, tag string, duration float64) {
-	gotConnLatencyHistogram.WithLabelValues(domain, tag, proberType).Observe(duration)
+func (p *PrometheusClient) UpdateGotConnHistogram(t Target, duration float64) {
+	p.gotConnLatencyHistogram.WithLabelValues(p.labelValues(t, t.Domain, t.Tag, t.ProberType)...).Observe(duration)
+	p.observeLatency(t, PhaseGotConn, duration)
 	log.Debug("Updated metric for GotConnection latency")
 }
 
 // UpdateFirstByteHistogram records the time to first byte.
-func (p *PrometheusClient) UpdateFirstByteHistogram(domain, proberType, tag string, duration float64) {
-	firstByteLatencyHistogram.WithLabelValues(domain, tag, proberType).Observe(duration)
+func (p *PrometheusClient) UpdateFirstByteHistogram(t Target, duration float64) {
+	p.firstByteLatencyHistogram.WithLabelValues(p.labelValues(t, t.Domain, t.Tag, t.ProberType)...).Observe(duration)
+	p.observeLatency(t, PhaseFirstByte, duration)
 	log.Debug("Updated metric for FirstByte latency")
 }
 
 // UpdateTotalHistogram records the total request duration.
-func (p *PrometheusClient) UpdateTotalHistogram(domain, proberType, tag string, duration float64) {
-	totalLatencyHistogram.WithLabelValues(domain, tag, proberType).Observe(duration)
+func (p *PrometheusClient) UpdateTotalHistogram(t Target, duration float64) {
+	p.UpdateTotalHistogramWithTrace(t, duration, "")
+}
+
+// UpdateTotalHistogramWithTrace records the total request duration with the
+// ID of the probe's trace as an exemplar, unless it is empty.
+func (p *PrometheusClient) UpdateTotalHistogramWithTrace(t Target, duration float64, traceID string) {
+	observer := p.totalLatencyHistogram.WithLabelValues(p.labelValues(t, t.Domain, t.Tag, t.ProberType)...)
+
+	if eo, ok := observer.(prometheus.ExemplarObserver); ok && traceID != "" {
+		eo.ObserveWithExemplar(duration, prometheus.Labels{"trace_id": traceID})
+	} else {
+		observer.Observe(duration)
+	}
+
+	p.observeLatency(t, PhaseTotal, duration)
+
 	log.Debug("Updated metric for total latency")
 }
 
 // UpdateRequestsCounter increments the total requests counter.
 // The status code is bucketed (e.g. "2xx") to limit label cardinality.
-func (p *PrometheusClient) UpdateRequestsCounter(domain, proberType, tag, statusCode string) {
-	totalRequestsCounter.WithLabelValues(domain, tag, BucketStatusCode(statusCode), proberType).Inc()
+func (p *PrometheusClient) UpdateRequestsCounter(t Target, statusCode string) {
+	p.totalRequestsCounter.WithLabelValues(p.labelValues(t, t.Domain, t.Tag, BucketStatusCode(statusCode), t.ProberType)...).Inc()
+
+	for _, s := range p.sinks {
+		s.CountRequest(t, statusCode)
+	}
+
 	log.Debug("Updated metric for total requests counter")
 }
 
+// UpdateDNSQueryMetrics records the outcome of a single DNS query: its response
+// code, the number of answers and, unless the query timed out, its latency.
+func (p *PrometheusClient) UpdateDNSQueryMetrics(t Target, recordType, rcode string, answers int, duration float64) {
+	p.dnsResponsesCounter.WithLabelValues(p.labelValues(t, t.Domain, t.Tag, recordType, rcode)...).Inc()
+	p.dnsAnswersGauge.WithLabelValues(p.labelValues(t, t.Domain, t.Tag, recordType)...).Set(float64(answers))
+
+	if rcode != "TIMEOUT" {
+		p.dnsQueryLatencyHistogram.WithLabelValues(p.labelValues(t, t.Domain, t.Tag, recordType)...).Observe(duration)
+	}
+
+	log.Debug("Updated metrics for DNS query")
+}
+
+// UpdateTLSMetrics records the certificate expiry of every certificate in the
+// presented chain together with the negotiated version and cipher suite.
+// Series from the previous handshake are dropped first so rotated
+// certificates do not leave stale entries behind.
+func (p *PrometheusClient) UpdateTLSMetrics(t Target, state tls.ConnectionState) {
+	p.tlsCertExpiryGauge.DeletePartialMatch(endpointLabels(t))
+	p.tlsInfoGauge.DeletePartialMatch(endpointLabels(t))
+
+	for depth, cert := range state.PeerCertificates {
+		days := time.Until(cert.NotAfter).Hours() / 24
+		p.tlsCertExpiryGauge.WithLabelValues(p.labelValues(t, t.Domain, t.Tag, t.ProberType, strconv.Itoa(depth), cert.Subject.CommonName)...).Set(days)
+	}
+
+	p.tlsInfoGauge.WithLabelValues(p.labelValues(t, t.Domain, t.Tag, t.ProberType, tls.VersionName(state.Version), tls.CipherSuiteName(state.CipherSuite))...).Set(1)
+	log.Debug("Updated metrics for TLS connection state")
+}
+
+// UpdateTLSChainVerified records whether the presented chain verified.
+func (p *PrometheusClient) UpdateTLSChainVerified(t Target, verified bool) {
+	p.tlsChainVerifiedGauge.WithLabelValues(p.labelValues(t, t.Domain, t.Tag, t.ProberType)...).Set(boolValue(verified))
+	log.Debug("Updated metric for TLS chain verification")
+}
+
+// UpdateEndpointUp records whether the last probe of the endpoint succeeded.
+func (p *PrometheusClient) UpdateEndpointUp(t Target, up bool) {
+	p.endpointUpGauge.WithLabelValues(p.labelValues(t, t.Domain, t.Tag, t.ProberType)...).Set(boolValue(up))
+	log.Debug("Updated metric for endpoint up")
+}
+
+// UpdateSLOMetrics records the SLO compliance of an endpoint and the error
+// budget remaining of each of its objectives.
+func (p *PrometheusClient) UpdateSLOMetrics(t Target, compliant bool, budgets map[string]float64) {
+	p.sloCompliantGauge.WithLabelValues(p.labelValues(t, t.Domain, t.Tag, t.ProberType)...).Set(boolValue(compliant))
+
+	for objective, remaining := range budgets {
+		p.sloErrorBudgetGauge.WithLabelValues(p.labelValues(t, t.Domain, t.Tag, t.ProberType, objective)...).Set(remaining)
+	}
+
+	log.Debug("Updated metrics for SLO")
+}
+
+// DeleteSLOMetrics removes the SLO series of an endpoint whose objectives
+// changed or were dropped.
+func (p *PrometheusClient) DeleteSLOMetrics(t Target) {
+	p.sloCompliantGauge.DeletePartialMatch(endpointLabels(t))
+	p.sloErrorBudgetGauge.DeletePartialMatch(endpointLabels(t))
+}
+
+// UpdateBenchLatency records a latency statistic of a phase, such as its
+// mean or 99th percentile, over a benchmark of the endpoint.
+func (p *PrometheusClient) UpdateBenchLatency(t Target, phase, statistic string, seconds float64) {
+	p.benchLatencyGauge.WithLabelValues(p.labelValues(t, t.Domain, t.Tag, t.ProberType, phase, statistic)...).Set(seconds)
+	log.Debug("Updated metric for benchmark latency")
+}
+
+// UpdateBenchThroughput records the probes per second a benchmark of the
+// endpoint completed.
+func (p *PrometheusClient) UpdateBenchThroughput(t Target, perSecond float64) {
+	p.benchThroughputGauge.WithLabelValues(p.labelValues(t, t.Domain, t.Tag, t.ProberType)...).Set(perSecond)
+	log.Debug("Updated metric for benchmark throughput")
+}
+
+// boolValue returns 1 for true and 0 for false.
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+// TCPInfo holds the kernel TCP statistics of a probe connection.
+type TCPInfo struct {
+	RTT              time.Duration
+	RTTVar           time.Duration
+	Retransmits      uint32
+	CongestionWindow uint32
+	MSS              uint32
+}
+
+// UpdateTCPInfoMetrics records the kernel TCP statistics of a probe connection.
+// Retransmits are cumulative over the life of a connection, so they are only
+// counted for connections opened by the probe itself.
+func (p *PrometheusClient) UpdateTCPInfoMetrics(t Target, info *TCPInfo, newConn bool) {
+	values := p.labelValues(t, t.Domain, t.Tag, t.ProberType)
+
+	p.tcpRTTHistogram.WithLabelValues(values...).Observe(info.RTT.Seconds())
+	p.tcpRTTVarHistogram.WithLabelValues(values...).Observe(info.RTTVar.Seconds())
+	p.tcpCongestionWindowGauge.WithLabelValues(values...).Set(float64(info.CongestionWindow))
+	p.tcpMSSGauge.WithLabelValues(values...).Set(float64(info.MSS))
+
+	if newConn {
+		p.tcpRetransmitsCounter.WithLabelValues(values...).Add(float64(info.Retransmits))
+	}
+
+	log.Debug("Updated metrics for TCP_INFO")
+}
+
 // BucketStatusCode maps an HTTP status code string to its class bucket
 // (e.g. "200" -> "2xx"). Unknown or empty codes are returned as-is.
 func BucketStatusCode(code string) string {
@@ -173,12 +569,34 @@ func BucketStatusCode(code string) string {
 
 // UpdateErrorsCounter increments the total errors counter with a categorized error type.
 // Error messages are categorized into a fixed set of labels to prevent cardinality explosion.
-func (p *PrometheusClient) UpdateErrorsCounter(domain, proberType, tag string, err error) {
+func (p *PrometheusClient) UpdateErrorsCounter(t Target, err error) {
 	category := CategorizeError(err)
-	totalErrorsCounter.WithLabelValues(domain, tag, category, proberType).Inc()
+	p.totalErrorsCounter.WithLabelValues(p.labelValues(t, t.Domain, t.Tag, category, t.ProberType)...).Inc()
+
+	for _, s := range p.sinks {
+		s.CountError(t, category)
+	}
+
 	log.Debug("Updated metric for total errors counter")
 }
 
+// ErrAssertionFailed is wrapped by probers when a response was received but
+// did not satisfy the configured expectations. Such errors are reported
+// under the "assertion_failed" category.
+var ErrAssertionFailed = errors.New("assertion failed")
+
+// PhaseTimeoutError is reported by probers when a single phase of a request
+// outlived its configured deadline. It is categorized as "<phase>_timeout".
+type PhaseTimeoutError struct {
+	Phase   string
+	Timeout time.Duration
+}
+
+// Error implements the error interface.
+func (e *PhaseTimeoutError) Error() string {
+	return fmt.Sprintf("%s phase exceeded its %v deadline", e.Phase, e.Timeout)
+}
+
 // errorPattern maps an error message substring to a known error category.
 type errorPattern struct {
 	substr   string
@@ -215,6 +633,19 @@ func CategorizeError(err error) string {
 		return "canceled"
 	}
 
+	if errors.Is(err, ErrAssertionFailed) {
+		return "assertion_failed"
+	}
+
+	var phaseErr *PhaseTimeoutError
+	if errors.As(err, &phaseErr) {
+		return strings.ToLower(phaseErr.Phase) + "_timeout"
+	}
+
+	if category := categorizeCertificateError(err); category != "" {
+		return category
+	}
+
 	// Fall back to substring matching on the lowercased error message
 	errStr := strings.ToLower(err.Error())
 
@@ -227,11 +658,59 @@ func CategorizeError(err error) string {
 	return "unknown"
 }
 
-// PrometheusPush sends the collected Prometheus metrics to the push gateway.
-func (p *PrometheusClient) PrometheusPush() {
-	log.Debug("Pushing metrics to push gateway")
+// categorizeCertificateError distinguishes the common certificate
+// verification failures so they are not all reported as "tls_error".
+func categorizeCertificateError(err error) string {
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	if errors.As(err, &unknownAuthorityErr) {
+		return "tls_unknown_authority"
+	}
+
+	var hostnameErr x509.HostnameError
+	if errors.As(err, &hostnameErr) {
+		return "tls_hostname_mismatch"
+	}
+
+	var invalidErr x509.CertificateInvalidError
+	if errors.As(err, &invalidErr) && invalidErr.Reason == x509.Expired {
+		return "tls_cert_expired"
+	}
+
+	return ""
+}
+
+// partialDeleter is implemented by every metric vector.
+type partialDeleter interface {
+	DeletePartialMatch(labels prometheus.Labels) int
+}
 
-	if err := p.pusher.Push(); err != nil {
-		log.WithError(err).Error("Failed to push metrics to push gateway")
+// DeleteEndpointMetrics removes every series exported for an endpoint that is
+// no longer probed, so it does not linger with its last value.
+func (p *PrometheusClient) DeleteEndpointMetrics(t Target) {
+	vecs := []partialDeleter{
+		p.dnsLatencyHistogram, p.connLatencyHistogram, p.tlsLatencyHistogram,
+		p.gotConnLatencyHistogram, p.firstByteLatencyHistogram, p.totalLatencyHistogram,
+		p.totalRequestsCounter, p.totalErrorsCounter,
+		p.tlsCertExpiryGauge, p.tlsInfoGauge, p.tlsChainVerifiedGauge,
+		p.tcpRTTHistogram, p.tcpRTTVarHistogram, p.tcpRetransmitsCounter,
+		p.tcpCongestionWindowGauge, p.tcpMSSGauge,
+		p.endpointUpGauge, p.sloCompliantGauge, p.sloErrorBudgetGauge,
+		p.benchLatencyGauge, p.benchThroughputGauge,
 	}
+
+	deleted := 0
+	for _, vec := range vecs {
+		deleted += vec.DeletePartialMatch(endpointLabels(t))
+	}
+
+	// DNS query metrics carry no prober_type, only dns probers export them
+	if t.ProberType == "dns" {
+		queryLabels := prometheus.Labels{"domain": t.Domain, "tag": t.Tag}
+
+		for _, vec := range []partialDeleter{p.dnsQueryLatencyHistogram, p.dnsResponsesCounter, p.dnsAnswersGauge} {
+			deleted += vec.DeletePartialMatch(queryLabels)
+		}
+	}
+
+	log.Debugf("Deleted %d series for %s endpoint %s", deleted, t.ProberType, t.Domain)
 }
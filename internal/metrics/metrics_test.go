@@ -2,9 +2,12 @@ package metrics_test
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/dntosas/astrolavos/internal/metrics"
 )
@@ -92,6 +95,21 @@ func TestCategorizeError(t *testing.T) {
 			err:      fmt.Errorf("x509: certificate signed by unknown authority"),
 			expected: "tls_error",
 		},
+		{
+			name:     "x509 unknown authority",
+			err:      fmt.Errorf("request failed: %w", &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}),
+			expected: "tls_unknown_authority",
+		},
+		{
+			name:     "x509 expired certificate",
+			err:      fmt.Errorf("verification failed: %w", x509.CertificateInvalidError{Reason: x509.Expired}),
+			expected: "tls_cert_expired",
+		},
+		{
+			name:     "x509 hostname mismatch",
+			err:      fmt.Errorf("verification failed: %w", x509.HostnameError{Certificate: &x509.Certificate{}, Host: "example.com"}),
+			expected: "tls_hostname_mismatch",
+		},
 		{
 			name:     "context canceled",
 			err:      context.Canceled,
@@ -112,6 +130,21 @@ func TestCategorizeError(t *testing.T) {
 			err:      fmt.Errorf("unexpected EOF"),
 			expected: "eof",
 		},
+		{
+			name:     "assertion failed",
+			err:      fmt.Errorf("%w: status code 503 not accepted", metrics.ErrAssertionFailed),
+			expected: "assertion_failed",
+		},
+		{
+			name:     "assertion failed mentioning timeout",
+			err:      fmt.Errorf("%w: body does not contain \"timeout\"", metrics.ErrAssertionFailed),
+			expected: "assertion_failed",
+		},
+		{
+			name:     "phase timeout",
+			err:      fmt.Errorf("request failed: %w", &metrics.PhaseTimeoutError{Phase: "firstByte", Timeout: time.Second}),
+			expected: "firstbyte_timeout",
+		},
 		{
 			name:     "unknown error",
 			err:      errors.New("something went wrong"),
@@ -128,3 +161,80 @@ func TestCategorizeError(t *testing.T) {
 		})
 	}
 }
+
+func TestNewPrometheusClient_IndependentRegistries(t *testing.T) {
+	a := metrics.NewPrometheusClient(metrics.Options{})
+	b := metrics.NewPrometheusClient(metrics.Options{})
+
+	a.UpdateRequestsCounter(metrics.Target{Domain: "example.com", ProberType: "tcp"}, "")
+
+	countSeries := func(p *metrics.PrometheusClient) int {
+		t.Helper()
+
+		families, err := p.Registry().Gather()
+		if err != nil {
+			t.Fatalf("failed to gather metrics: %v", err)
+		}
+
+		for _, mf := range families {
+			if mf.GetName() == "astrolavos_requests_total" {
+				return len(mf.GetMetric())
+			}
+		}
+
+		return 0
+	}
+
+	if n := countSeries(a); n != 1 {
+		t.Errorf("expected 1 series in the first registry, got %d", n)
+	}
+
+	if n := countSeries(b); n != 0 {
+		t.Errorf("expected no series in the second registry, got %d", n)
+	}
+}
+
+func TestPrometheusClient_CustomLabels(t *testing.T) {
+	p := metrics.NewPrometheusClient(metrics.Options{
+		IsOneOff:       true,
+		LabelNames:     []string{"team"},
+		ExternalLabels: map[string]string{"cluster": "eu-1"},
+	})
+
+	p.UpdateRequestsCounter(metrics.Target{Domain: "a.com", ProberType: "tcp", Labels: map[string]string{"team": "payments"}}, "")
+	p.UpdateRequestsCounter(metrics.Target{Domain: "b.com", ProberType: "tcp"}, "")
+
+	families, err := p.Registry().Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+
+	got := map[string]map[string]string{}
+
+	for _, mf := range families {
+		if mf.GetName() != "astrolavos_requests_total" {
+			continue
+		}
+
+		for _, m := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, lp := range m.GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+
+			got[labels["domain"]] = labels
+		}
+	}
+
+	if got["a.com"]["team"] != "payments" || got["a.com"]["cluster"] != "eu-1" {
+		t.Errorf("unexpected labels for a.com: %v", got["a.com"])
+	}
+
+	if v, ok := got["b.com"]["team"]; ok && v != "" {
+		t.Errorf("expected empty team label for b.com, got %q", v)
+	}
+
+	if got["b.com"]["cluster"] != "eu-1" {
+		t.Errorf("expected external label on every series, got %v", got["b.com"])
+	}
+}
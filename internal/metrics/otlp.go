@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dntosas/astrolavos/internal/model"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prombridge "go.opentelemetry.io/contrib/bridges/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// newOTLPProvider creates a meter provider exporting the series of
+// registry to an OpenTelemetry collector every cfg.Interval. The series are
+// bridged as they are, so OTLP receives the same histograms, counters and
+// labels as /metrics.
+func newOTLPProvider(cfg model.OTLP, registry *prometheus.Registry) (*sdkmetric.MeterProvider, error) {
+	ctx := context.Background()
+
+	var (
+		exporter sdkmetric.Exporter
+		err      error
+	)
+
+	switch cfg.Protocol {
+	case "grpc":
+		exporter, err = otlpmetricgrpc.New(ctx,
+			otlpmetricgrpc.WithEndpointURL(cfg.Endpoint),
+			otlpmetricgrpc.WithHeaders(cfg.Headers),
+		)
+	case "http":
+		exporter, err = otlpmetrichttp.New(ctx,
+			otlpmetrichttp.WithEndpointURL(cfg.Endpoint),
+			otlpmetrichttp.WithHeaders(cfg.Headers),
+		)
+	default:
+		return nil, fmt.Errorf("unknown OTLP protocol: %s", cfg.Protocol)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("creation of OTLP exporter failed: %w", err)
+	}
+
+	reader := sdkmetric.NewPeriodicReader(exporter,
+		sdkmetric.WithInterval(cfg.Interval),
+		sdkmetric.WithProducer(prombridge.NewMetricProducer(prombridge.WithGatherer(registry))),
+	)
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", "astrolavos")))
+	if err != nil {
+		return nil, fmt.Errorf("creation of OTLP resource failed: %w", err)
+	}
+
+	return sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader), sdkmetric.WithResource(res)), nil
+}
+
+// Push sends the collected metrics once, at the end of a one-off run: over
+// OTLP when an exporter is configured, to the push gateway otherwise. Sinks
+// are flushed and closed too. It returns the error of a failed push to the
+// gateway.
+func (p *PrometheusClient) Push(ctx context.Context) error {
+	var err error
+
+	if p.otlp == nil {
+		err = p.PrometheusPush(ctx)
+	}
+
+	p.Shutdown(ctx)
+
+	return err
+}
+
+// Shutdown exports the collected metrics a last time over OTLP, if
+// configured, and stops the exporter and sinks.
+func (p *PrometheusClient) Shutdown(ctx context.Context) {
+	defer p.closeSinks()
+
+	if p.otlp == nil {
+		return
+	}
+
+	log.Debug("Exporting metrics over OTLP")
+
+	if err := p.otlp.Shutdown(ctx); err != nil {
+		log.WithError(err).Error("Failed to export metrics over OTLP")
+	}
+}
@@ -0,0 +1,155 @@
+package metrics_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/metrics"
+	"github.com/dntosas/astrolavos/internal/model"
+
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// grpcCollector is a stand-in OpenTelemetry collector receiving metrics over gRPC.
+type grpcCollector struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+	requests chan *colmetricspb.ExportMetricsServiceRequest
+}
+
+func (c *grpcCollector) Export(_ context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	c.requests <- req
+
+	return &colmetricspb.ExportMetricsServiceResponse{}, nil
+}
+
+func TestOTLPExport_GRPC(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	collector := &grpcCollector{requests: make(chan *colmetricspb.ExportMetricsServiceRequest, 1)}
+	srv := grpc.NewServer()
+	colmetricspb.RegisterMetricsServiceServer(srv, collector)
+
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	p := metrics.NewPrometheusClient(metrics.Options{
+		IsOneOff: true,
+		OTLP:     &model.OTLP{Endpoint: "http://" + lis.Addr().String(), Protocol: "grpc", Interval: time.Hour},
+	})
+
+	p.UpdateConnHistogram(metrics.Target{Domain: "example.com:443", ProberType: "tcp"}, 0.01)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := p.Push(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case req := <-collector.requests:
+		name := req.GetResourceMetrics()[0].GetScopeMetrics()[0].GetMetrics()[0].GetName()
+		if name != "astrolavos_conn_latency_seconds" {
+			t.Errorf("unexpected metric exported: %s", name)
+		}
+	default:
+		t.Fatal("expected metrics to be exported to the collector")
+	}
+}
+
+func TestOTLPExport(t *testing.T) {
+	requests := make(chan *colmetricspb.ExportMetricsServiceRequest, 1)
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/metrics" || r.Header.Get("X-Tenant") != "astrolavos" {
+			t.Errorf("unexpected request: %s %v", r.URL.Path, r.Header)
+		}
+
+		body, _ := io.ReadAll(r.Body)
+
+		req := &colmetricspb.ExportMetricsServiceRequest{}
+		if err := proto.Unmarshal(body, req); err != nil {
+			t.Errorf("failed to decode export request: %v", err)
+		}
+
+		requests <- req
+
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		_, _ = w.Write(nil)
+	}))
+	defer collector.Close()
+
+	p := metrics.NewPrometheusClient(metrics.Options{
+		IsOneOff:       true,
+		ExternalLabels: map[string]string{"cluster": "eu-1"},
+		OTLP: &model.OTLP{
+			Endpoint: collector.URL + "/v1/metrics",
+			Protocol: "http",
+			Headers:  map[string]string{"X-Tenant": "astrolavos"},
+			Interval: time.Hour,
+		},
+	})
+
+	target := metrics.Target{Domain: "https://example.com", ProberType: "httptrace", Tag: "prod"}
+	p.UpdateTotalHistogram(target, 0.2)
+	p.UpdateRequestsCounter(target, "200")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := p.Push(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var req *colmetricspb.ExportMetricsServiceRequest
+	select {
+	case req = <-requests:
+	default:
+		t.Fatal("expected metrics to be exported to the collector")
+	}
+
+	found := map[string]*metricspb.Metric{}
+
+	for _, rm := range req.GetResourceMetrics() {
+		for _, sm := range rm.GetScopeMetrics() {
+			for _, m := range sm.GetMetrics() {
+				found[m.GetName()] = m
+			}
+		}
+	}
+
+	hist := found["astrolavos_total_latency_seconds"].GetHistogram()
+	if hist == nil || len(hist.GetDataPoints()) != 1 {
+		t.Fatalf("expected the total latency histogram, got %v", found["astrolavos_total_latency_seconds"])
+	}
+
+	dp := hist.GetDataPoints()[0]
+	if dp.GetCount() != 1 || dp.GetSum() != 0.2 || len(dp.GetExplicitBounds()) == 0 {
+		t.Errorf("unexpected histogram data point: %v", dp)
+	}
+
+	attrs := map[string]string{}
+	for _, kv := range dp.GetAttributes() {
+		attrs[kv.GetKey()] = kv.GetValue().GetStringValue()
+	}
+
+	if attrs["domain"] != "https://example.com" || attrs["tag"] != "prod" || attrs["prober_type"] != "httptrace" || attrs["cluster"] != "eu-1" {
+		t.Errorf("unexpected attributes: %v", attrs)
+	}
+
+	sum := found["astrolavos_requests_total"].GetSum()
+	if sum == nil || !sum.GetIsMonotonic() || sum.GetDataPoints()[0].GetAsDouble() != 1 {
+		t.Errorf("expected the requests counter as a monotonic sum, got %v", found["astrolavos_requests_total"])
+	}
+}
@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/model"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	log "github.com/sirupsen/logrus"
+)
+
+// pushTimeout bounds a single attempt to push to the gateway.
+const pushTimeout = 10 * time.Second
+
+// newPusher creates the pusher of registry to the gateway of cfg, with its
+// grouping labels, credentials and TLS settings.
+func newPusher(cfg model.PushGateway, registry prometheus.Gatherer) *push.Pusher {
+	pusher := push.New(cfg.URL, "astrolavos").Gatherer(registry)
+
+	for name, value := range cfg.Grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+
+	if cfg.Username != "" {
+		pusher = pusher.BasicAuth(cfg.Username, cfg.Password)
+	}
+
+	if cfg.BearerToken != "" {
+		pusher = pusher.Header(http.Header{"Authorization": {"Bearer " + cfg.BearerToken}})
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert // the default transport is always an *http.Transport
+	transport.TLSClientConfig = cfg.TLS
+
+	return pusher.Client(&http.Client{Transport: transport, Timeout: pushTimeout})
+}
+
+// PrometheusPush sends the collected Prometheus metrics to the push gateway,
+// retrying with exponential backoff. It does nothing without a gateway.
+func (p *PrometheusClient) PrometheusPush(ctx context.Context) error {
+	if p.pusher == nil {
+		return nil
+	}
+
+	log.Debug("Pushing metrics to push gateway")
+
+	var lastErr error
+
+	for attempt := range p.pushGateway.Retries {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("push to gateway canceled: %w", errors.Join(lastErr, ctx.Err()))
+			case <-time.After(time.Duration(500*(1<<min(attempt-1, 6))) * time.Millisecond):
+			}
+		}
+
+		if p.pushGateway.Add {
+			lastErr = p.pusher.AddContext(ctx)
+		} else {
+			lastErr = p.pusher.PushContext(ctx)
+		}
+
+		if lastErr == nil {
+			return nil
+		}
+
+		log.WithError(lastErr).Debugf("Push attempt %d/%d failed", attempt+1, p.pushGateway.Retries)
+	}
+
+	return fmt.Errorf("push to gateway failed after %d attempts: %w", p.pushGateway.Retries, lastErr)
+}
+
+// PushPeriodically pushes to the gateway every configured interval until
+// ctx is done, for servers that cannot be scraped. It returns at once if
+// periodic pushes are disabled.
+func (p *PrometheusClient) PushPeriodically(ctx context.Context) {
+	if p.pusher == nil || p.pushGateway.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(p.pushGateway.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.PrometheusPush(ctx); err != nil && ctx.Err() == nil {
+				log.WithError(err).Error("Failed to push metrics to push gateway")
+			}
+		}
+	}
+}
+
+// PushesPeriodically reports whether metrics are pushed outside one-off
+// mode.
+func (p *PrometheusClient) PushesPeriodically() bool {
+	return p.pusher != nil && p.pushGateway.Interval > 0
+}
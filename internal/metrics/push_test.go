@@ -0,0 +1,193 @@
+package metrics_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/metrics"
+	"github.com/dntosas/astrolavos/internal/model"
+)
+
+// gatewayRequest is what a stand-in push gateway received.
+type gatewayRequest struct {
+	method, path, authorization string
+}
+
+// groupingOf returns the grouping labels of a push to the astrolavos job
+// at path.
+func groupingOf(path string) map[string]string {
+	parts := strings.Split(strings.TrimPrefix(path, "/metrics/job/astrolavos/"), "/")
+	grouping := map[string]string{}
+
+	for i := 0; i+1 < len(parts); i += 2 {
+		grouping[parts[i]] = parts[i+1]
+	}
+
+	return grouping
+}
+
+// newGateway starts a stand-in push gateway failing the first failures
+// requests with a 503.
+func newGateway(t *testing.T, tlsServer bool, failures int32) (*httptest.Server, chan gatewayRequest) {
+	t.Helper()
+
+	requests := make(chan gatewayRequest, 16)
+
+	var count atomic.Int32
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- gatewayRequest{r.Method, r.URL.Path, r.Header.Get("Authorization")}
+
+		if count.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+
+	var srv *httptest.Server
+	if tlsServer {
+		srv = httptest.NewTLSServer(handler)
+	} else {
+		srv = httptest.NewServer(handler)
+	}
+
+	t.Cleanup(srv.Close)
+
+	return srv, requests
+}
+
+func TestPrometheusPush(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        model.PushGateway
+		wantMethod string
+		wantAuth   string
+	}{
+		{
+			name:       "push with basic auth",
+			cfg:        model.PushGateway{Username: "astrolavos", Password: "s3cret"},
+			wantMethod: http.MethodPut,
+			wantAuth:   "Basic YXN0cm9sYXZvczpzM2NyZXQ=",
+		},
+		{
+			name:       "add with bearer token",
+			cfg:        model.PushGateway{BearerToken: "t0ken", Add: true},
+			wantMethod: http.MethodPost,
+			wantAuth:   "Bearer t0ken",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := newGateway(t, false, 0)
+
+			tt.cfg.URL = srv.URL
+			tt.cfg.Grouping = map[string]string{"cluster": "eu-1", "instance": "probe-0"}
+			tt.cfg.Retries = 1
+
+			p := metrics.NewPrometheusClient(metrics.Options{IsOneOff: true, PushGateway: &tt.cfg})
+			if err := p.PrometheusPush(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			req := <-requests
+			if req.method != tt.wantMethod || req.authorization != tt.wantAuth {
+				t.Errorf("expected %s with %q, got %+v", tt.wantMethod, tt.wantAuth, req)
+			}
+
+			// The client orders the grouping labels of the path at random
+			if got := groupingOf(req.path); !maps.Equal(got, tt.cfg.Grouping) {
+				t.Errorf("expected grouping %v, got path %s", tt.cfg.Grouping, req.path)
+			}
+		})
+	}
+}
+
+func TestPrometheusPush_TLS(t *testing.T) {
+	srv, requests := newGateway(t, true, 0)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+
+	p := metrics.NewPrometheusClient(metrics.Options{IsOneOff: true, PushGateway: &model.PushGateway{
+		URL:     srv.URL,
+		TLS:     &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12},
+		Retries: 1,
+	}})
+
+	if err := p.PrometheusPush(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(requests) != 1 {
+		t.Errorf("expected 1 push, got %d", len(requests))
+	}
+}
+
+func TestPrometheusPush_Retries(t *testing.T) {
+	srv, requests := newGateway(t, false, 2)
+
+	p := metrics.NewPrometheusClient(metrics.Options{IsOneOff: true, PushGateway: &model.PushGateway{URL: srv.URL, Retries: 3}})
+	if err := p.PrometheusPush(context.Background()); err != nil {
+		t.Fatalf("expected the third attempt to succeed, got %v", err)
+	}
+
+	if len(requests) != 3 {
+		t.Errorf("expected 3 attempts, got %d", len(requests))
+	}
+
+	srv, requests = newGateway(t, false, 2)
+
+	p = metrics.NewPrometheusClient(metrics.Options{IsOneOff: true, PushGateway: &model.PushGateway{URL: srv.URL, Retries: 2}})
+	if err := p.Push(context.Background()); err == nil {
+		t.Fatal("expected an error once retries are exhausted")
+	}
+
+	if len(requests) != 2 {
+		t.Errorf("expected 2 attempts, got %d", len(requests))
+	}
+}
+
+func TestPushPeriodically(t *testing.T) {
+	srv, requests := newGateway(t, false, 0)
+
+	p := metrics.NewPrometheusClient(metrics.Options{PushGateway: &model.PushGateway{URL: srv.URL, Interval: 20 * time.Millisecond, Retries: 1}})
+	if !p.PushesPeriodically() {
+		t.Fatal("expected periodic pushes to be enabled")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+
+	go func() {
+		p.PushPeriodically(ctx)
+		close(done)
+	}()
+
+	for range 2 {
+		select {
+		case <-requests:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a periodic push")
+		}
+	}
+
+	cancel()
+	<-done
+
+	if metrics.NewPrometheusClient(metrics.Options{PushGateway: &model.PushGateway{URL: srv.URL, Retries: 1}}).PushesPeriodically() {
+		t.Error("expected periodic pushes to be disabled without an interval")
+	}
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import log "github.com/sirupsen/logrus"
+
+// Latency phases reported to sinks. Each matches the histogram named
+// astrolavos_<phase>_latency_seconds.
+const (
+	PhaseDNS       = "dns"
+	PhaseConn      = "conn"
+	PhaseTLS       = "tls"
+	PhaseGotConn   = "gotconn"
+	PhaseFirstByte = "firstbyte"
+	PhaseTotal     = "total"
+)
+
+// Sink receives the measurements of probes at the same update points as the
+// Prometheus registry, to send them to another metrics backend. Sinks are
+// called from every prober concurrently.
+type Sink interface {
+	// ObserveLatency records the duration in seconds of a phase of a probe.
+	ObserveLatency(t Target, phase string, seconds float64)
+	// CountRequest counts a probe request, with its HTTP status code if any.
+	CountRequest(t Target, statusCode string)
+	// CountError counts a probe error of the given category.
+	CountError(t Target, category string)
+	// Close sends any buffered measurements and releases the sink.
+	Close() error
+}
+
+func (p *PrometheusClient) observeLatency(t Target, phase string, seconds float64) {
+	for _, s := range p.sinks {
+		s.ObserveLatency(t, phase, seconds)
+	}
+}
+
+// closeSinks closes every sink, logging the ones failing to.
+func (p *PrometheusClient) closeSinks() {
+	for _, s := range p.sinks {
+		if err := s.Close(); err != nil {
+			log.WithError(err).Error("Failed to close metrics sink")
+		}
+	}
+}
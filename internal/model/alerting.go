@@ -0,0 +1,32 @@
+package model
+
+import (
+	"text/template"
+	"time"
+)
+
+// Alerting configures the webhooks notified when an endpoint goes down or
+// comes back up.
+type Alerting struct {
+	// FailureThreshold is the number of consecutive failures marking an
+	// endpoint down.
+	FailureThreshold int
+	// SuccessThreshold is the number of consecutive successes marking a
+	// down endpoint up again.
+	SuccessThreshold int
+	Webhooks         []Webhook
+}
+
+// Webhook is a single notification receiver.
+type Webhook struct {
+	URL     string
+	Headers map[string]string
+	// Format is "json" for the plain notification or "slack" for a Slack
+	// compatible message. Ignored when Template is set.
+	Format string
+	// Template renders the request body from the notification. Optional.
+	Template *template.Template
+	// Retries is the number of delivery attempts.
+	Retries int
+	Timeout time.Duration
+}
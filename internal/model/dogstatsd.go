@@ -0,0 +1,10 @@
+package model
+
+// DogStatsD configures the sending of probe measurements to a DogStatsD
+// server, such as the Datadog agent.
+type DogStatsD struct {
+	// Address is host:port for UDP or unix:///path for a Unix socket.
+	Address string
+	// LatencyType is "distribution" or "timing".
+	LatencyType string
+}
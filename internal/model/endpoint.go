@@ -1,16 +1,104 @@
 // Package model contains domain types shared across the application.
 package model
 
-import "time"
+import (
+	"bytes"
+	"crypto/x509"
+	"maps"
+	"regexp"
+	"slices"
+	"time"
+)
 
 // Endpoint encapsulates information needed to run probes against a destination.
 type Endpoint struct {
 	URI                 string
 	Interval            time.Duration
 	Tag                 string
+	Labels              map[string]string
 	Retries             int
 	ProberType          string
 	ReuseConnection     bool
 	SkipTLSVerification bool
 	TCPTimeout          time.Duration
+	SLO                 *SLO
+
+	// HTTP prober request settings
+	Method        string
+	Headers       map[string]string
+	Body          []byte
+	Expect        *Expectations
+	Timeout       time.Duration
+	PhaseTimeouts PhaseTimeouts
+
+	// DNS prober settings
+	RecordType      string
+	Resolver        string
+	ExpectedAnswers []string
+	AnswerPattern   *regexp.Regexp
+
+	// TLS prober settings
+	ServerName string
+	RootCAs    *x509.CertPool
+}
+
+// PhaseTimeouts holds the optional per-phase deadlines of an HTTP probe.
+// A zero value disables the deadline for that phase.
+type PhaseTimeouts struct {
+	DNS       time.Duration
+	Connect   time.Duration
+	TLS       time.Duration
+	FirstByte time.Duration
+}
+
+// Key identifies an endpoint across configuration reloads. Endpoints sharing
+// a key would export the same metric series, so only one of them is probed.
+type Key struct {
+	ProberType string
+	URI        string
+	Tag        string
+}
+
+// Key returns the identity of the endpoint.
+func (e *Endpoint) Key() Key {
+	return Key{ProberType: e.ProberType, URI: e.URI, Tag: e.Tag}
+}
+
+// Equal reports whether both endpoints would be probed identically.
+func (e *Endpoint) Equal(o *Endpoint) bool {
+	if e == nil || o == nil {
+		return e == o
+	}
+
+	return e.URI == o.URI &&
+		e.Interval == o.Interval &&
+		e.Tag == o.Tag &&
+		maps.Equal(e.Labels, o.Labels) &&
+		e.Retries == o.Retries &&
+		e.ProberType == o.ProberType &&
+		e.ReuseConnection == o.ReuseConnection &&
+		e.SkipTLSVerification == o.SkipTLSVerification &&
+		e.TCPTimeout == o.TCPTimeout &&
+		e.SLO.Equal(o.SLO) &&
+		e.Method == o.Method &&
+		maps.Equal(e.Headers, o.Headers) &&
+		bytes.Equal(e.Body, o.Body) &&
+		e.Expect.Equal(o.Expect) &&
+		e.Timeout == o.Timeout &&
+		e.PhaseTimeouts == o.PhaseTimeouts &&
+		e.RecordType == o.RecordType &&
+		e.Resolver == o.Resolver &&
+		slices.Equal(e.ExpectedAnswers, o.ExpectedAnswers) &&
+		regexpEqual(e.AnswerPattern, o.AnswerPattern) &&
+		e.ServerName == o.ServerName &&
+		e.RootCAs.Equal(o.RootCAs)
+}
+
+// regexpEqual compares two optional patterns by their source text.
+func regexpEqual(a, b *regexp.Regexp) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.String() == b.String()
 }
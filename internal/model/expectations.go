@@ -0,0 +1,53 @@
+package model
+
+import (
+	"maps"
+	"regexp"
+	"slices"
+
+	"github.com/dntosas/astrolavos/internal/jsonpath"
+)
+
+// Expectations describes the assertions an HTTP response must satisfy for
+// a probe to count as successful.
+type Expectations struct {
+	StatusCodes  []StatusCodeRange
+	BodyContains string
+	BodyRegex    *regexp.Regexp
+	JSONPaths    []JSONPathExpectation
+	Headers      map[string]*regexp.Regexp
+	MaxBodySize  int64
+}
+
+// Equal reports whether both sets of expectations assert the same things.
+func (e *Expectations) Equal(o *Expectations) bool {
+	if e == nil || o == nil {
+		return e == o
+	}
+
+	return slices.Equal(e.StatusCodes, o.StatusCodes) &&
+		e.BodyContains == o.BodyContains &&
+		regexpEqual(e.BodyRegex, o.BodyRegex) &&
+		slices.EqualFunc(e.JSONPaths, o.JSONPaths, func(a, b JSONPathExpectation) bool {
+			return a.Path.String() == b.Path.String() && a.Equals == b.Equals
+		}) &&
+		maps.EqualFunc(e.Headers, o.Headers, regexpEqual) &&
+		e.MaxBodySize == o.MaxBodySize
+}
+
+// StatusCodeRange is an inclusive range of accepted HTTP status codes.
+type StatusCodeRange struct {
+	Min int
+	Max int
+}
+
+// Contains reports whether code falls within the range.
+func (r StatusCodeRange) Contains(code int) bool {
+	return code >= r.Min && code <= r.Max
+}
+
+// JSONPathExpectation asserts that the value selected by Path equals Equals.
+type JSONPathExpectation struct {
+	Path   jsonpath.Path
+	Equals string
+}
@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// Mesh configures the discovery of peer astrolavos instances, each of which
+// is probed on its /latency endpoint.
+type Mesh struct {
+	// Discovery is the DNS name listing the peers, e.g. a headless Service.
+	Discovery string
+	// RecordType is A (A and AAAA records) or SRV.
+	RecordType string
+	// Port is the peers' HTTP port, used unless SRV records provide one.
+	Port int
+	// RefreshInterval is how often Discovery is resolved again.
+	RefreshInterval time.Duration
+	// Source identifies this instance in the source label.
+	Source string
+	// PayloadSize is requested from each peer's /latency endpoint when positive.
+	PayloadSize int
+
+	// Settings of the httpTrace probes created for each peer
+	Interval time.Duration
+	Tag      string
+	Retries  int
+	Timeout  time.Duration
+}
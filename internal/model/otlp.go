@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// OTLP configures the export of metrics and traces to an OpenTelemetry
+// collector.
+type OTLP struct {
+	// Endpoint is the collector URL metrics are sent to, if any. Plain http
+	// disables TLS.
+	Endpoint string
+	// TracesEndpoint is the collector URL probe traces are sent to, if any.
+	TracesEndpoint string
+	// Protocol is "grpc" or "http".
+	Protocol string
+	Headers  map[string]string
+	// Interval is how often metrics are exported outside one-off mode.
+	Interval time.Duration
+}
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"crypto/tls"
+	"time"
+)
+
+// PushGateway configures how metrics are sent to a Prometheus push gateway.
+type PushGateway struct {
+	URL string
+	// Grouping labels identify the group replaced by a push, so instances
+	// pushing to the same gateway keep their own series.
+	Grouping map[string]string
+	// Username and Password enable basic auth, BearerToken token auth.
+	Username    string
+	Password    string
+	BearerToken string
+	// TLS overrides the default TLS settings of the connection. Optional.
+	TLS *tls.Config
+	// Add only replaces the pushed series of the group (POST), instead of
+	// the whole group (PUT).
+	Add bool
+	// Interval is how often metrics are pushed in server mode. Zero
+	// disables pushing outside one-off mode.
+	Interval time.Duration
+	// Retries is the number of attempts of every push.
+	Retries int
+	// Optional is set for the default gateway, which one-off runs do not
+	// require: failing to push to it is logged instead of failing the run.
+	Optional bool
+}
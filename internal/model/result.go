@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// Result is the outcome of a single probe, after retries.
+type Result struct {
+	Time       time.Time
+	Err        error
+	StatusCode string
+	// RemoteAddr is the address of the peer the probe connected to, if any.
+	RemoteAddr string
+
+	// Phase latencies, zero for phases the prober does not measure
+	DNS       time.Duration
+	Connect   time.Duration
+	TLS       time.Duration
+	GotConn   time.Duration
+	FirstByte time.Duration
+	Total     time.Duration
+}
+
+// Success reports whether the probe succeeded.
+func (r Result) Success() bool {
+	return r.Err == nil
+}
@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// SLO is the service level objective of an endpoint, evaluated over a
+// sliding window of its probe results. At least one of the latency and
+// success ratio objectives is set.
+type SLO struct {
+	Window time.Duration
+	// LatencyPercentile of the total latency of successful probes must be
+	// within LatencyThreshold. A zero threshold disables the objective.
+	LatencyPercentile float64
+	LatencyThreshold  time.Duration
+	// SuccessRatio is the minimum share of successful probes, between 0
+	// and 1. Zero disables the objective.
+	SuccessRatio float64
+}
+
+// Equal reports whether both objectives are the same.
+func (s *SLO) Equal(o *SLO) bool {
+	if s == nil || o == nil {
+		return s == o
+	}
+
+	return *s == *o
+}
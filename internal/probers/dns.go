@@ -0,0 +1,134 @@
+package probers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/metrics"
+	"github.com/dntosas/astrolavos/internal/model"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DNS implements the Prober interface for DNS resolution probes, querying
+// a nameserver directly for a single record type and validating the
+// returned answers.
+type DNS struct {
+	ProberConfig
+}
+
+// NewDNS creates a new DNS prober with the given configuration.
+// When no nameserver is configured the first one of /etc/resolv.conf is
+// queried, bypassing any local resolver cache.
+func NewDNS(c ProberConfig) *DNS {
+	c.target = c.newTarget("dns")
+
+	if c.nameserver == "" {
+		c.nameserver = systemNameserver(resolvConfPath)
+	}
+
+	return &DNS{ProberConfig: c}
+}
+
+// String returns a human-readable description of the DNS prober configuration.
+func (d *DNS) String() string {
+	return fmt.Sprintf("DNS Prober Endpoint: %s - Record: %s - Interval: %v - Tag: %s - Retries: %d", d.endpoint, d.recordType, d.interval, d.tag, d.retries)
+}
+
+// Run starts the DNS prober, executing probes according to the configured mode.
+func (d *DNS) Run(ctx context.Context) {
+	d.runLoop(ctx, d.String(), d.probe)
+}
+
+// probe performs a single DNS query with retry logic and records metrics.
+func (d *DNS) probe(ctx context.Context) {
+	var duration time.Duration
+
+	err := d.retryWithBackoff(ctx, func() error {
+		var resolveErr error
+		duration, resolveErr = d.resolve(ctx)
+
+		return resolveErr
+	})
+
+	d.promC.UpdateRequestsCounter(d.target, "")
+
+	if err != nil {
+		log.Errorf("DNS prober %s failed after %d attempts: %v", d, d.retries, err)
+		d.promC.UpdateErrorsCounter(d.target, err)
+		d.report(model.Result{Err: err})
+
+		return
+	}
+
+	d.report(model.Result{Total: duration})
+}
+
+// resolve runs one query, records its outcome and validates the answers.
+// It returns the query duration.
+func (d *DNS) resolve(ctx context.Context) (time.Duration, error) {
+	if d.timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, d.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	rcode, answers, err := exchange(ctx, d.nameserver, d.endpoint, d.recordType)
+	duration := time.Since(start)
+
+	label := rcodeLabel(rcode, len(answers), err)
+	d.promC.UpdateDNSQueryMetrics(d.target, d.recordType, label, len(answers), duration.Seconds())
+
+	if err != nil {
+		return duration, fmt.Errorf("DNS %s query to %s failed: %w", d.recordType, d.nameserver, err)
+	}
+
+	if rcode != dnsmessage.RCodeSuccess {
+		return duration, fmt.Errorf("DNS %s query to %s failed: %s", d.recordType, d.nameserver, label)
+	}
+
+	log.Debugf("DNS %s answers for %s: %v", d.recordType, d.endpoint, answers)
+
+	return duration, d.validate(answers)
+}
+
+// validate checks the answers against the expected answers and pattern.
+// A resolver returning stale or wrong records is reported as an assertion failure.
+func (d *DNS) validate(answers []string) error {
+	if len(answers) == 0 {
+		return fmt.Errorf("DNS %s query returned no answers", d.recordType)
+	}
+
+	for _, expected := range d.expectedAnswers {
+		if !slices.Contains(answers, normalizeAnswer(expected)) {
+			return fmt.Errorf("%w: expected DNS answer %q not found in %v", metrics.ErrAssertionFailed, expected, answers)
+		}
+	}
+
+	if d.answerPattern != nil {
+		for _, answer := range answers {
+			if !d.answerPattern.MatchString(answer) {
+				return fmt.Errorf("%w: DNS answer %q does not match %q", metrics.ErrAssertionFailed, answer, d.answerPattern)
+			}
+		}
+	}
+
+	return nil
+}
+
+// normalizeAnswer brings IPs into canonical form and strips the trailing
+// dot of fully qualified names so answers compare equal to user input.
+func normalizeAnswer(answer string) string {
+	if ip := net.ParseIP(answer); ip != nil {
+		return ip.String()
+	}
+
+	return strings.TrimSuffix(answer, ".")
+}
@@ -0,0 +1,241 @@
+package probers
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// resolvConfPath is read for the nameserver of dns probes without a resolver.
+const resolvConfPath = "/etc/resolv.conf"
+
+// Pseudo response codes of queries that got no usable response, or an
+// answer-less NOERROR response.
+const (
+	rcodeNoData  = "NODATA"
+	rcodeTimeout = "TIMEOUT"
+	rcodeError   = "ERROR"
+)
+
+// dnsTypes maps the supported record types to their wire type.
+var dnsTypes = map[string]dnsmessage.Type{
+	"A":     dnsmessage.TypeA,
+	"AAAA":  dnsmessage.TypeAAAA,
+	"CNAME": dnsmessage.TypeCNAME,
+	"SRV":   dnsmessage.TypeSRV,
+	"TXT":   dnsmessage.TypeTXT,
+}
+
+// rcodeNames are the labels of the response codes of RFC 1035.
+var rcodeNames = map[dnsmessage.RCode]string{
+	dnsmessage.RCodeSuccess:        "NOERROR",
+	dnsmessage.RCodeFormatError:    "FORMERR",
+	dnsmessage.RCodeServerFailure:  "SERVFAIL",
+	dnsmessage.RCodeNameError:      "NXDOMAIN",
+	dnsmessage.RCodeNotImplemented: "NOTIMP",
+	dnsmessage.RCodeRefused:        "REFUSED",
+}
+
+// errTruncated reports a UDP response that did not fit in a datagram.
+var errTruncated = errors.New("truncated response")
+
+// systemNameserver returns the first nameserver of the resolv.conf at path,
+// or the local nameserver if there is none, like the Go resolver does.
+func systemNameserver(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return "127.0.0.1:53"
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 1 && fields[0] == "nameserver" {
+			return net.JoinHostPort(fields[1], "53")
+		}
+	}
+
+	return "127.0.0.1:53"
+}
+
+// exchange queries nameserver for the records of the given type of name,
+// over UDP and again over TCP if the response is truncated. It returns the
+// response code and the answers of that type, as strings; the error is
+// only set if no response was received.
+func exchange(ctx context.Context, nameserver, name, recordType string) (dnsmessage.RCode, []string, error) {
+	qname, err := dnsmessage.NewName(strings.TrimSuffix(name, ".") + ".")
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid name %q: %w", name, err)
+	}
+
+	qtype := dnsTypes[recordType]
+
+	query := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: uint16(rand.Uint32()), RecursionDesired: true}, //nolint:gosec // query IDs need not be cryptographically random
+		Questions: []dnsmessage.Question{
+			{Name: qname, Type: qtype, Class: dnsmessage.ClassINET},
+		},
+	}
+
+	resp, err := roundTrip(ctx, "udp", nameserver, query)
+	if errors.Is(err, errTruncated) {
+		resp, err = roundTrip(ctx, "tcp", nameserver, query)
+	}
+
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var answers []string
+
+	for _, rr := range resp.Answers {
+		if rr.Header.Type != qtype {
+			continue
+		}
+
+		switch body := rr.Body.(type) {
+		case *dnsmessage.AResource:
+			answers = append(answers, net.IP(body.A[:]).String())
+		case *dnsmessage.AAAAResource:
+			answers = append(answers, net.IP(body.AAAA[:]).String())
+		case *dnsmessage.CNAMEResource:
+			// A name is not an alias of itself
+			if cname := normalizeAnswer(body.CNAME.String()); !strings.EqualFold(cname, normalizeAnswer(name)) {
+				answers = append(answers, cname)
+			}
+		case *dnsmessage.SRVResource:
+			answers = append(answers, net.JoinHostPort(normalizeAnswer(body.Target.String()), strconv.Itoa(int(body.Port))))
+		case *dnsmessage.TXTResource:
+			answers = append(answers, strings.Join(body.TXT, ""))
+		}
+	}
+
+	return resp.RCode, answers, nil
+}
+
+// roundTrip sends query to nameserver over network and returns the
+// matching response.
+func roundTrip(ctx context.Context, network, nameserver string, query dnsmessage.Message) (dnsmessage.Message, error) {
+	packed, err := query.Pack()
+	if err != nil {
+		return dnsmessage.Message{}, fmt.Errorf("packing query failed: %w", err)
+	}
+
+	var dialer net.Dialer
+
+	conn, err := dialer.DialContext(ctx, network, nameserver)
+	if err != nil {
+		return dnsmessage.Message{}, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	// Unblock reads once ctx is canceled
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	resp, err := readResponse(conn, network, packed, query.ID)
+	if err != nil && ctx.Err() != nil {
+		return dnsmessage.Message{}, fmt.Errorf("%w: %w", ctx.Err(), err)
+	}
+
+	return resp, err
+}
+
+// readResponse writes the packed query to conn and reads responses until
+// one with the given ID arrives. Over TCP, messages are length-prefixed and
+// the first response must match.
+func readResponse(conn net.Conn, network string, packed []byte, id uint16) (dnsmessage.Message, error) {
+	stream := network == "tcp"
+
+	if stream {
+		framed := binary.BigEndian.AppendUint16(make([]byte, 0, len(packed)+2), uint16(len(packed))) //nolint:gosec // a packed message is at most 64KiB
+		packed = append(framed, packed...)
+	}
+
+	if _, err := conn.Write(packed); err != nil {
+		return dnsmessage.Message{}, err
+	}
+
+	buf := make([]byte, 65535)
+
+	for {
+		n, err := readMessage(conn, stream, buf)
+		if err != nil {
+			return dnsmessage.Message{}, err
+		}
+
+		var resp dnsmessage.Message
+
+		err = resp.Unpack(buf[:n])
+		if err == nil && (!resp.Response || resp.ID != id) {
+			err = errors.New("unexpected message")
+		}
+
+		switch {
+		case err != nil && stream:
+			return dnsmessage.Message{}, fmt.Errorf("invalid response: %w", err)
+		case err != nil:
+			// A stray or spoofed datagram must not end the query
+			continue
+		case resp.Truncated && !stream:
+			return dnsmessage.Message{}, errTruncated
+		}
+
+		return resp, nil
+	}
+}
+
+// readMessage reads a single message from conn into buf, a datagram or a
+// length-prefixed message of a stream, and returns its length.
+func readMessage(conn net.Conn, stream bool, buf []byte) (int, error) {
+	if !stream {
+		return conn.Read(buf)
+	}
+
+	var size [2]byte
+	if _, err := io.ReadFull(conn, size[:]); err != nil {
+		return 0, err
+	}
+
+	return io.ReadFull(conn, buf[:binary.BigEndian.Uint16(size[:])])
+}
+
+// rcodeLabel returns the response code label of a query: the code of the
+// response, NODATA for an answer-less NOERROR response, and TIMEOUT or
+// ERROR if no response was received.
+func rcodeLabel(rcode dnsmessage.RCode, answers int, err error) string {
+	if err != nil {
+		var netErr net.Error
+		if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+			return rcodeTimeout
+		}
+
+		return rcodeError
+	}
+
+	if rcode == dnsmessage.RCodeSuccess && answers == 0 {
+		return rcodeNoData
+	}
+
+	if name, ok := rcodeNames[rcode]; ok {
+		return name
+	}
+
+	return "RCODE" + strconv.Itoa(int(rcode))
+}
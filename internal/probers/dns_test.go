@@ -0,0 +1,357 @@
+package probers_test
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/metrics"
+	"github.com/dntosas/astrolavos/internal/probers"
+)
+
+const (
+	dnsTypeA   = 1
+	dnsTypeTXT = 16
+
+	// servfailName is answered with SERVFAIL by the DNS stand-in.
+	servfailName = "servfail.astrolavos.test"
+)
+
+// dnsRecord is a single answer served by the DNS stand-in.
+type dnsRecord struct {
+	qtype uint16
+	value string
+}
+
+// startDNSServer runs an in-process UDP DNS stand-in that answers from
+// records and replies NXDOMAIN for unknown names. It returns its address.
+// Names with records of other types only get an empty NOERROR response.
+func startDNSServer(t *testing.T, records map[string][]dnsRecord) string {
+	t.Helper()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start DNS stand-in: %v", err)
+	}
+
+	t.Cleanup(func() { _ = pc.Close() })
+
+	go func() {
+		buf := make([]byte, 1500)
+
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			if resp := dnsAnswer(buf[:n], records); resp != nil {
+				_, _ = pc.WriteTo(resp, addr)
+			}
+		}
+	}()
+
+	return pc.LocalAddr().String()
+}
+
+// dnsAnswer builds the wire-format response for a single-question query.
+func dnsAnswer(query []byte, records map[string][]dnsRecord) []byte {
+	if len(query) < 12 {
+		return nil
+	}
+
+	off := 12
+
+	var labels []string
+
+	for off < len(query) && query[off] != 0 {
+		l := int(query[off])
+		if off+1+l > len(query) {
+			return nil
+		}
+
+		labels = append(labels, string(query[off+1:off+1+l]))
+		off += 1 + l
+	}
+
+	off++ // root label
+	if off+4 > len(query) {
+		return nil
+	}
+
+	qtype := binary.BigEndian.Uint16(query[off:])
+	question := query[12 : off+4]
+	name := strings.ToLower(strings.Join(labels, "."))
+
+	recs, found := records[name]
+
+	var answers []byte
+
+	count := 0
+
+	for _, r := range recs {
+		if r.qtype != qtype {
+			continue
+		}
+
+		var rdata []byte
+
+		switch r.qtype {
+		case dnsTypeA:
+			rdata = net.ParseIP(r.value).To4()
+		case dnsTypeTXT:
+			rdata = append([]byte{byte(len(r.value))}, r.value...)
+		}
+
+		answers = append(answers, 0xc0, 0x0c)
+		answers = binary.BigEndian.AppendUint16(answers, r.qtype)
+		answers = binary.BigEndian.AppendUint16(answers, 1)  // class IN
+		answers = binary.BigEndian.AppendUint32(answers, 60) // TTL
+		answers = binary.BigEndian.AppendUint16(answers, uint16(len(rdata)))
+		answers = append(answers, rdata...)
+		count++
+	}
+
+	flags := uint16(0x8580) // QR, AA, RD, RA
+
+	switch {
+	case name == servfailName:
+		flags |= 2 // SERVFAIL
+	case !found:
+		flags |= 3 // NXDOMAIN
+	}
+
+	resp := append([]byte{}, query[0:2]...)
+	resp = binary.BigEndian.AppendUint16(resp, flags)
+	resp = binary.BigEndian.AppendUint16(resp, 1)
+	resp = binary.BigEndian.AppendUint16(resp, uint16(count))
+	resp = binary.BigEndian.AppendUint16(resp, 0)
+	resp = binary.BigEndian.AppendUint16(resp, 0)
+	resp = append(resp, question...)
+
+	return append(resp, answers...)
+}
+
+// metricValue returns the value of the counter or gauge, or the sample count
+// of the histogram, with the given name and label values from the shared
+// client, or 0 when it does not exist.
+func metricValue(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+
+	return metricValueOf(t, testPromC, name, labels)
+}
+
+// metricValueOf is like metricValue, reading from the client p.
+func metricValueOf(t *testing.T, p *metrics.PrometheusClient, name string, labels map[string]string) float64 {
+	t.Helper()
+
+	v, _ := lookupMetric(t, p, name, labels)
+
+	return v
+}
+
+// lookupMetric is like metricValueOf, but also reports whether the series
+// exists, for values where 0 and a missing series differ.
+func lookupMetric(t *testing.T, p *metrics.PrometheusClient, name string, labels map[string]string) (float64, bool) {
+	t.Helper()
+
+	families, err := p.Registry().Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+
+	for _, mf := range families {
+		if mf.GetName() != name {
+			continue
+		}
+
+	next:
+		for _, m := range mf.GetMetric() {
+			for _, lp := range m.GetLabel() {
+				if v, ok := labels[lp.GetName()]; ok && v != lp.GetValue() {
+					continue next
+				}
+			}
+
+			if m.GetCounter() != nil {
+				return m.GetCounter().GetValue(), true
+			}
+
+			if m.GetHistogram() != nil {
+				return float64(m.GetHistogram().GetSampleCount()), true
+			}
+
+			return m.GetGauge().GetValue(), true
+		}
+	}
+
+	return 0, false
+}
+
+func runDNSProbe(t *testing.T, opts probers.ProberOptions) {
+	t.Helper()
+
+	opts.WG = newTestWG()
+	opts.PromClient = testPromC
+	opts.Interval = 1 * time.Second
+	opts.Timeout = 2 * time.Second
+	opts.Retries = 1
+	opts.IsOneOff = true
+
+	probers.NewDNS(probers.NewProberConfig(opts)).Run(context.Background())
+}
+
+func TestDNS_OneOff_ValidAnswers(t *testing.T) {
+	addr := startDNSServer(t, map[string][]dnsRecord{
+		"valid.astrolavos.test": {{dnsTypeA, "10.0.0.1"}, {dnsTypeA, "10.0.0.2"}},
+	})
+
+	runDNSProbe(t, probers.ProberOptions{
+		Endpoint:        "valid.astrolavos.test.",
+		Tag:             "dns-valid",
+		RecordType:      "A",
+		Resolver:        addr,
+		ExpectedAnswers: []string{"10.0.0.2"},
+		AnswerPattern:   regexp.MustCompile(`^10\.0\.0\.\d+$`),
+	})
+
+	labels := map[string]string{"domain": "valid.astrolavos.test.", "tag": "dns-valid"}
+
+	if v := metricValue(t, "astrolavos_errors_total", labels); v != 0 {
+		t.Errorf("expected no errors, got %v", v)
+	}
+
+	if v := metricValue(t, "astrolavos_dns_answers", labels); v != 2 {
+		t.Errorf("expected 2 answers, got %v", v)
+	}
+
+	labels["rcode"] = "NOERROR"
+	if v := metricValue(t, "astrolavos_dns_responses_total", labels); v != 1 {
+		t.Errorf("expected 1 NOERROR response, got %v", v)
+	}
+}
+
+func TestDNS_OneOff_StaleAnswerIsError(t *testing.T) {
+	addr := startDNSServer(t, map[string][]dnsRecord{
+		"stale.astrolavos.test": {{dnsTypeA, "10.0.0.9"}},
+	})
+
+	runDNSProbe(t, probers.ProberOptions{
+		Endpoint:        "stale.astrolavos.test.",
+		Tag:             "dns-stale",
+		RecordType:      "A",
+		Resolver:        addr,
+		ExpectedAnswers: []string{"10.0.0.1"},
+	})
+
+	labels := map[string]string{"domain": "stale.astrolavos.test.", "tag": "dns-stale", "error": "assertion_failed"}
+	if v := metricValue(t, "astrolavos_errors_total", labels); v != 1 {
+		t.Errorf("expected 1 assertion_failed error, got %v", v)
+	}
+}
+
+func TestDNS_OneOff_PatternMismatchIsError(t *testing.T) {
+	addr := startDNSServer(t, map[string][]dnsRecord{
+		"txt.astrolavos.test": {{dnsTypeTXT, "v=spf1 -all"}},
+	})
+
+	runDNSProbe(t, probers.ProberOptions{
+		Endpoint:      "txt.astrolavos.test.",
+		Tag:           "dns-txt",
+		RecordType:    "TXT",
+		Resolver:      addr,
+		AnswerPattern: regexp.MustCompile(`^google-site-verification=`),
+	})
+
+	labels := map[string]string{"domain": "txt.astrolavos.test.", "tag": "dns-txt", "error": "assertion_failed"}
+	if v := metricValue(t, "astrolavos_errors_total", labels); v != 1 {
+		t.Errorf("expected 1 assertion_failed error, got %v", v)
+	}
+}
+
+func TestDNS_OneOff_NXDOMAIN(t *testing.T) {
+	addr := startDNSServer(t, map[string][]dnsRecord{})
+
+	runDNSProbe(t, probers.ProberOptions{
+		Endpoint:   "missing.astrolavos.test.",
+		Tag:        "dns-nx",
+		RecordType: "A",
+		Resolver:   addr,
+	})
+
+	labels := map[string]string{"domain": "missing.astrolavos.test.", "tag": "dns-nx", "rcode": "NXDOMAIN"}
+	if v := metricValue(t, "astrolavos_dns_responses_total", labels); v != 1 {
+		t.Errorf("expected 1 NXDOMAIN response, got %v", v)
+	}
+}
+
+func TestDNS_OneOff_ResponseCodes(t *testing.T) {
+	addr := startDNSServer(t, map[string][]dnsRecord{
+		"nodata.astrolavos.test": {{dnsTypeTXT, "v=spf1 -all"}},
+	})
+
+	// A port nothing listens on refuses the query without a response
+	closed, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to reserve a port: %v", err)
+	}
+
+	closedAddr := closed.LocalAddr().String()
+	_ = closed.Close()
+
+	tests := []struct {
+		name       string
+		endpoint   string
+		recordType string
+		resolver   string
+		wantRcode  string
+	}{
+		{"name without records of the type", "nodata.astrolavos.test.", "A", addr, "NODATA"},
+		{"name without a CNAME", "nodata.astrolavos.test.", "CNAME", addr, "NODATA"},
+		{"server failure", servfailName + ".", "A", addr, "SERVFAIL"},
+		{"no response", "nodata.astrolavos.test.", "A", closedAddr, "ERROR"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tag := "dns-rcode-" + strings.ToLower(tt.recordType) + "-" + tt.wantRcode
+
+			runDNSProbe(t, probers.ProberOptions{
+				Endpoint:   tt.endpoint,
+				Tag:        tag,
+				RecordType: tt.recordType,
+				Resolver:   tt.resolver,
+			})
+
+			labels := map[string]string{"domain": tt.endpoint, "tag": tag, "rcode": tt.wantRcode}
+			if v := metricValue(t, "astrolavos_dns_responses_total", labels); v != 1 {
+				t.Errorf("expected 1 %s response, got %v", tt.wantRcode, v)
+			}
+
+			delete(labels, "rcode")
+
+			if v := metricValue(t, "astrolavos_errors_total", labels); v != 1 {
+				t.Errorf("expected the probe to fail, got %v errors", v)
+			}
+		})
+	}
+}
+
+func TestDNSString(t *testing.T) {
+	cfg := probers.NewProberConfig(probers.ProberOptions{
+		Endpoint:   "example.com",
+		Interval:   5 * time.Second,
+		Tag:        "dns",
+		Retries:    2,
+		RecordType: "AAAA",
+	})
+
+	s := probers.NewDNS(cfg).String()
+	if s != "DNS Prober Endpoint: example.com - Record: AAAA - Interval: 5s - Tag: dns - Retries: 2" {
+		t.Errorf("unexpected String() output: %s", s)
+	}
+}
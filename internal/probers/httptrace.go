@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/dntosas/astrolavos/internal/model"
+
 	log "github.com/sirupsen/logrus"
 )
 
@@ -15,6 +17,8 @@ type HTTPTrace struct {
 
 // NewHTTPTrace creates a new HTTPTrace prober with the given configuration.
 func NewHTTPTrace(c ProberConfig) *HTTPTrace {
+	c.target = c.newTarget("httptrace")
+
 	return &HTTPTrace{c}
 }
 
@@ -39,24 +43,37 @@ func (h *HTTPTrace) probe(ctx context.Context) {
 		return traceErr
 	})
 
-	// Determine status code for the request counter
-	statusCode := ""
+	// Determine status code and peer for the request counter and result
+	statusCode, remoteAddr := "", ""
 	if t != nil {
-		statusCode = t.statusCode
+		statusCode, remoteAddr = t.statusCode, t.remoteAddr()
 	}
 
-	h.promC.UpdateRequestsCounter(h.endpoint, "httptrace", h.tag, statusCode)
+	h.promC.UpdateRequestsCounter(h.target, statusCode)
 
 	if err != nil {
 		log.Errorf("HTTPTrace %s failed after %d attempts: %v", h, h.retries, err)
-		h.promC.UpdateErrorsCounter(h.endpoint, "httptrace", h.tag, err)
-	} else {
-		// Update all exposed Prometheus metrics histograms
-		h.promC.UpdateDNSHistogram(h.endpoint, "httptrace", h.tag, t.dnsDuration)
-		h.promC.UpdateConnHistogram(h.endpoint, "httptrace", h.tag, t.connDuration)
-		h.promC.UpdateTLSHistogram(h.endpoint, "httptrace", h.tag, t.tlsDuration)
-		h.promC.UpdateGotConnHistogram(h.endpoint, "httptrace", h.tag, t.gotConnDuration)
-		h.promC.UpdateFirstByteHistogram(h.endpoint, "httptrace", h.tag, t.firstByteDuration)
-		h.promC.UpdateTotalHistogram(h.endpoint, "httptrace", h.tag, t.totalDuration)
+		h.promC.UpdateErrorsCounter(h.target, err)
+		h.report(model.Result{Err: err, StatusCode: statusCode, RemoteAddr: remoteAddr})
+
+		return
 	}
+
+	// Update all exposed Prometheus metrics histograms
+	h.promC.UpdateDNSHistogram(h.target, t.dnsDuration)
+	h.promC.UpdateConnHistogram(h.target, t.connDuration)
+	h.promC.UpdateTLSHistogram(h.target, t.tlsDuration)
+	h.promC.UpdateGotConnHistogram(h.target, t.gotConnDuration)
+	h.promC.UpdateFirstByteHistogram(h.target, t.firstByteDuration)
+	h.promC.UpdateTotalHistogramWithTrace(h.target, t.totalDuration, t.traceID)
+
+	if t.tlsState != nil {
+		h.promC.UpdateTLSMetrics(h.target, *t.tlsState)
+	}
+
+	if t.tcpInfo != nil {
+		h.promC.UpdateTCPInfoMetrics(h.target, t.tcpInfo, !t.connReused)
+	}
+
+	h.report(t.result())
 }
@@ -0,0 +1,106 @@
+package probers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/metrics"
+	"github.com/dntosas/astrolavos/internal/model"
+)
+
+// Request phases that can be given their own deadline.
+const (
+	phaseDNS       = "dns"
+	phaseConnect   = "connect"
+	phaseTLS       = "tls"
+	phaseFirstByte = "firstByte"
+)
+
+// phaseDeadlines cancels an in-flight request, with a *metrics.PhaseTimeoutError
+// as the cause, when one of its phases runs longer than its budget.
+// It is driven by the httptrace.ClientTrace hooks of the request.
+type phaseDeadlines struct {
+	limits model.PhaseTimeouts
+	cancel context.CancelCauseFunc
+
+	mu     sync.Mutex
+	timers map[string]*time.Timer
+}
+
+func newPhaseDeadlines(limits model.PhaseTimeouts, cancel context.CancelCauseFunc) *phaseDeadlines {
+	return &phaseDeadlines{
+		limits: limits,
+		cancel: cancel,
+		timers: map[string]*time.Timer{},
+	}
+}
+
+func (d *phaseDeadlines) limit(phase string) time.Duration {
+	switch phase {
+	case phaseDNS:
+		return d.limits.DNS
+	case phaseConnect:
+		return d.limits.Connect
+	case phaseTLS:
+		return d.limits.TLS
+	case phaseFirstByte:
+		return d.limits.FirstByte
+	}
+
+	return 0
+}
+
+// start arms the deadline of phase. It is a no-op without a budget for the
+// phase or when the phase is already running (e.g. parallel dials).
+func (d *phaseDeadlines) start(phase string) {
+	if d == nil {
+		return
+	}
+
+	limit := d.limit(phase)
+	if limit <= 0 {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, running := d.timers[phase]; running {
+		return
+	}
+
+	d.timers[phase] = time.AfterFunc(limit, func() {
+		d.cancel(&metrics.PhaseTimeoutError{Phase: phase, Timeout: limit})
+	})
+}
+
+// stop disarms the deadline of phase.
+func (d *phaseDeadlines) stop(phase string) {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if timer, running := d.timers[phase]; running {
+		timer.Stop()
+		delete(d.timers, phase)
+	}
+}
+
+// stopAll disarms every running deadline once the request is done.
+func (d *phaseDeadlines) stopAll() {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for phase, timer := range d.timers {
+		timer.Stop()
+		delete(d.timers, phase)
+	}
+}
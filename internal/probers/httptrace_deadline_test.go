@@ -0,0 +1,96 @@
+package probers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/model"
+	"github.com/dntosas/astrolavos/internal/probers"
+)
+
+// newSlowServer returns a server that waits for delay before responding.
+func newSlowServer(delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func runTimeoutProbe(t *testing.T, srv *httptest.Server, tag string, timeout time.Duration, phases model.PhaseTimeouts) map[string]string {
+	t.Helper()
+
+	cfg := probers.NewProberConfig(probers.ProberOptions{
+		WG:            newTestWG(),
+		PromClient:    testPromC,
+		Endpoint:      srv.URL,
+		Tag:           tag,
+		Interval:      1 * time.Second,
+		Retries:       1,
+		IsOneOff:      true,
+		Timeout:       timeout,
+		PhaseTimeouts: phases,
+	})
+
+	done := make(chan struct{})
+
+	go func() {
+		probers.NewHTTPTrace(cfg).Run(context.Background())
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("probe did not honour its timeout")
+	}
+
+	return map[string]string{"domain": srv.URL, "tag": tag, "prober_type": "httptrace"}
+}
+
+func TestHTTPTrace_TotalTimeout(t *testing.T) {
+	srv := newSlowServer(2 * time.Second)
+	defer srv.Close()
+
+	labels := runTimeoutProbe(t, srv, "total-timeout", 100*time.Millisecond, model.PhaseTimeouts{})
+
+	labels["error"] = "timeout"
+	if v := metricValue(t, "astrolavos_errors_total", labels); v != 1 {
+		t.Errorf("expected 1 timeout error, got %v", v)
+	}
+}
+
+func TestHTTPTrace_FirstBytePhaseTimeout(t *testing.T) {
+	srv := newSlowServer(2 * time.Second)
+	defer srv.Close()
+
+	labels := runTimeoutProbe(t, srv, "firstbyte-timeout", 3*time.Second, model.PhaseTimeouts{FirstByte: 100 * time.Millisecond})
+
+	labels["error"] = "firstbyte_timeout"
+	if v := metricValue(t, "astrolavos_errors_total", labels); v != 1 {
+		t.Errorf("expected 1 firstbyte_timeout error, got %v", v)
+	}
+}
+
+func TestHTTPTrace_PhaseTimeoutsWithinBudget(t *testing.T) {
+	srv := newSlowServer(0)
+	defer srv.Close()
+
+	labels := runTimeoutProbe(t, srv, "within-budget", 3*time.Second, model.PhaseTimeouts{
+		DNS:       time.Second,
+		Connect:   time.Second,
+		TLS:       time.Second,
+		FirstByte: time.Second,
+	})
+
+	if v := metricValue(t, "astrolavos_errors_total", labels); v != 0 {
+		t.Errorf("expected no errors, got %v", v)
+	}
+}
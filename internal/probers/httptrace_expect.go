@@ -0,0 +1,86 @@
+package probers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dntosas/astrolavos/internal/jsonpath"
+	"github.com/dntosas/astrolavos/internal/metrics"
+	"github.com/dntosas/astrolavos/internal/model"
+)
+
+// checkExpectations asserts the response against the configured expectations.
+// Failures wrap metrics.ErrAssertionFailed so they get their own error category.
+func (h *HTTPTrace) checkExpectations(resp *http.Response, body []byte) error {
+	e := h.expect
+	if e == nil {
+		return nil
+	}
+
+	if len(e.StatusCodes) > 0 && !statusAccepted(e, resp.StatusCode) {
+		return fmt.Errorf("%w: status code %d is not accepted", metrics.ErrAssertionFailed, resp.StatusCode)
+	}
+
+	for name, re := range e.Headers {
+		values := resp.Header.Values(name)
+		if len(values) == 0 {
+			return fmt.Errorf("%w: response header %q is missing", metrics.ErrAssertionFailed, name)
+		}
+
+		if !re.MatchString(strings.Join(values, ", ")) {
+			return fmt.Errorf("%w: response header %q does not match %q", metrics.ErrAssertionFailed, name, re)
+		}
+	}
+
+	if e.BodyContains != "" && !bytes.Contains(body, []byte(e.BodyContains)) {
+		return fmt.Errorf("%w: response body does not contain %q", metrics.ErrAssertionFailed, e.BodyContains)
+	}
+
+	if e.BodyRegex != nil && !e.BodyRegex.Match(body) {
+		return fmt.Errorf("%w: response body does not match %q", metrics.ErrAssertionFailed, e.BodyRegex)
+	}
+
+	if len(e.JSONPaths) > 0 {
+		return checkJSONPaths(e.JSONPaths, body)
+	}
+
+	return nil
+}
+
+// statusAccepted reports whether code falls in any of the accepted ranges.
+func statusAccepted(e *model.Expectations, code int) bool {
+	for _, r := range e.StatusCodes {
+		if r.Contains(code) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkJSONPaths decodes the body and compares every selected value.
+func checkJSONPaths(paths []model.JSONPathExpectation, body []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return fmt.Errorf("%w: response body is not valid JSON: %w", metrics.ErrAssertionFailed, err)
+	}
+
+	for _, jp := range paths {
+		v, ok := jp.Path.Lookup(doc)
+		if !ok {
+			return fmt.Errorf("%w: JSONPath %s not found in response body", metrics.ErrAssertionFailed, jp.Path)
+		}
+
+		if got := jsonpath.Format(v); got != jp.Equals {
+			return fmt.Errorf("%w: JSONPath %s is %q, expected %q", metrics.ErrAssertionFailed, jp.Path, got, jp.Equals)
+		}
+	}
+
+	return nil
+}
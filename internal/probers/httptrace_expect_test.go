@@ -0,0 +1,132 @@
+package probers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/jsonpath"
+	"github.com/dntosas/astrolavos/internal/metrics"
+	"github.com/dntosas/astrolavos/internal/model"
+	"github.com/dntosas/astrolavos/internal/probers"
+)
+
+// runExpectProbe probes srv once with its own Prometheus client, so cases
+// probing a reused port do not share counters, and returns the client and
+// the labels of the probe.
+func runExpectProbe(t *testing.T, srv *httptest.Server, tag string, expect *model.Expectations) (*metrics.PrometheusClient, map[string]string) {
+	t.Helper()
+
+	promC := metrics.NewPrometheusClient(metrics.Options{IsOneOff: true})
+
+	cfg := probers.NewProberConfig(probers.ProberOptions{
+		WG:         newTestWG(),
+		PromClient: promC,
+		Endpoint:   srv.URL,
+		Tag:        tag,
+		Interval:   1 * time.Second,
+		Retries:    1,
+		IsOneOff:   true,
+		Expect:     expect,
+	})
+
+	probers.NewHTTPTrace(cfg).Run(context.Background())
+
+	return promC, map[string]string{"domain": srv.URL, "tag": tag, "prober_type": "httptrace"}
+}
+
+func TestHTTPTrace_Expect_Satisfied(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"up","checks":[{"name":"db","ok":true}]}`))
+	}))
+	defer srv.Close()
+
+	promC, labels := runExpectProbe(t, srv, "expect-ok", &model.Expectations{
+		StatusCodes:  []model.StatusCodeRange{{Min: 200, Max: 299}},
+		BodyContains: `"status"`,
+		BodyRegex:    regexp.MustCompile(`"ok":\s*true`),
+		JSONPaths: []model.JSONPathExpectation{
+			{Path: jsonpath.MustParse("$.status"), Equals: "up"},
+			{Path: jsonpath.MustParse("$.checks[0].ok"), Equals: "true"},
+		},
+		Headers:     map[string]*regexp.Regexp{"content-type": regexp.MustCompile(`^application/json`)},
+		MaxBodySize: 1024,
+	})
+
+	if v := metricValueOf(t, promC, "astrolavos_errors_total", labels); v != 0 {
+		t.Errorf("expected no errors, got %v", v)
+	}
+
+	if v := metricValueOf(t, promC, "astrolavos_total_latency_seconds", labels); v != 1 {
+		t.Errorf("expected 1 total latency observation, got %v", v)
+	}
+}
+
+func TestHTTPTrace_Expect_Failures(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		expect *model.Expectations
+	}{
+		{
+			name:   "status code",
+			status: http.StatusServiceUnavailable,
+			expect: &model.Expectations{StatusCodes: []model.StatusCodeRange{{Min: 200, Max: 299}}},
+		},
+		{
+			name:   "body contains",
+			status: http.StatusOK,
+			body:   "degraded",
+			expect: &model.Expectations{BodyContains: "healthy"},
+		},
+		{
+			name:   "body regex",
+			status: http.StatusOK,
+			body:   "version=1",
+			expect: &model.Expectations{BodyRegex: regexp.MustCompile(`version=2`)},
+		},
+		{
+			name:   "json path",
+			status: http.StatusOK,
+			body:   `{"status":"down"}`,
+			expect: &model.Expectations{JSONPaths: []model.JSONPathExpectation{{Path: jsonpath.MustParse("$.status"), Equals: "up"}}},
+		},
+		{
+			name:   "missing header",
+			status: http.StatusOK,
+			expect: &model.Expectations{Headers: map[string]*regexp.Regexp{"x-request-id": regexp.MustCompile(``)}},
+		},
+		{
+			name:   "max body size",
+			status: http.StatusOK,
+			body:   "this body is longer than sixteen bytes",
+			expect: &model.Expectations{MaxBodySize: 16},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			promC, labels := runExpectProbe(t, srv, "expect-fail", tt.expect)
+
+			if v := metricValueOf(t, promC, "astrolavos_total_latency_seconds", labels); v != 0 {
+				t.Errorf("expected no latency observation for failed assertion, got %v", v)
+			}
+
+			labels["error"] = "assertion_failed"
+			if v := metricValueOf(t, promC, "astrolavos_errors_total", labels); v != 1 {
+				t.Errorf("expected 1 assertion_failed error, got %v", v)
+			}
+		})
+	}
+}
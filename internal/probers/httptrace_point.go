@@ -1,15 +1,22 @@
 package probers
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptrace"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/dntosas/astrolavos/internal/metrics"
+	"github.com/dntosas/astrolavos/internal/model"
+
 	log "github.com/sirupsen/logrus"
 )
 
@@ -26,10 +33,15 @@ type tracePoint struct {
 	tlsStartTime time.Time
 	tlsDoneTime  time.Time
 	tlsDuration  float64
+	tlsState     *tls.ConnectionState
 
 	gotConnTime     time.Time
 	gotConnDuration float64
+	conn            net.Conn
+	connReused      bool
+	tcpInfo         *metrics.TCPInfo
 
+	wroteRequestTime  time.Time
 	firstByteTime     time.Time
 	firstByteDuration float64
 
@@ -38,6 +50,10 @@ type tracePoint struct {
 	totalDuration  float64
 
 	statusCode string
+	// traceID identifies the sampled trace of the request, if any
+	traceID string
+
+	deadlines *phaseDeadlines
 
 	err error
 }
@@ -46,15 +62,42 @@ func newTracePoint() *tracePoint {
 	return &tracePoint{}
 }
 
+// remoteAddr returns the address of the connection the request was sent
+// on, or an empty string if none was obtained.
+func (t *tracePoint) remoteAddr() string {
+	if t.conn == nil {
+		return ""
+	}
+
+	return t.conn.RemoteAddr().String()
+}
+
+// result returns the outcome of a successful trace.
+func (t *tracePoint) result() model.Result {
+	return model.Result{
+		StatusCode: t.statusCode,
+		RemoteAddr: t.remoteAddr(),
+		DNS:        seconds(t.dnsDuration),
+		Connect:    seconds(t.connDuration),
+		TLS:        seconds(t.tlsDuration),
+		GotConn:    seconds(t.gotConnDuration),
+		FirstByte:  seconds(t.firstByteDuration),
+		Total:      seconds(t.totalDuration),
+	}
+}
+
 func (t *tracePoint) setDNSDuration() {
 	t.dnsDuration = (t.dnsDoneTime.Sub(t.dnsStartTime)).Seconds()
 }
 
 func (t *tracePoint) dnsStartHandler(_ httptrace.DNSStartInfo) {
 	t.dnsStartTime = time.Now()
+	t.deadlines.start(phaseDNS)
 }
 
 func (t *tracePoint) dnsDoneHandler(d httptrace.DNSDoneInfo) {
+	t.deadlines.stop(phaseDNS)
+
 	if d.Err != nil {
 		t.err = fmt.Errorf("DNS resolution failed: %w", d.Err)
 
@@ -70,9 +113,12 @@ func (t *tracePoint) setConnDuration() {
 
 func (t *tracePoint) connStartHandler(_, _ string) {
 	t.connStartTime = time.Now()
+	t.deadlines.start(phaseConnect)
 }
 
 func (t *tracePoint) connDoneHandler(_, _ string, err error) {
+	t.deadlines.stop(phaseConnect)
+
 	if err != nil {
 		t.err = fmt.Errorf("TCP connection failed: %w", err)
 
@@ -88,9 +134,12 @@ func (t *tracePoint) setTLSDuration() {
 
 func (t *tracePoint) tlsStartHandler() {
 	t.tlsStartTime = time.Now()
+	t.deadlines.start(phaseTLS)
 }
 
-func (t *tracePoint) tlsDoneHandler(_ tls.ConnectionState, err error) {
+func (t *tracePoint) tlsDoneHandler(state tls.ConnectionState, err error) {
+	t.deadlines.stop(phaseTLS)
+
 	if err != nil {
 		t.err = fmt.Errorf("TLS handshake failed: %w", err)
 
@@ -98,6 +147,7 @@ func (t *tracePoint) tlsDoneHandler(_ tls.ConnectionState, err error) {
 	}
 
 	t.tlsDoneTime = time.Now()
+	t.tlsState = &state
 }
 
 func (t *tracePoint) getConnTimeHandler(_ string) {
@@ -108,16 +158,29 @@ func (t *tracePoint) setGotConnDuration() {
 	t.gotConnDuration = (t.gotConnTime.Sub(t.totalStartTime)).Seconds()
 }
 
-func (t *tracePoint) gotConnTimeHandler(_ httptrace.GotConnInfo) {
+func (t *tracePoint) gotConnTimeHandler(info httptrace.GotConnInfo) {
 	t.gotConnTime = time.Now()
+	t.conn = info.Conn
+	t.connReused = info.Reused
 }
 
 func (t *tracePoint) setFirstByteDuration() {
 	t.firstByteDuration = (t.firstByteTime.Sub(t.totalStartTime)).Seconds()
 }
 
+// wroteRequestHandler starts the wait for the first response byte.
+func (t *tracePoint) wroteRequestHandler(_ httptrace.WroteRequestInfo) {
+	t.wroteRequestTime = time.Now()
+	t.deadlines.start(phaseFirstByte)
+}
+
 func (t *tracePoint) firstByteTimeHandler() {
 	t.firstByteTime = time.Now()
+	t.deadlines.stop(phaseFirstByte)
+
+	// Sample kernel statistics while the connection is still guaranteed open,
+	// as the transport may close it as soon as the response is read
+	t.tcpInfo = connTCPInfo(t.conn)
 }
 
 func (t *tracePoint) setTotalDuration() {
@@ -133,17 +196,88 @@ func (h *HTTPTrace) getClient() *http.Client {
 		return h.client
 	}
 
-	return getCustomClient(h.reuseConnection, h.skipTLS)
+	return getCustomClient(h.reuseConnection, h.skipTLS, h.timeout)
+}
+
+// newRequest builds the probe request from the configured method, headers and body.
+func (h *HTTPTrace) newRequest(ctx context.Context) (*http.Request, error) {
+	method := h.method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var body io.Reader
+	if len(h.body) > 0 {
+		body = bytes.NewReader(h.body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, h.endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range h.headers {
+		// The Host header is ignored by the client unless set on the request itself
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+
+			continue
+		}
+
+		req.Header.Set(name, value)
+	}
+
+	return req, nil
 }
 
-func (h *HTTPTrace) trace(ctx context.Context) (*tracePoint, error) {
-	t := newTracePoint()
+// readBody reads the whole response body, failing once it grows past the
+// expected maximum size when one is configured.
+func (h *HTTPTrace) readBody(resp *http.Response) ([]byte, error) {
+	if h.expect == nil || h.expect.MaxBodySize <= 0 {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("reading response body failed: %w", err)
+		}
+
+		return body, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, h.expect.MaxBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading response body failed: %w", err)
+	}
+
+	if int64(len(body)) > h.expect.MaxBodySize {
+		return nil, fmt.Errorf("%w: response body exceeds %d bytes", metrics.ErrAssertionFailed, h.expect.MaxBodySize)
+	}
+
+	return body, nil
+}
+
+func (h *HTTPTrace) trace(ctx context.Context) (t *tracePoint, err error) {
+	t = newTracePoint()
+
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.endpoint, nil)
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+
+	ctx, cancelCause := context.WithCancelCause(ctx)
+	defer cancelCause(nil)
+
+	t.deadlines = newPhaseDeadlines(h.phaseTimeouts, cancelCause)
+	defer t.deadlines.stopAll()
+
+	req, err := h.newRequest(ctx)
 	if err != nil {
 		return t, fmt.Errorf("creation of new request failed: %w", err)
 	}
 
+	spanCtx, span := h.startSpan(ctx, req)
+	defer func() { h.endSpan(spanCtx, span, t, err) }()
+
 	trace := &httptrace.ClientTrace{
 		GetConn:              t.getConnTimeHandler,
 		DNSStart:             t.dnsStartHandler,
@@ -153,6 +287,7 @@ func (h *HTTPTrace) trace(ctx context.Context) (*tracePoint, error) {
 		TLSHandshakeStart:    t.tlsStartHandler,
 		TLSHandshakeDone:     t.tlsDoneHandler,
 		GotConn:              t.gotConnTimeHandler,
+		WroteRequest:         t.wroteRequestHandler,
 		GotFirstResponseByte: t.firstByteTimeHandler,
 	}
 
@@ -160,27 +295,39 @@ func (h *HTTPTrace) trace(ctx context.Context) (*tracePoint, error) {
 
 	resp, err := h.getClient().Do(req)
 	if err != nil {
+		// Report the phase that blew its budget rather than a bare cancellation
+		var phaseErr *metrics.PhaseTimeoutError
+		if errors.As(context.Cause(ctx), &phaseErr) {
+			return t, fmt.Errorf("request failed: %w", phaseErr)
+		}
+
 		return t, fmt.Errorf("request failed: %w", err)
 	}
 
+	t.statusCode = strconv.Itoa(resp.StatusCode)
+
 	// Read and close response body
-	_, err = io.ReadAll(resp.Body)
+	body, err := h.readBody(resp)
 	if err != nil {
-		return t, fmt.Errorf("reading response body failed: %w", err)
+		_ = resp.Body.Close()
+
+		return t, err
 	}
 
 	if err = resp.Body.Close(); err != nil {
 		return t, fmt.Errorf("closing response body failed: %w", err)
 	}
 
-	t.statusCode = strconv.Itoa(resp.StatusCode)
-
 	t.totalDoneHandler()
 
 	if t.err != nil {
 		return t, fmt.Errorf("trace failed: %w", t.err)
 	}
 
+	if err = h.checkExpectations(resp, body); err != nil {
+		return t, err
+	}
+
 	// Calculate all durations
 	t.setDNSDuration()
 	t.setConnDuration()
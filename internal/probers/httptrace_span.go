@@ -0,0 +1,83 @@
+package probers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/metrics"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startSpan starts the client span of a probe request and injects its
+// context into the request as a W3C traceparent header, so the server side
+// trace of the request joins the probe's.
+func (h *HTTPTrace) startSpan(ctx context.Context, req *http.Request) (context.Context, trace.Span) {
+	ctx, span := h.tracer.Start(ctx, req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("url.full", h.endpoint),
+			attribute.String("astrolavos.tag", h.tag),
+		),
+	)
+
+	propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	return ctx, span
+}
+
+// endSpan records the phases captured by t as child spans of span, from
+// their timestamps, and ends it. A phase that started but never completed
+// ends with the request and is marked as failed.
+func (h *HTTPTrace) endSpan(ctx context.Context, span trace.Span, t *tracePoint, err error) {
+	end := time.Now()
+
+	phase := func(name string, start, done time.Time) {
+		if start.IsZero() {
+			return
+		}
+
+		_, child := h.tracer.Start(ctx, name, trace.WithTimestamp(start))
+
+		if done.IsZero() {
+			child.SetStatus(codes.Error, "phase did not complete")
+			done = end
+		}
+
+		child.End(trace.WithTimestamp(done))
+	}
+
+	phase("DNS lookup", t.dnsStartTime, t.dnsDoneTime)
+	phase("TCP connection", t.connStartTime, t.connDoneTime)
+	phase("TLS handshake", t.tlsStartTime, t.tlsDoneTime)
+	phase("Server processing", t.wroteRequestTime, t.firstByteTime)
+	phase("Content transfer", t.firstByteTime, t.totalDoneTime)
+
+	if t.statusCode != "" {
+		if code, convErr := strconv.Atoi(t.statusCode); convErr == nil {
+			span.SetAttributes(attribute.Int("http.response.status_code", code))
+		}
+	}
+
+	if addr := t.remoteAddr(); addr != "" {
+		span.SetAttributes(attribute.String("network.peer.address", addr))
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetAttributes(attribute.String("error.type", metrics.CategorizeError(err)))
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	if sc := span.SpanContext(); sc.IsSampled() {
+		t.traceID = sc.TraceID().String()
+	}
+
+	span.End(trace.WithTimestamp(end))
+}
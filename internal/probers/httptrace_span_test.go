@@ -0,0 +1,154 @@
+package probers_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/probers"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func runTracedProbe(t *testing.T, endpoint, tag string) []sdktrace.ReadOnlySpan {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	cfg := probers.NewProberConfig(probers.ProberOptions{
+		WG:         newTestWG(),
+		PromClient: testPromC,
+		Tracer:     provider.Tracer("test"),
+		Endpoint:   endpoint,
+		Tag:        tag,
+		Interval:   1 * time.Second,
+		Retries:    1,
+		IsOneOff:   true,
+	})
+
+	probers.NewHTTPTrace(cfg).Run(context.Background())
+
+	return recorder.Ended()
+}
+
+func spansByName(spans []sdktrace.ReadOnlySpan) map[string]sdktrace.ReadOnlySpan {
+	byName := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range spans {
+		byName[s.Name()] = s
+	}
+
+	return byName
+}
+
+func TestHTTPTrace_Spans(t *testing.T) {
+	traceparent := make(chan string, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		traceparent <- r.Header.Get("traceparent")
+	}))
+	defer srv.Close()
+
+	spans := spansByName(runTracedProbe(t, srv.URL, "traced"))
+
+	root, ok := spans[http.MethodGet]
+	if !ok {
+		t.Fatalf("expected a %s span, got %v", http.MethodGet, spans)
+	}
+
+	if root.Status().Code == codes.Error {
+		t.Errorf("expected the probe span not to fail, got %v", root.Status())
+	}
+
+	want := "00-" + root.SpanContext().TraceID().String() + "-" + root.SpanContext().SpanID().String() + "-01"
+	if got := <-traceparent; got != want {
+		t.Errorf("expected traceparent %q, got %q", want, got)
+	}
+
+	for _, name := range []string{"TCP connection", "Server processing", "Content transfer"} {
+		child, ok := spans[name]
+		if !ok {
+			t.Errorf("expected a %q span", name)
+
+			continue
+		}
+
+		if child.Parent().SpanID() != root.SpanContext().SpanID() {
+			t.Errorf("expected %q to be a child of the probe span", name)
+		}
+
+		if child.StartTime().Before(root.StartTime()) || child.EndTime().After(root.EndTime()) {
+			t.Errorf("expected %q to be within the probe span", name)
+		}
+	}
+
+	if _, ok := spans["TLS handshake"]; ok {
+		t.Error("expected no TLS handshake span for plain http")
+	}
+}
+
+func TestHTTPTrace_Spans_Failure(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	endpoint := "http://" + ln.Addr().String()
+	_ = ln.Close()
+
+	spans := spansByName(runTracedProbe(t, endpoint, "traced-failure"))
+
+	root, ok := spans[http.MethodGet]
+	if !ok {
+		t.Fatalf("expected a %s span, got %v", http.MethodGet, spans)
+	}
+
+	if root.Status().Code != codes.Error {
+		t.Errorf("expected the probe span to fail, got %v", root.Status())
+	}
+
+	conn, ok := spans["TCP connection"]
+	if !ok {
+		t.Fatal("expected a TCP connection span")
+	}
+
+	if conn.Status().Code != codes.Error {
+		t.Errorf("expected the TCP connection span to fail, got %v", conn.Status())
+	}
+}
+
+func TestHTTPTrace_Exemplar(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {}))
+	defer srv.Close()
+
+	spans := spansByName(runTracedProbe(t, srv.URL, "traced-exemplar"))
+	traceID := spans[http.MethodGet].SpanContext().TraceID().String()
+
+	families, err := testPromC.Registry().Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+
+	for _, mf := range families {
+		if mf.GetName() != "astrolavos_total_latency_seconds" {
+			continue
+		}
+
+		for _, m := range mf.GetMetric() {
+			for _, b := range m.GetHistogram().GetBucket() {
+				for _, lp := range b.GetExemplar().GetLabel() {
+					if lp.GetName() == "trace_id" && lp.GetValue() == traceID {
+						return
+					}
+				}
+			}
+		}
+	}
+
+	t.Errorf("expected an exemplar with trace_id %s", traceID)
+}
@@ -0,0 +1,96 @@
+package probers
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+)
+
+// Measurement is the detailed outcome of a single ad-hoc probe. Phases run
+// back to back from the start of the request, so they add up to Total, and
+// are zero for phases that did not happen, e.g. DNS for an IP address or
+// every connection phase for a reused connection.
+type Measurement struct {
+	StatusCode string
+	RemoteAddr string
+	ConnReused bool
+	// TLSState is the negotiated TLS connection, if any.
+	TLSState *tls.ConnectionState
+
+	DNS              time.Duration
+	Connect          time.Duration
+	TLS              time.Duration
+	ServerProcessing time.Duration
+	Transfer         time.Duration
+	Total            time.Duration
+	// FirstByte is the time from the start of the request until the first
+	// response byte.
+	FirstByte time.Duration
+}
+
+// Measure sends a single request to the endpoint without retries or
+// metrics and returns its phase breakdown. The measurement is returned
+// alongside the error of a failed request, holding whatever was observed.
+func (h *HTTPTrace) Measure(ctx context.Context) (Measurement, error) {
+	t, err := h.trace(ctx)
+
+	return t.measurement(), err
+}
+
+// measurement splits the request into consecutive phases like curl's
+// timing variables, so the time between two phases, such as writing the
+// request, is attributed to the latter.
+func (t *tracePoint) measurement() Measurement {
+	m := Measurement{
+		StatusCode: t.statusCode,
+		RemoteAddr: t.remoteAddr(),
+		ConnReused: t.connReused,
+		TLSState:   t.tlsState,
+	}
+
+	if t.totalStartTime.IsZero() {
+		return m
+	}
+
+	// since returns the time from the start of the request until ts, or
+	// prev if ts was never reached
+	since := func(ts time.Time, prev time.Duration) time.Duration {
+		if ts.IsZero() {
+			return prev
+		}
+
+		return ts.Sub(t.totalStartTime)
+	}
+
+	dns := since(t.dnsDoneTime, 0)
+	connect := since(t.connDoneTime, dns)
+	tlsDone := since(t.tlsDoneTime, connect)
+	firstByte := since(t.firstByteTime, tlsDone)
+	total := since(t.totalDoneTime, firstByte)
+
+	m.DNS = dns
+	m.Connect = connect - dns
+	m.TLS = tlsDone - connect
+	m.ServerProcessing = firstByte - tlsDone
+	m.Transfer = total - firstByte
+	m.Total = total
+	m.FirstByte = firstByte
+
+	return m
+}
+
+// Measure dials the endpoint once without retries or metrics and returns
+// the DNS and connect latency of the dial.
+func (t *TCP) Measure(ctx context.Context) (Measurement, error) {
+	timing, err := t.dial(ctx)
+	if err != nil {
+		return Measurement{}, err
+	}
+
+	return Measurement{
+		RemoteAddr: timing.remoteAddr,
+		DNS:        seconds(timing.dnsDuration),
+		Connect:    seconds(timing.connDuration),
+		Total:      seconds(timing.totalDuration),
+	}, nil
+}
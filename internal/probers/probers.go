@@ -4,13 +4,18 @@ package probers
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"net/http"
+	"regexp"
 	"sync"
 	"time"
 
 	"github.com/dntosas/astrolavos/internal/metrics"
+	"github.com/dntosas/astrolavos/internal/model"
 
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 // Prober interface defines the contract each probe implementation must fulfill.
@@ -25,26 +30,49 @@ type ProberOptions struct {
 	PromClient          *metrics.PrometheusClient
 	Endpoint            string
 	Tag                 string
+	Labels              map[string]string
 	Retries             int
 	Interval            time.Duration
 	TCPTimeout          time.Duration
 	IsOneOff            bool
 	ReuseConnection     bool
 	SkipTLSVerification bool
+	Method              string
+	Headers             map[string]string
+	Body                []byte
+	Expect              *model.Expectations
+	// Timeout bounds an httpTrace request or a dns query.
+	Timeout         time.Duration
+	PhaseTimeouts   model.PhaseTimeouts
+	RecordType      string
+	Resolver        string
+	ExpectedAnswers []string
+	AnswerPattern   *regexp.Regexp
+	ServerName      string
+	RootCAs         *x509.CertPool
+	// OnResult is called with the outcome of every probe. Optional.
+	OnResult func(model.Result)
+	// Tracer traces httpTrace probes. Optional.
+	Tracer trace.Tracer
 }
 
 // ProberConfig holds the shared configuration and helpers for all prober implementations.
 type ProberConfig struct {
 	HTTPProberConfig
+	DNSProberConfig
+	TLSProberConfig
 
 	wg         *sync.WaitGroup
 	promC      *metrics.PrometheusClient
 	endpoint   string
 	retries    int
 	tag        string
+	labels     map[string]string
+	target     metrics.Target
 	interval   time.Duration
 	tcpTimeout time.Duration
 	isOneOff   bool
+	onResult   func(model.Result)
 }
 
 // HTTPProberConfig holds HTTP-specific configuration.
@@ -52,6 +80,27 @@ type HTTPProberConfig struct {
 	reuseConnection bool
 	skipTLS         bool
 	client          *http.Client
+	method          string
+	headers         map[string]string
+	body            []byte
+	expect          *model.Expectations
+	timeout         time.Duration
+	phaseTimeouts   model.PhaseTimeouts
+	tracer          trace.Tracer
+}
+
+// DNSProberConfig holds DNS-specific configuration.
+type DNSProberConfig struct {
+	recordType      string
+	nameserver      string
+	expectedAnswers []string
+	answerPattern   *regexp.Regexp
+}
+
+// TLSProberConfig holds TLS-specific configuration.
+type TLSProberConfig struct {
+	serverName string
+	rootCAs    *x509.CertPool
 }
 
 // NewProberConfig creates a new ProberConfig from the given options.
@@ -62,20 +111,70 @@ func NewProberConfig(opts ProberOptions) ProberConfig {
 		endpoint:   opts.Endpoint,
 		retries:    opts.Retries,
 		tag:        opts.Tag,
+		labels:     opts.Labels,
 		interval:   opts.Interval,
 		tcpTimeout: opts.TCPTimeout,
 		isOneOff:   opts.IsOneOff,
+		onResult:   opts.OnResult,
 	}
 
 	p.HTTPProberConfig = HTTPProberConfig{
 		reuseConnection: opts.ReuseConnection,
 		skipTLS:         opts.SkipTLSVerification,
-		client:          getCustomClient(opts.ReuseConnection, opts.SkipTLSVerification),
+		client:          getCustomClient(opts.ReuseConnection, opts.SkipTLSVerification, opts.Timeout),
+		method:          opts.Method,
+		headers:         opts.Headers,
+		body:            opts.Body,
+		expect:          opts.Expect,
+		timeout:         opts.Timeout,
+		phaseTimeouts:   opts.PhaseTimeouts,
+		tracer:          opts.Tracer,
+	}
+
+	if p.tracer == nil {
+		p.tracer = noop.NewTracerProvider().Tracer("")
+	}
+
+	p.DNSProberConfig = DNSProberConfig{
+		recordType:      opts.RecordType,
+		nameserver:      opts.Resolver,
+		expectedAnswers: opts.ExpectedAnswers,
+		answerPattern:   opts.AnswerPattern,
+	}
+
+	p.TLSProberConfig = TLSProberConfig{
+		serverName: opts.ServerName,
+		rootCAs:    opts.RootCAs,
 	}
 
 	return p
 }
 
+// newTarget returns the metrics target of the endpoint for the given prober type.
+func (p *ProberConfig) newTarget(proberType string) metrics.Target {
+	return metrics.Target{
+		Domain:     p.endpoint,
+		ProberType: proberType,
+		Tag:        p.tag,
+		Labels:     p.labels,
+	}
+}
+
+// report hands the outcome of a probe to the OnResult hook, if any.
+func (p *ProberConfig) report(r model.Result) {
+	if p.onResult == nil {
+		return
+	}
+
+	r.Time = time.Now()
+	p.onResult(r)
+}
+
+// seconds converts a duration in seconds, as exported in metrics, to a time.Duration.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
 // runLoop handles the common one-off vs interval execution pattern.
 // It calls probe on each tick (or once in one-off mode) and respects context cancellation.
 func (p *ProberConfig) runLoop(ctx context.Context, name string, probe func(ctx context.Context)) {
@@ -141,7 +240,7 @@ func (p *ProberConfig) retryWithBackoff(ctx context.Context, fn func() error) er
 	return lastErr
 }
 
-func getCustomClient(reuseCon, skipTLS bool) *http.Client {
+func getCustomClient(reuseCon, skipTLS bool, timeout time.Duration) *http.Client {
 	transport := http.DefaultTransport.(*http.Transport).Clone()
 	if skipTLS {
 		//nolint:gosec
@@ -153,5 +252,5 @@ func getCustomClient(reuseCon, skipTLS bool) *http.Client {
 		transport.MaxIdleConnsPerHost = -1
 	}
 
-	return &http.Client{Transport: transport}
+	return &http.Client{Transport: transport, Timeout: timeout}
 }
@@ -2,6 +2,8 @@ package probers_test
 
 import (
 	"context"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"sync"
@@ -9,11 +11,12 @@ import (
 	"time"
 
 	"github.com/dntosas/astrolavos/internal/metrics"
+	"github.com/dntosas/astrolavos/internal/model"
 	"github.com/dntosas/astrolavos/internal/probers"
 )
 
-// testPromC is a shared Prometheus client to avoid double-registration panics.
-var testPromC = metrics.NewPrometheusClient(true, "localhost")
+// testPromC is the Prometheus client shared by prober tests and read back by metricValue.
+var testPromC = metrics.NewPrometheusClient(metrics.Options{IsOneOff: true})
 
 // newTestWG returns a WaitGroup with 1 added, matching what the agent does.
 func newTestWG() *sync.WaitGroup {
@@ -96,6 +99,116 @@ func TestHTTPTrace_OneOff_Success(_ *testing.T) {
 	h.Run(context.Background())
 }
 
+func TestHTTPTrace_OneOff_MethodHeadersBody(t *testing.T) {
+	var gotMethod, gotAuth, gotHost, gotBody string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotMethod, gotAuth, gotHost, gotBody = r.Method, r.Header.Get("Authorization"), r.Host, string(body)
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	cfg := probers.NewProberConfig(probers.ProberOptions{
+		WG:         newTestWG(),
+		PromClient: testPromC,
+		Endpoint:   srv.URL,
+		Interval:   1 * time.Second,
+		Retries:    1,
+		IsOneOff:   true,
+		Method:     http.MethodPost,
+		Headers: map[string]string{
+			"authorization": "Bearer token",
+			"host":          "api.internal",
+		},
+		Body: []byte(`{"ping":true}`),
+	})
+
+	probers.NewHTTPTrace(cfg).Run(context.Background())
+
+	if gotMethod != http.MethodPost {
+		t.Errorf("expected method POST, got %q", gotMethod)
+	}
+
+	if gotAuth != "Bearer token" {
+		t.Errorf("expected Authorization header, got %q", gotAuth)
+	}
+
+	if gotHost != "api.internal" {
+		t.Errorf("expected Host override 'api.internal', got %q", gotHost)
+	}
+
+	if gotBody != `{"ping":true}` {
+		t.Errorf("expected request body, got %q", gotBody)
+	}
+}
+
+func TestTCP_OneOff_RecordsLatency(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		for {
+			conn, acceptErr := ln.Accept()
+			if acceptErr != nil {
+				return
+			}
+
+			_ = conn.Close()
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	tests := []struct {
+		endpoint string
+		resolved bool
+	}{
+		{ln.Addr().String(), false},
+		{net.JoinHostPort("localhost", port), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			cfg := probers.NewProberConfig(probers.ProberOptions{
+				WG:         newTestWG(),
+				PromClient: testPromC,
+				Endpoint:   tt.endpoint,
+				Tag:        "tcp-latency",
+				Interval:   1 * time.Second,
+				TCPTimeout: 2 * time.Second,
+				Retries:    1,
+				IsOneOff:   true,
+			})
+
+			probers.NewTCP(cfg).Run(context.Background())
+
+			labels := map[string]string{"domain": tt.endpoint, "tag": "tcp-latency", "prober_type": "tcp"}
+
+			if v := metricValue(t, "astrolavos_conn_latency_seconds", labels); v != 1 {
+				t.Errorf("expected 1 connection latency observation, got %v", v)
+			}
+
+			if v := metricValue(t, "astrolavos_total_latency_seconds", labels); v != 1 {
+				t.Errorf("expected 1 total latency observation, got %v", v)
+			}
+
+			expectedDNS := 0.0
+			if tt.resolved {
+				expectedDNS = 1
+			}
+
+			if v := metricValue(t, "astrolavos_dns_latency_seconds", labels); v != expectedDNS {
+				t.Errorf("expected %v DNS latency observations, got %v", expectedDNS, v)
+			}
+		})
+	}
+}
+
 func TestTCP_OneOff_FailsGracefully(_ *testing.T) {
 	cfg := probers.NewProberConfig(probers.ProberOptions{
 		WG:         newTestWG(),
@@ -113,6 +226,65 @@ func TestTCP_OneOff_FailsGracefully(_ *testing.T) {
 	tcp.Run(context.Background())
 }
 
+func TestHTTPTrace_ReportsResult(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	var got []model.Result
+
+	cfg := probers.NewProberConfig(probers.ProberOptions{
+		WG:         newTestWG(),
+		PromClient: testPromC,
+		Endpoint:   srv.URL,
+		Interval:   1 * time.Second,
+		Retries:    1,
+		IsOneOff:   true,
+		OnResult:   func(r model.Result) { got = append(got, r) },
+	})
+
+	probers.NewHTTPTrace(cfg).Run(context.Background())
+
+	if len(got) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(got))
+	}
+
+	r := got[0]
+	if !r.Success() || r.StatusCode != "202" {
+		t.Errorf("expected successful result with status 202, got %+v", r)
+	}
+
+	if r.Total <= 0 || r.Total < r.FirstByte || r.Time.IsZero() {
+		t.Errorf("unexpected result timing: %+v", r)
+	}
+
+	if r.RemoteAddr != srv.Listener.Addr().String() {
+		t.Errorf("expected remote address %s, got %q", srv.Listener.Addr(), r.RemoteAddr)
+	}
+}
+
+func TestTCP_ReportsFailure(t *testing.T) {
+	var got []model.Result
+
+	cfg := probers.NewProberConfig(probers.ProberOptions{
+		WG:         newTestWG(),
+		PromClient: testPromC,
+		Endpoint:   "localhost:1",
+		Interval:   1 * time.Second,
+		TCPTimeout: 100 * time.Millisecond,
+		Retries:    1,
+		IsOneOff:   true,
+		OnResult:   func(r model.Result) { got = append(got, r) },
+	})
+
+	probers.NewTCP(cfg).Run(context.Background())
+
+	if len(got) != 1 || got[0].Success() || got[0].Total != 0 {
+		t.Errorf("expected a single failed result, got %+v", got)
+	}
+}
+
 func TestHTTPTrace_OneOff_RetriesOnError(t *testing.T) {
 	calls := 0
 
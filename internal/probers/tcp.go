@@ -4,6 +4,10 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/metrics"
+	"github.com/dntosas/astrolavos/internal/model"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -15,6 +19,8 @@ type TCP struct {
 
 // NewTCP creates a new TCP prober with the given configuration.
 func NewTCP(c ProberConfig) *TCP {
+	c.target = c.newTarget("tcp")
+
 	return &TCP{c}
 }
 
@@ -28,27 +34,116 @@ func (t *TCP) Run(ctx context.Context) {
 	t.runLoop(ctx, t.String(), t.probe)
 }
 
+// tcpTiming holds the phase durations of a single dial in seconds.
+type tcpTiming struct {
+	resolved      bool
+	dnsDuration   float64
+	connDuration  float64
+	totalDuration float64
+	tcpInfo       *metrics.TCPInfo
+	remoteAddr    string
+}
+
 // probe performs a single TCP dial with retry logic and records metrics.
 func (t *TCP) probe(ctx context.Context) {
+	var timing *tcpTiming
+
 	err := t.retryWithBackoff(ctx, func() error {
-		return t.dial(ctx)
+		var dialErr error
+		timing, dialErr = t.dial(ctx)
+
+		return dialErr
 	})
 
-	t.promC.UpdateRequestsCounter(t.endpoint, "tcp", t.tag, "")
+	t.promC.UpdateRequestsCounter(t.target, "")
 
 	if err != nil {
 		log.Errorf("TCP prober %s failed after %d attempts: %v", t, t.retries, err)
-		t.promC.UpdateErrorsCounter(t.endpoint, "tcp", t.tag, err)
+		t.promC.UpdateErrorsCounter(t.target, err)
+		t.report(model.Result{Err: err})
+
+		return
 	}
+
+	if timing.resolved {
+		t.promC.UpdateDNSHistogram(t.target, timing.dnsDuration)
+	}
+
+	t.promC.UpdateConnHistogram(t.target, timing.connDuration)
+	t.promC.UpdateTotalHistogram(t.target, timing.totalDuration)
+
+	if timing.tcpInfo != nil {
+		t.promC.UpdateTCPInfoMetrics(t.target, timing.tcpInfo, true)
+	}
+
+	t.report(model.Result{
+		RemoteAddr: timing.remoteAddr,
+		DNS:        seconds(timing.dnsDuration),
+		Connect:    seconds(timing.connDuration),
+		Total:      seconds(timing.totalDuration),
+	})
 }
 
-func (t *TCP) dial(ctx context.Context) error {
-	dialer := net.Dialer{Timeout: t.tcpTimeout}
+// dial opens and closes a TCP connection to the endpoint. When the endpoint
+// is a hostname it is resolved first, so DNS and connect latency are timed
+// separately. Resolved addresses are tried in order until one connects, and
+// the connect latency is that of the attempt that succeeded.
+func (t *TCP) dial(ctx context.Context) (*tcpTiming, error) {
+	if t.tcpTimeout > 0 {
+		var cancel context.CancelFunc
 
-	conn, err := dialer.DialContext(ctx, "tcp", t.endpoint)
+		ctx, cancel = context.WithTimeout(ctx, t.tcpTimeout)
+		defer cancel()
+	}
+
+	host, port, err := net.SplitHostPort(t.endpoint)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("invalid TCP endpoint %q: %w", t.endpoint, err)
+	}
+
+	timing := &tcpTiming{}
+	addrs := []string{t.endpoint}
+	start := time.Now()
+
+	if net.ParseIP(host) == nil {
+		ips, lookupErr := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if lookupErr != nil {
+			return nil, fmt.Errorf("DNS resolution failed: %w", lookupErr)
+		}
+
+		if len(ips) == 0 {
+			return nil, fmt.Errorf("DNS resolution failed: no addresses for host %s", host)
+		}
+
+		timing.resolved = true
+		timing.dnsDuration = time.Since(start).Seconds()
+
+		addrs = addrs[:0]
+		for _, ip := range ips {
+			addrs = append(addrs, net.JoinHostPort(ip.IP.String(), port))
+		}
+	}
+
+	var dialer net.Dialer
+
+	for _, addr := range addrs {
+		var conn net.Conn
+
+		connStart := time.Now()
+
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			continue
+		}
+
+		done := time.Now()
+		timing.connDuration = done.Sub(connStart).Seconds()
+		timing.totalDuration = done.Sub(start).Seconds()
+		timing.tcpInfo = connTCPInfo(conn)
+		timing.remoteAddr = conn.RemoteAddr().String()
+
+		return timing, conn.Close()
 	}
 
-	return conn.Close()
+	return nil, err
 }
@@ -0,0 +1,41 @@
+package probers
+
+import (
+	"errors"
+	"net"
+	"syscall"
+
+	"github.com/dntosas/astrolavos/internal/metrics"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// errTCPInfoUnsupported is returned when kernel TCP statistics cannot be read
+// on this platform or connection type.
+var errTCPInfoUnsupported = errors.New("TCP_INFO is not supported")
+
+// connTCPInfo reads the kernel TCP statistics of the socket underlying conn,
+// unwrapping TLS connections. It returns nil when they are not available.
+func connTCPInfo(conn net.Conn) *metrics.TCPInfo {
+	if conn == nil {
+		return nil
+	}
+
+	if tlsConn, ok := conn.(interface{ NetConn() net.Conn }); ok {
+		conn = tlsConn.NetConn()
+	}
+
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return nil
+	}
+
+	info, err := readTCPInfo(sc)
+	if err != nil {
+		log.Debugf("Unable to read TCP_INFO for %s: %v", conn.RemoteAddr(), err)
+
+		return nil
+	}
+
+	return info
+}
@@ -0,0 +1,42 @@
+package probers
+
+import (
+	"syscall"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/metrics"
+
+	"golang.org/x/sys/unix"
+)
+
+// readTCPInfo reads TCP_INFO from the socket with getsockopt.
+func readTCPInfo(sc syscall.Conn) (*metrics.TCPInfo, error) {
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		info    *unix.TCPInfo
+		sockErr error
+	)
+
+	err = raw.Control(func(fd uintptr) {
+		info, sockErr = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO) //nolint:gosec // fd is a valid socket descriptor
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if sockErr != nil {
+		return nil, sockErr
+	}
+
+	return &metrics.TCPInfo{
+		RTT:              time.Duration(info.Rtt) * time.Microsecond,
+		RTTVar:           time.Duration(info.Rttvar) * time.Microsecond,
+		Retransmits:      info.Total_retrans,
+		CongestionWindow: info.Snd_cwnd,
+		MSS:              info.Snd_mss,
+	}, nil
+}
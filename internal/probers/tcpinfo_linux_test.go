@@ -0,0 +1,79 @@
+package probers_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/probers"
+)
+
+func TestTCPInfoMetrics(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		for {
+			conn, acceptErr := ln.Accept()
+			if acceptErr != nil {
+				return
+			}
+
+			_ = conn.Close()
+		}
+	}()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		endpoint   string
+		proberType string
+		run        func(cfg probers.ProberConfig)
+	}{
+		{"tcp", ln.Addr().String(), "tcp", func(cfg probers.ProberConfig) {
+			probers.NewTCP(cfg).Run(context.Background())
+		}},
+		{"httpTrace", srv.URL, "httptrace", func(cfg probers.ProberConfig) {
+			probers.NewHTTPTrace(cfg).Run(context.Background())
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(probers.NewProberConfig(probers.ProberOptions{
+				WG:         newTestWG(),
+				PromClient: testPromC,
+				Endpoint:   tt.endpoint,
+				Tag:        "tcp-info",
+				Interval:   1 * time.Second,
+				TCPTimeout: 2 * time.Second,
+				Retries:    1,
+				IsOneOff:   true,
+			}))
+
+			labels := map[string]string{"domain": tt.endpoint, "tag": "tcp-info", "prober_type": tt.proberType}
+
+			if v := metricValue(t, "astrolavos_tcp_rtt_seconds", labels); v != 1 {
+				t.Errorf("expected 1 RTT observation, got %v", v)
+			}
+
+			if v := metricValue(t, "astrolavos_tcp_mss_bytes", labels); v <= 0 {
+				t.Errorf("expected a positive MSS, got %v", v)
+			}
+
+			if v := metricValue(t, "astrolavos_tcp_congestion_window", labels); v <= 0 {
+				t.Errorf("expected a positive congestion window, got %v", v)
+			}
+		})
+	}
+}
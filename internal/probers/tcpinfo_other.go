@@ -0,0 +1,14 @@
+//go:build !linux
+
+package probers
+
+import (
+	"syscall"
+
+	"github.com/dntosas/astrolavos/internal/metrics"
+)
+
+// readTCPInfo is only implemented on Linux.
+func readTCPInfo(_ syscall.Conn) (*metrics.TCPInfo, error) {
+	return nil, errTCPInfoUnsupported
+}
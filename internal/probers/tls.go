@@ -0,0 +1,168 @@
+package probers
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/model"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// TLS implements the Prober interface for TLS handshake probes. It completes
+// a handshake without sending any application data and reports the
+// presented certificate chain, negotiated parameters and handshake latency.
+type TLS struct {
+	ProberConfig
+}
+
+// NewTLS creates a new TLS prober with the given configuration.
+func NewTLS(c ProberConfig) *TLS {
+	c.target = c.newTarget("tls")
+
+	return &TLS{c}
+}
+
+// String returns a human-readable description of the TLS prober configuration.
+func (t *TLS) String() string {
+	return fmt.Sprintf("TLS Prober Endpoint: %s - Interval: %v - Tag: %s - Retries: %d", t.endpoint, t.interval, t.tag, t.retries)
+}
+
+// Run starts the TLS prober, executing probes according to the configured mode.
+func (t *TLS) Run(ctx context.Context) {
+	t.runLoop(ctx, t.String(), t.probe)
+}
+
+// tlsTiming holds the phase durations of a single handshake in seconds.
+type tlsTiming struct {
+	connDuration  float64
+	tlsDuration   float64
+	totalDuration float64
+	remoteAddr    string
+}
+
+// probe performs a single TLS handshake with retry logic and records metrics.
+func (t *TLS) probe(ctx context.Context) {
+	var timing *tlsTiming
+
+	err := t.retryWithBackoff(ctx, func() error {
+		var handshakeErr error
+		timing, handshakeErr = t.handshake(ctx)
+
+		return handshakeErr
+	})
+
+	t.promC.UpdateRequestsCounter(t.target, "")
+
+	if err != nil {
+		log.Errorf("TLS prober %s failed after %d attempts: %v", t, t.retries, err)
+		t.promC.UpdateErrorsCounter(t.target, err)
+		t.report(model.Result{Err: err})
+
+		return
+	}
+
+	t.promC.UpdateConnHistogram(t.target, timing.connDuration)
+	t.promC.UpdateTLSHistogram(t.target, timing.tlsDuration)
+	t.promC.UpdateTotalHistogram(t.target, timing.totalDuration)
+
+	t.report(model.Result{
+		RemoteAddr: timing.remoteAddr,
+		Connect:    seconds(timing.connDuration),
+		TLS:        seconds(timing.tlsDuration),
+		Total:      seconds(timing.totalDuration),
+	})
+}
+
+// handshake dials the endpoint, completes a TLS handshake and verifies the
+// presented chain. Certificate metrics are recorded for every completed
+// handshake, so an expired or untrusted chain is still reported.
+func (t *TLS) handshake(ctx context.Context) (*tlsTiming, error) {
+	if t.tcpTimeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, t.tcpTimeout)
+		defer cancel()
+	}
+
+	serverName := t.serverName
+	if serverName == "" {
+		host, _, err := net.SplitHostPort(t.endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLS endpoint %q: %w", t.endpoint, err)
+		}
+
+		serverName = host
+	}
+
+	start := time.Now()
+
+	var dialer net.Dialer
+
+	conn, err := dialer.DialContext(ctx, "tcp", t.endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("TCP connection failed: %w", err)
+	}
+
+	connDone := time.Now()
+
+	// Verification is done explicitly below so the chain can be inspected
+	// and reported even when it does not verify.
+	//nolint:gosec // chain is verified manually in verifyChain
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: serverName, InsecureSkipVerify: true})
+	defer func() {
+		_ = tlsConn.Close()
+	}()
+
+	if err = tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, fmt.Errorf("TLS handshake failed: %w", err)
+	}
+
+	tlsDone := time.Now()
+	state := tlsConn.ConnectionState()
+
+	t.promC.UpdateTLSMetrics(t.target, state)
+
+	verifyErr := verifyChain(state, t.rootCAs, serverName)
+	t.promC.UpdateTLSChainVerified(t.target, verifyErr == nil)
+
+	if verifyErr != nil && !t.skipTLS {
+		return nil, fmt.Errorf("TLS certificate verification failed: %w", verifyErr)
+	}
+
+	log.Debugf("TLS %s negotiated %s with %s", t.endpoint, tls.VersionName(state.Version), tls.CipherSuiteName(state.CipherSuite))
+
+	return &tlsTiming{
+		connDuration:  connDone.Sub(start).Seconds(),
+		tlsDuration:   tlsDone.Sub(connDone).Seconds(),
+		totalDuration: tlsDone.Sub(start).Seconds(),
+		remoteAddr:    conn.RemoteAddr().String(),
+	}, nil
+}
+
+// verifyChain verifies the presented chain against roots, or the system
+// roots when nil, using the intermediates sent by the server.
+func verifyChain(state tls.ConnectionState, roots *x509.CertPool, serverName string) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("server presented no certificates")
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		DNSName:       serverName,
+		Intermediates: x509.NewCertPool(),
+	}
+
+	for _, cert := range state.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+
+	_, err := state.PeerCertificates[0].Verify(opts)
+
+	return err
+}
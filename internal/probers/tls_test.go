@@ -0,0 +1,102 @@
+package probers_test
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/probers"
+)
+
+func runTLSProbe(t *testing.T, opts probers.ProberOptions) {
+	t.Helper()
+
+	opts.WG = newTestWG()
+	opts.PromClient = testPromC
+	opts.Interval = 1 * time.Second
+	opts.TCPTimeout = 2 * time.Second
+	opts.Retries = 1
+	opts.IsOneOff = true
+
+	probers.NewTLS(probers.NewProberConfig(opts)).Run(context.Background())
+}
+
+func TestTLS_OneOff_VerifiedWithCABundle(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+
+	endpoint := srv.Listener.Addr().String()
+
+	runTLSProbe(t, probers.ProberOptions{
+		Endpoint: endpoint,
+		Tag:      "tls-verified",
+		RootCAs:  roots,
+	})
+
+	labels := map[string]string{"domain": endpoint, "tag": "tls-verified", "prober_type": "tls"}
+
+	if v := metricValue(t, "astrolavos_tls_chain_verified", labels); v != 1 {
+		t.Errorf("expected chain to verify, got %v", v)
+	}
+
+	if v := metricValue(t, "astrolavos_errors_total", labels); v != 0 {
+		t.Errorf("expected no errors, got %v", v)
+	}
+
+	labels["depth"] = "0"
+	if v := metricValue(t, "astrolavos_tls_cert_expiry_days", labels); v <= 0 {
+		t.Errorf("expected positive leaf expiry days, got %v", v)
+	}
+}
+
+func TestTLS_OneOff_UnknownAuthority(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	endpoint := srv.Listener.Addr().String()
+
+	runTLSProbe(t, probers.ProberOptions{
+		Endpoint: endpoint,
+		Tag:      "tls-untrusted",
+	})
+
+	labels := map[string]string{"domain": endpoint, "tag": "tls-untrusted", "prober_type": "tls"}
+
+	v, ok := lookupMetric(t, testPromC, "astrolavos_tls_chain_verified", labels)
+	if !ok {
+		t.Fatal("expected the chain verification to be recorded")
+	}
+
+	if v != 0 {
+		t.Errorf("expected chain not to verify, got %v", v)
+	}
+
+	labels["error"] = "tls_unknown_authority"
+	if v := metricValue(t, "astrolavos_errors_total", labels); v != 1 {
+		t.Errorf("expected 1 tls_unknown_authority error, got %v", v)
+	}
+}
+
+func TestTLSString(t *testing.T) {
+	cfg := probers.NewProberConfig(probers.ProberOptions{
+		Endpoint: "example.com:443",
+		Interval: 30 * time.Second,
+		Tag:      "certs",
+		Retries:  1,
+	})
+
+	s := probers.NewTLS(cfg).String()
+	if s != "TLS Prober Endpoint: example.com:443 - Interval: 30s - Tag: certs - Retries: 1" {
+		t.Errorf("unexpected String() output: %s", s)
+	}
+}
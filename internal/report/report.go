@@ -0,0 +1,204 @@
+// Package report summarizes the final results of a one-off run, as a table
+// for people or as JSON for machines.
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dntosas/astrolavos/internal/metrics"
+	"github.com/dntosas/astrolavos/internal/model"
+)
+
+// Output formats of a report.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Formats lists the supported output formats.
+var Formats = []string{FormatText, FormatJSON}
+
+// Report holds the final result of every endpoint of a run.
+type Report struct {
+	Endpoints []Endpoint `json:"endpoints"`
+	Total     int        `json:"total"`
+	Failed    int        `json:"failed"`
+}
+
+// Endpoint is the final result of a single endpoint. Phase latencies are in
+// seconds and zero for phases the prober does not measure. An endpoint
+// without a result, e.g. one whose prober could not be created, failed.
+type Endpoint struct {
+	URI              string            `json:"uri"`
+	ProberType       string            `json:"prober_type"`
+	Tag              string            `json:"tag,omitempty"`
+	Labels           map[string]string `json:"labels,omitempty"`
+	Success          bool              `json:"success"`
+	Error            string            `json:"error,omitempty"`
+	ErrorCategory    string            `json:"error_category,omitempty"`
+	StatusCode       string            `json:"status_code,omitempty"`
+	RemoteAddr       string            `json:"remote_addr,omitempty"`
+	DNSSeconds       float64           `json:"dns_seconds"`
+	ConnectSeconds   float64           `json:"connect_seconds"`
+	TLSSeconds       float64           `json:"tls_seconds"`
+	GotConnSeconds   float64           `json:"got_conn_seconds"`
+	FirstByteSeconds float64           `json:"first_byte_seconds"`
+	TotalSeconds     float64           `json:"total_seconds"`
+}
+
+// errNoResult is reported for endpoints that produced no result.
+var errNoResult = errors.New("no result")
+
+// New builds the report of endpoints from their latest result, as returned
+// by latest.
+func New(endpoints []*model.Endpoint, latest func(model.Key) (model.Result, bool)) Report {
+	r := Report{Endpoints: make([]Endpoint, 0, len(endpoints))}
+
+	for _, e := range endpoints {
+		res, ok := latest(e.Key())
+		if !ok {
+			res = model.Result{Err: errNoResult}
+		}
+
+		entry := Endpoint{
+			URI:              e.URI,
+			ProberType:       e.ProberType,
+			Tag:              e.Tag,
+			Labels:           e.Labels,
+			Success:          res.Success(),
+			StatusCode:       res.StatusCode,
+			RemoteAddr:       res.RemoteAddr,
+			DNSSeconds:       res.DNS.Seconds(),
+			ConnectSeconds:   res.Connect.Seconds(),
+			TLSSeconds:       res.TLS.Seconds(),
+			GotConnSeconds:   res.GotConn.Seconds(),
+			FirstByteSeconds: res.FirstByte.Seconds(),
+			TotalSeconds:     res.Total.Seconds(),
+		}
+
+		if res.Err != nil {
+			entry.Error = res.Err.Error()
+			entry.ErrorCategory = metrics.CategorizeError(res.Err)
+			r.Failed++
+		}
+
+		r.Endpoints = append(r.Endpoints, entry)
+	}
+
+	r.Total = len(r.Endpoints)
+
+	return r
+}
+
+// Write writes the report in the given format.
+func (r Report) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		if err := enc.Encode(r); err != nil {
+			return fmt.Errorf("encoding report failed: %w", err)
+		}
+
+		return nil
+	case FormatText:
+		return r.writeText(w)
+	default:
+		return fmt.Errorf("unknown report format: %s", format)
+	}
+}
+
+// writeText writes a table of the endpoints followed by their errors.
+func (r Report) writeText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "ENDPOINT\tTYPE\tTAG\tRESULT\tDNS\tCONNECT\tTLS\tFIRST BYTE\tTOTAL")
+
+	for _, e := range r.Endpoints {
+		result := "OK"
+		if !e.Success {
+			result = "FAIL " + e.ErrorCategory
+		} else if e.StatusCode != "" {
+			result += " " + e.StatusCode
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.URI, e.ProberType, e.Tag, result,
+			FormatSeconds(e.DNSSeconds), FormatSeconds(e.ConnectSeconds), FormatSeconds(e.TLSSeconds),
+			FormatSeconds(e.FirstByteSeconds), FormatSeconds(e.TotalSeconds))
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("writing report failed: %w", err)
+	}
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "\n%d/%d probes succeeded\n", r.Total-r.Failed, r.Total)
+
+	for _, e := range r.Endpoints {
+		if !e.Success {
+			fmt.Fprintf(&b, "  %s (%s): %s\n", e.URI, e.ProberType, e.Error)
+		}
+	}
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("writing report failed: %w", err)
+	}
+
+	return nil
+}
+
+// FormatSeconds formats a phase latency in milliseconds, or "-" for a
+// phase that was not measured.
+func FormatSeconds(s float64) string {
+	if s == 0 {
+		return "-"
+	}
+
+	return strconv.FormatFloat(s*1000, 'f', 1, 64) + "ms"
+}
+
+// Threshold is the number of failed probes a run tolerates, as a count or
+// as a percentage of its probes.
+type Threshold struct {
+	Count   int
+	Percent float64
+	// IsPercent selects Percent over Count.
+	IsPercent bool
+}
+
+// ParseThreshold parses a count such as "2" or a percentage such as "10%".
+func ParseThreshold(s string) (Threshold, error) {
+	if p, ok := strings.CutSuffix(s, "%"); ok {
+		percent, err := strconv.ParseFloat(p, 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return Threshold{}, fmt.Errorf("invalid percentage %q: must be between 0%% and 100%%", s)
+		}
+
+		return Threshold{Percent: percent, IsPercent: true}, nil
+	}
+
+	count, err := strconv.Atoi(s)
+	if err != nil || count < 0 {
+		return Threshold{}, fmt.Errorf("invalid count %q: must be a non-negative number or a percentage", s)
+	}
+
+	return Threshold{Count: count}, nil
+}
+
+// Exceeded reports whether more probes failed than the threshold tolerates.
+func (t Threshold) Exceeded(failed, total int) bool {
+	if t.IsPercent {
+		return total > 0 && float64(failed)*100/float64(total) > t.Percent
+	}
+
+	return failed > t.Count
+}
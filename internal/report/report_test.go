@@ -0,0 +1,92 @@
+package report_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/model"
+	"github.com/dntosas/astrolavos/internal/report"
+)
+
+func TestReport_Text(t *testing.T) {
+	ok := &model.Endpoint{URI: "https://example.com", ProberType: "httpTrace", Tag: "web"}
+	failed := &model.Endpoint{URI: "example.com:5432", ProberType: "tcp"}
+	missing := &model.Endpoint{URI: "example.com", ProberType: "dns"}
+
+	results := map[model.Key]model.Result{
+		ok.Key():     {StatusCode: "200", DNS: 2 * time.Millisecond, Connect: 10 * time.Millisecond, Total: 52500 * time.Microsecond},
+		failed.Key(): {Err: errors.New("dial tcp: connection refused")},
+	}
+
+	r := report.New([]*model.Endpoint{ok, failed, missing}, func(k model.Key) (model.Result, bool) {
+		res, found := results[k]
+
+		return res, found
+	})
+
+	if r.Total != 3 || r.Failed != 2 {
+		t.Fatalf("expected 2 of 3 probes to fail, got %+v", r)
+	}
+
+	var out bytes.Buffer
+	if err := r.Write(&out, report.FormatText); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []string{
+		"ENDPOINT",
+		"https://example.com  httpTrace  web  OK 200",
+		"2.0ms",
+		"52.5ms",
+		"FAIL connection_refused",
+		"1/3 probes succeeded",
+		"example.com:5432 (tcp): dial tcp: connection refused",
+		"example.com (dns): no result",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in report:\n%s", want, out.String())
+		}
+	}
+
+	if err := r.Write(&out, "yaml"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestThreshold(t *testing.T) {
+	tests := []struct {
+		value       string
+		failed      int
+		total       int
+		wantExceeds bool
+	}{
+		{"0", 0, 4, false},
+		{"0", 1, 4, true},
+		{"2", 2, 4, false},
+		{"2", 3, 4, true},
+		{"25%", 1, 4, false},
+		{"25%", 2, 4, true},
+		{"0%", 0, 0, false},
+		{"100%", 4, 4, false},
+	}
+
+	for _, tt := range tests {
+		th, err := report.ParseThreshold(tt.value)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %v", tt.value, err)
+		}
+
+		if got := th.Exceeded(tt.failed, tt.total); got != tt.wantExceeds {
+			t.Errorf("%s with %d of %d failed: expected exceeded %v, got %v", tt.value, tt.failed, tt.total, tt.wantExceeds, got)
+		}
+	}
+
+	for _, invalid := range []string{"", "-1", "many", "101%", "-5%"} {
+		if _, err := report.ParseThreshold(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
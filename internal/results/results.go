@@ -0,0 +1,212 @@
+// Package results keeps the recent probe results of every endpoint in
+// memory, so latency can be summarized without a Prometheus server.
+package results
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/model"
+)
+
+const (
+	// DefaultWindow is the rolling window summaries are computed over.
+	DefaultWindow = 5 * time.Minute
+	// DefaultHistory is the number of recent results kept per endpoint.
+	DefaultHistory = 100
+)
+
+// Summary describes the results of an endpoint within the rolling window.
+type Summary struct {
+	// Latest is the most recent result, which may be older than the window.
+	Latest model.Result
+	// LastSuccess is the time of the most recent successful probe, if any.
+	LastSuccess time.Time
+	// Percentiles of the total latency of successful probes.
+	P50 time.Duration
+	P95 time.Duration
+	P99 time.Duration
+	// ErrorRate is the share of failed probes, between 0 and 1.
+	ErrorRate float64
+	// Samples is the number of probes within the window.
+	Samples int
+}
+
+// Store holds the results of every endpoint within a rolling window, as
+// well as a bounded history of the most recent ones regardless of age, and
+// streams them to subscribers as they are recorded.
+// It is safe for concurrent use.
+type Store struct {
+	mu      sync.Mutex
+	window  time.Duration
+	history int
+	series  map[model.Key]*series
+	events  broadcaster
+}
+
+// series holds the results of a single endpoint.
+type series struct {
+	latest      model.Result
+	lastSuccess time.Time
+	// results within the window, oldest first
+	results []model.Result
+	// recent is a ring buffer of the last results, next is its write position
+	recent []model.Result
+	next   int
+}
+
+// NewStore creates a Store summarizing results over window and keeping the
+// last history results of every endpoint. Non-positive values use
+// DefaultWindow and DefaultHistory.
+func NewStore(window time.Duration, history int) *Store {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+
+	if history <= 0 {
+		history = DefaultHistory
+	}
+
+	return &Store{
+		window:  window,
+		history: history,
+		series:  map[model.Key]*series{},
+	}
+}
+
+// Window returns the rolling window summaries are computed over.
+func (s *Store) Window() time.Duration {
+	return s.window
+}
+
+// History returns the number of recent results kept per endpoint.
+func (s *Store) History() int {
+	return s.history
+}
+
+// Record adds the result of a probe of the endpoint identified by key,
+// drops its results that fell out of the window and publishes it to the
+// subscribers.
+func (s *Store) Record(key model.Key, r model.Result) {
+	defer s.publish(Event{Key: key, Result: r})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ser, ok := s.series[key]
+	if !ok {
+		ser = &series{}
+		s.series[key] = ser
+	}
+
+	ser.latest = r
+	if r.Success() {
+		ser.lastSuccess = r.Time
+	}
+
+	ser.results = append(ser.results, r)
+	ser.prune(r.Time.Add(-s.window))
+
+	if len(ser.recent) < s.history {
+		ser.recent = append(ser.recent, r)
+	} else {
+		ser.recent[ser.next] = r
+	}
+
+	ser.next = (ser.next + 1) % s.history
+}
+
+// Recent returns up to limit of the most recent results of an endpoint,
+// newest first, or false if it has no results. A non-positive limit
+// returns all kept results.
+func (s *Store) Recent(key model.Key, limit int) ([]model.Result, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ser, ok := s.series[key]
+	if !ok {
+		return nil, false
+	}
+
+	n := len(ser.recent)
+	if limit > 0 {
+		n = min(n, limit)
+	}
+
+	recent := make([]model.Result, 0, n)
+
+	for i := 1; i <= n; i++ {
+		idx := (ser.next - i + len(ser.recent)) % len(ser.recent)
+		recent = append(recent, ser.recent[idx])
+	}
+
+	return recent, true
+}
+
+// Remove forgets the results of an endpoint that is no longer probed.
+func (s *Store) Remove(key model.Key) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.series, key)
+}
+
+// Summary returns the summary of an endpoint, or false if it has no results.
+func (s *Store) Summary(key model.Key) (Summary, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ser, ok := s.series[key]
+	if !ok {
+		return Summary{}, false
+	}
+
+	ser.prune(time.Now().Add(-s.window))
+
+	sum := Summary{Latest: ser.latest, LastSuccess: ser.lastSuccess, Samples: len(ser.results)}
+	if sum.Samples == 0 {
+		return sum, true
+	}
+
+	latencies := make([]time.Duration, 0, len(ser.results))
+
+	for _, r := range ser.results {
+		if r.Success() {
+			latencies = append(latencies, r.Total)
+		}
+	}
+
+	slices.Sort(latencies)
+
+	sum.P50 = percentile(latencies, 50)
+	sum.P95 = percentile(latencies, 95)
+	sum.P99 = percentile(latencies, 99)
+	sum.ErrorRate = float64(sum.Samples-len(latencies)) / float64(sum.Samples)
+
+	return sum, true
+}
+
+// prune drops the results older than cutoff.
+func (ser *series) prune(cutoff time.Time) {
+	i := 0
+	for i < len(ser.results) && ser.results[i].Time.Before(cutoff) {
+		i++
+	}
+
+	if i > 0 {
+		ser.results = slices.Delete(ser.results, 0, i)
+	}
+}
+
+// percentile returns the nearest-rank percentile p of sorted, or zero when
+// it is empty.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := (p*len(sorted) + 99) / 100
+
+	return sorted[max(rank, 1)-1]
+}
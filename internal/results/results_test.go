@@ -0,0 +1,113 @@
+package results_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/model"
+	"github.com/dntosas/astrolavos/internal/results"
+)
+
+var testKey = model.Key{ProberType: "httpTrace", URI: "http://example.com", Tag: "prod"}
+
+func TestStore_Summary(t *testing.T) {
+	store := results.NewStore(time.Minute, 0)
+	now := time.Now()
+
+	// Out of the window, must be ignored
+	store.Record(testKey, model.Result{Time: now.Add(-2 * time.Minute), Total: time.Hour})
+
+	for i := 1; i <= 100; i++ {
+		store.Record(testKey, model.Result{Time: now, Total: time.Duration(i) * time.Millisecond})
+	}
+
+	store.Record(testKey, model.Result{Time: now, Err: errors.New("timeout")})
+
+	sum, ok := store.Summary(testKey)
+	if !ok {
+		t.Fatal("expected a summary")
+	}
+
+	if sum.Samples != 101 {
+		t.Errorf("expected 101 samples in the window, got %d", sum.Samples)
+	}
+
+	if sum.P50 != 50*time.Millisecond || sum.P95 != 95*time.Millisecond || sum.P99 != 99*time.Millisecond {
+		t.Errorf("unexpected percentiles: p50=%v p95=%v p99=%v", sum.P50, sum.P95, sum.P99)
+	}
+
+	if sum.ErrorRate != 1.0/101 {
+		t.Errorf("unexpected error rate: %v", sum.ErrorRate)
+	}
+
+	if sum.Latest.Success() {
+		t.Error("expected the latest result to be the failure")
+	}
+
+	if !sum.LastSuccess.Equal(now) {
+		t.Errorf("expected last success at %v, got %v", now, sum.LastSuccess)
+	}
+}
+
+func TestStore_SummaryExpired(t *testing.T) {
+	store := results.NewStore(time.Minute, 0)
+	store.Record(testKey, model.Result{Time: time.Now().Add(-time.Hour), Total: time.Second})
+
+	sum, ok := store.Summary(testKey)
+	if !ok {
+		t.Fatal("expected a summary keeping the latest result")
+	}
+
+	if sum.Samples != 0 || sum.P95 != 0 || sum.ErrorRate != 0 {
+		t.Errorf("expected no samples in the window, got %+v", sum)
+	}
+
+	if sum.Latest.Total != time.Second {
+		t.Errorf("unexpected latest result: %+v", sum.Latest)
+	}
+}
+
+func TestStore_Remove(t *testing.T) {
+	store := results.NewStore(0, 0)
+
+	if store.Window() != results.DefaultWindow {
+		t.Errorf("expected default window, got %v", store.Window())
+	}
+
+	if _, ok := store.Summary(testKey); ok {
+		t.Error("expected no summary for an unknown endpoint")
+	}
+
+	store.Record(testKey, model.Result{Time: time.Now()})
+	store.Remove(testKey)
+
+	if _, ok := store.Summary(testKey); ok {
+		t.Error("expected no summary after removal")
+	}
+}
+
+func TestStore_Recent(t *testing.T) {
+	store := results.NewStore(0, 2)
+
+	if _, ok := store.Recent(testKey, 0); ok {
+		t.Error("expected no results for an unknown endpoint")
+	}
+
+	for i := 1; i <= 3; i++ {
+		store.Record(testKey, model.Result{Time: time.Now(), Total: time.Duration(i)})
+	}
+
+	recent, ok := store.Recent(testKey, 0)
+	if !ok || len(recent) != 2 || recent[0].Total != 3 || recent[1].Total != 2 {
+		t.Errorf("expected the last 2 results newest first, got %+v", recent)
+	}
+
+	if recent, _ = store.Recent(testKey, 1); len(recent) != 1 || recent[0].Total != 3 {
+		t.Errorf("expected the newest result only, got %+v", recent)
+	}
+
+	if store.History() != 2 {
+		t.Errorf("unexpected history size: %d", store.History())
+	}
+}
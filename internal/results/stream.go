@@ -0,0 +1,105 @@
+package results
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/dntosas/astrolavos/internal/model"
+)
+
+// Event is a probe result together with the endpoint it belongs to.
+type Event struct {
+	Key    model.Key
+	Result model.Result
+}
+
+// Subscription receives the events matching its filter on C. Events are
+// dropped rather than queued once its buffer is full, so a slow consumer
+// never holds up the probers. C is closed when the Store is closed.
+type Subscription struct {
+	C <-chan Event
+
+	c       chan Event
+	filter  func(Event) bool
+	dropped atomic.Uint64
+}
+
+// Dropped returns the number of events dropped because the buffer was full.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// broadcaster fans events out to subscriptions.
+type broadcaster struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// Subscribe returns a subscription to the results recorded from now on that
+// match filter, or every result when filter is nil. It must be released
+// with Unsubscribe.
+func (s *Store) Subscribe(buffer int, filter func(Event) bool) *Subscription {
+	c := make(chan Event, buffer)
+	sub := &Subscription{C: c, c: c, filter: filter}
+
+	s.events.mu.Lock()
+	defer s.events.mu.Unlock()
+
+	if s.events.closed {
+		close(c)
+
+		return sub
+	}
+
+	if s.events.subs == nil {
+		s.events.subs = map[*Subscription]struct{}{}
+	}
+
+	s.events.subs[sub] = struct{}{}
+
+	return sub
+}
+
+// Unsubscribe stops delivering events to sub and closes its channel.
+func (s *Store) Unsubscribe(sub *Subscription) {
+	s.events.mu.Lock()
+	defer s.events.mu.Unlock()
+
+	if _, ok := s.events.subs[sub]; ok {
+		delete(s.events.subs, sub)
+		close(sub.c)
+	}
+}
+
+// Close closes every subscription, so streaming consumers return during a
+// shutdown, and refuses new ones.
+func (s *Store) Close() {
+	s.events.mu.Lock()
+	defer s.events.mu.Unlock()
+
+	for sub := range s.events.subs {
+		close(sub.c)
+	}
+
+	s.events.subs = nil
+	s.events.closed = true
+}
+
+// publish hands an event to every matching subscription without blocking.
+func (s *Store) publish(e Event) {
+	s.events.mu.Lock()
+	defer s.events.mu.Unlock()
+
+	for sub := range s.events.subs {
+		if sub.filter != nil && !sub.filter(e) {
+			continue
+		}
+
+		select {
+		case sub.c <- e:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package results_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/model"
+	"github.com/dntosas/astrolavos/internal/results"
+)
+
+func TestStore_Subscribe(t *testing.T) {
+	store := results.NewStore(0, 0)
+	other := model.Key{ProberType: "tcp", URI: "example.com:443"}
+
+	sub := store.Subscribe(2, func(e results.Event) bool { return e.Key == testKey })
+
+	// Recording must never block on a full subscription
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		store.Record(other, model.Result{Time: time.Now()})
+
+		for i := 1; i <= 5; i++ {
+			store.Record(testKey, model.Result{Time: time.Now(), Total: time.Duration(i)})
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("recording blocked on a slow subscriber")
+	}
+
+	for i := 1; i <= 2; i++ {
+		e := <-sub.C
+		if e.Key != testKey || e.Result.Total != time.Duration(i) {
+			t.Errorf("unexpected event %d: %+v", i, e)
+		}
+	}
+
+	if sub.Dropped() != 3 {
+		t.Errorf("expected 3 dropped events, got %d", sub.Dropped())
+	}
+
+	store.Unsubscribe(sub)
+
+	if _, ok := <-sub.C; ok {
+		t.Error("expected channel to be closed after unsubscribing")
+	}
+
+	// Unsubscribing twice is harmless
+	store.Unsubscribe(sub)
+}
+
+func TestStore_Close(t *testing.T) {
+	store := results.NewStore(0, 0)
+	sub := store.Subscribe(1, nil)
+
+	store.Close()
+
+	if _, ok := <-sub.C; ok {
+		t.Error("expected subscriptions to be closed")
+	}
+
+	if _, ok := <-store.Subscribe(1, nil).C; ok {
+		t.Error("expected subscriptions after close to be closed")
+	}
+
+	store.Unsubscribe(sub)
+	store.Record(testKey, model.Result{Time: time.Now()})
+}
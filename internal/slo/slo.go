@@ -0,0 +1,185 @@
+// Package slo evaluates the service level objectives of endpoints over a
+// sliding window of their probe results, without an external time series
+// database.
+package slo
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/model"
+)
+
+// slots is the number of buckets a window is divided into. The window
+// slides by a sixtieth of its length, e.g. by a minute for an hour.
+const slots = 60
+
+// Objective names, used as keys of the remaining error budgets.
+const (
+	ObjectiveAvailability = "availability"
+	ObjectiveLatency      = "latency"
+)
+
+// Status is the evaluation of an endpoint's objectives over its window.
+type Status struct {
+	Compliant bool
+	// Samples is the number of probes within the window.
+	Samples int
+	// SuccessRatio is the share of successful probes, and LatencyRatio the
+	// share of successful probes within the latency threshold. Both are 1
+	// without samples.
+	SuccessRatio float64
+	LatencyRatio float64
+	// ErrorBudgetRemaining is the share of the error budget left for every
+	// set objective. It is 1 when untouched and negative once overspent.
+	ErrorBudgetRemaining map[string]float64
+}
+
+// Tracker counts the probe results of every endpoint with an SLO.
+// It is safe for concurrent use.
+type Tracker struct {
+	mu      sync.Mutex
+	windows map[model.Key]*window
+}
+
+// window holds the counters of an endpoint in a ring of slots, each
+// covering width of time.
+type window struct {
+	slo   model.SLO
+	width time.Duration
+	slots [slots]slot
+}
+
+func newWindow(s model.SLO) *window {
+	return &window{slo: s, width: max(s.Window/slots, 1)}
+}
+
+// slot counts the probes whose time falls in the index-th width since the
+// epoch.
+type slot struct {
+	index     int64
+	total     int
+	successes int
+	fast      int
+}
+
+// NewTracker creates an empty Tracker.
+func NewTracker() *Tracker {
+	return &Tracker{windows: map[model.Key]*window{}}
+}
+
+// Observe counts the result of a probe of e and returns the evaluation of
+// its objectives, or false if e has no SLO. Counters are reset when the
+// objectives of the endpoint change.
+func (t *Tracker) Observe(e *model.Endpoint, r model.Result) (Status, bool) {
+	if e.SLO == nil {
+		return Status{}, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	w, ok := t.windows[e.Key()]
+	if !ok || w.slo != *e.SLO {
+		w = newWindow(*e.SLO)
+		t.windows[e.Key()] = w
+	}
+
+	index := r.Time.UnixNano() / int64(w.width)
+
+	s := &w.slots[index%slots]
+	if s.index != index {
+		*s = slot{index: index}
+	}
+
+	s.total++
+
+	if r.Success() {
+		s.successes++
+
+		if r.Total <= w.slo.LatencyThreshold {
+			s.fast++
+		}
+	}
+
+	return w.evaluate(r.Time), true
+}
+
+// Status returns the current evaluation of e's objectives, or false if e
+// has no SLO. Objectives are met until results are observed.
+func (t *Tracker) Status(e *model.Endpoint) (Status, bool) {
+	if e.SLO == nil {
+		return Status{}, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	w, ok := t.windows[e.Key()]
+	if !ok || w.slo != *e.SLO {
+		w = newWindow(*e.SLO)
+	}
+
+	return w.evaluate(time.Now()), true
+}
+
+// Remove forgets the counters of an endpoint.
+func (t *Tracker) Remove(key model.Key) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.windows, key)
+}
+
+// evaluate sums the slots within the window ending at now and checks them
+// against the objectives. The latency percentile is within the threshold
+// when at least that share of successful probes are.
+func (w *window) evaluate(now time.Time) Status {
+	current := now.UnixNano() / int64(w.width)
+
+	var total, successes, fast int
+
+	for _, s := range w.slots {
+		if s.index > current-slots && s.index <= current {
+			total += s.total
+			successes += s.successes
+			fast += s.fast
+		}
+	}
+
+	st := Status{
+		Compliant:            true,
+		Samples:              total,
+		SuccessRatio:         ratio(successes, total),
+		LatencyRatio:         ratio(fast, successes),
+		ErrorBudgetRemaining: map[string]float64{},
+	}
+
+	if w.slo.SuccessRatio > 0 {
+		st.Compliant = st.SuccessRatio >= w.slo.SuccessRatio
+		st.ErrorBudgetRemaining[ObjectiveAvailability] = budgetRemaining(st.SuccessRatio, w.slo.SuccessRatio)
+	}
+
+	if w.slo.LatencyThreshold > 0 {
+		target := w.slo.LatencyPercentile / 100
+		st.Compliant = st.Compliant && st.LatencyRatio >= target
+		st.ErrorBudgetRemaining[ObjectiveLatency] = budgetRemaining(st.LatencyRatio, target)
+	}
+
+	return st
+}
+
+// ratio returns n/total, or 1 without samples.
+func ratio(n, total int) float64 {
+	if total == 0 {
+		return 1
+	}
+
+	return float64(n) / float64(total)
+}
+
+// budgetRemaining returns the share of the error budget 1-target left when
+// the good ratio is achieved.
+func budgetRemaining(achieved, target float64) float64 {
+	return 1 - (1-achieved)/(1-target)
+}
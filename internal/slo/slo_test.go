@@ -0,0 +1,130 @@
+package slo_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/dntosas/astrolavos/internal/model"
+	"github.com/dntosas/astrolavos/internal/slo"
+)
+
+func newEndpoint(s *model.SLO) *model.Endpoint {
+	return &model.Endpoint{URI: "http://example.com", ProberType: "httpTrace", Tag: "prod", SLO: s}
+}
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestTracker_NoSLO(t *testing.T) {
+	tracker := slo.NewTracker()
+
+	if _, ok := tracker.Observe(newEndpoint(nil), model.Result{Time: time.Now()}); ok {
+		t.Error("expected endpoints without an SLO to be ignored")
+	}
+}
+
+func TestTracker_Latency(t *testing.T) {
+	tracker := slo.NewTracker()
+	e := newEndpoint(&model.SLO{Window: time.Hour, LatencyPercentile: 95, LatencyThreshold: 300 * time.Millisecond})
+	now := time.Now()
+
+	var st slo.Status
+
+	// 95 fast and 5 slow probes put the p95 right at the threshold
+	for i := range 100 {
+		total := 100 * time.Millisecond
+		if i < 5 {
+			total = time.Second
+		}
+
+		st, _ = tracker.Observe(e, model.Result{Time: now, Total: total})
+	}
+
+	if !st.Compliant {
+		t.Errorf("expected p95 within the threshold to be compliant, got %+v", st)
+	}
+
+	if !approx(st.ErrorBudgetRemaining[slo.ObjectiveLatency], 0) {
+		t.Errorf("expected the latency budget to be spent, got %v", st.ErrorBudgetRemaining[slo.ObjectiveLatency])
+	}
+
+	if _, ok := st.ErrorBudgetRemaining[slo.ObjectiveAvailability]; ok {
+		t.Error("expected no availability budget without a success ratio objective")
+	}
+
+	st, _ = tracker.Observe(e, model.Result{Time: now, Total: time.Second})
+
+	if st.Compliant {
+		t.Errorf("expected p95 above the threshold to break the SLO, got %+v", st)
+	}
+
+	if st.ErrorBudgetRemaining[slo.ObjectiveLatency] >= 0 {
+		t.Errorf("expected an overspent latency budget, got %v", st.ErrorBudgetRemaining[slo.ObjectiveLatency])
+	}
+}
+
+func TestTracker_Availability(t *testing.T) {
+	tracker := slo.NewTracker()
+	e := newEndpoint(&model.SLO{Window: time.Hour, SuccessRatio: 0.9})
+	now := time.Now()
+
+	var st slo.Status
+
+	for i := range 20 {
+		r := model.Result{Time: now, Total: time.Second}
+		if i == 0 {
+			r.Err = errors.New("connection refused")
+		}
+
+		st, _ = tracker.Observe(e, r)
+	}
+
+	if !st.Compliant || st.Samples != 20 || !approx(st.SuccessRatio, 0.95) {
+		t.Errorf("unexpected status: %+v", st)
+	}
+
+	if got := st.ErrorBudgetRemaining[slo.ObjectiveAvailability]; !approx(got, 0.5) {
+		t.Errorf("expected half of the availability budget left, got %v", got)
+	}
+}
+
+func TestTracker_SlidingWindow(t *testing.T) {
+	tracker := slo.NewTracker()
+	e := newEndpoint(&model.SLO{Window: time.Hour, SuccessRatio: 0.9})
+	start := time.Now()
+
+	tracker.Observe(e, model.Result{Time: start, Err: errors.New("timeout")})
+
+	st, _ := tracker.Observe(e, model.Result{Time: start.Add(30 * time.Minute)})
+	if st.Compliant || st.Samples != 2 {
+		t.Errorf("expected both probes within the window, got %+v", st)
+	}
+
+	st, _ = tracker.Observe(e, model.Result{Time: start.Add(2 * time.Hour)})
+	if !st.Compliant || st.Samples != 1 {
+		t.Errorf("expected the old probes to slide out of the window, got %+v", st)
+	}
+}
+
+func TestTracker_ChangedSLO(t *testing.T) {
+	tracker := slo.NewTracker()
+	now := time.Now()
+
+	tracker.Observe(newEndpoint(&model.SLO{Window: time.Hour, SuccessRatio: 0.9}), model.Result{Time: now, Err: errors.New("timeout")})
+
+	st, _ := tracker.Observe(newEndpoint(&model.SLO{Window: time.Hour, SuccessRatio: 0.99}), model.Result{Time: now})
+	if st.Samples != 1 || !st.Compliant {
+		t.Errorf("expected counters to be reset for changed objectives, got %+v", st)
+	}
+
+	e := newEndpoint(&model.SLO{Window: time.Hour, SuccessRatio: 0.99})
+	tracker.Remove(e.Key())
+
+	st, ok := tracker.Status(e)
+	if !ok || st.Samples != 0 || !st.Compliant || st.ErrorBudgetRemaining[slo.ObjectiveAvailability] != 1 {
+		t.Errorf("expected an untouched status after removal, got %+v", st)
+	}
+}
@@ -0,0 +1,57 @@
+// Package tracing exports the traces of probes to an OpenTelemetry
+// collector over OTLP.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dntosas/astrolavos/internal/model"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// ScopeName is the instrumentation scope of probe spans.
+const ScopeName = "github.com/dntosas/astrolavos/internal/probers"
+
+// NewTracerProvider creates a tracer provider batching spans to the
+// collector at cfg.TracesEndpoint. Sampling follows the standard
+// OTEL_TRACES_SAMPLER variables and defaults to every probe.
+func NewTracerProvider(cfg model.OTLP) (*sdktrace.TracerProvider, error) {
+	ctx := context.Background()
+
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+
+	switch cfg.Protocol {
+	case "grpc":
+		exporter, err = otlptracegrpc.New(ctx,
+			otlptracegrpc.WithEndpointURL(cfg.TracesEndpoint),
+			otlptracegrpc.WithHeaders(cfg.Headers),
+		)
+	case "http":
+		exporter, err = otlptracehttp.New(ctx,
+			otlptracehttp.WithEndpointURL(cfg.TracesEndpoint),
+			otlptracehttp.WithHeaders(cfg.Headers),
+		)
+	default:
+		return nil, fmt.Errorf("unknown OTLP protocol: %s", cfg.Protocol)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("creation of OTLP trace exporter failed: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", "astrolavos")))
+	if err != nil {
+		return nil, fmt.Errorf("creation of OTLP resource failed: %w", err)
+	}
+
+	return sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res)), nil
+}
@@ -2,11 +2,18 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"io"
 	"os"
+	"os/signal"
+	"slices"
+	"syscall"
 
+	"github.com/dntosas/astrolavos/internal/cli"
 	"github.com/dntosas/astrolavos/internal/config"
 	"github.com/dntosas/astrolavos/internal/machinery"
+	"github.com/dntosas/astrolavos/internal/report"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -15,17 +22,39 @@ var (
 	// Version of the tool that gets written during build time.
 	Version = "dev"
 	// CommitHash of the code that gets written during build time.
-	CommitHash     = ""
-	oneOffFlag     = flag.Bool("oneoff", false, "Run the probe measurements one time and exit.")
-	configPathFlag = flag.String("config-path", "/etc/astrolavos", "Specify the path of the config file.")
+	CommitHash      = ""
+	oneOffFlag      = flag.Bool("oneoff", false, "Run the probe measurements one time and exit.")
+	configPathFlag  = flag.String("config-path", "/etc/astrolavos", "Specify the path of the config file.")
+	outputFlag      = flag.String("output", report.FormatText, "Format of the oneoff report printed to stdout: text or json.")
+	maxFailuresFlag = flag.String("max-failures", "0", "Failed probes a oneoff run tolerates before exiting with an error, as a count or a percentage such as 10%.")
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "probe":
+			os.Exit(runCommand(cli.Probe))
+		case "validate":
+			os.Exit(runCommand(cli.Validate))
+		case "bench":
+			os.Exit(runCommand(cli.Bench))
+		}
+	}
+
 	flag.Parse()
 
 	// Initialize logging early for better error visibility
 	initLogging("INFO") // Default level before config is loaded
 
+	if !slices.Contains(report.Formats, *outputFlag) {
+		log.Fatalf("Invalid -output %q: must be one of %v", *outputFlag, report.Formats)
+	}
+
+	maxFailures, err := report.ParseThreshold(*maxFailuresFlag)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid -max-failures")
+	}
+
 	log.WithFields(log.Fields{
 		"version": Version,
 		"commit":  CommitHash,
@@ -39,14 +68,43 @@ func main() {
 	// Re-initialize logging with config level
 	initLogging(cfg.LogLevel)
 
-	a := machinery.NewAstrolavos(cfg.AppPort, cfg.Endpoints, cfg.PromPushGateway, Version, cfg.MaxPayloadSize, *oneOffFlag)
+	a := machinery.NewAstrolavos(machinery.Options{
+		Port:           cfg.AppPort,
+		Endpoints:      cfg.Endpoints,
+		PushGateway:    cfg.PushGateway,
+		LabelNames:     cfg.LabelNames,
+		ExternalLabels: cfg.ExternalLabels,
+		Version:        Version,
+		MaxPayloadSize: cfg.MaxPayloadSize,
+		IsOneOff:       *oneOffFlag,
+		Reloader:       cfg,
+		Mesh:           cfg.Mesh,
+		ResultsWindow:  cfg.ResultsWindow,
+		ResultsHistory: cfg.ResultsHistory,
+		Alerting:       cfg.Alerting,
+		OTLP:           cfg.OTLP,
+		DogStatsD:      cfg.DogStatsD,
+		Output:         *outputFlag,
+		MaxFailures:    maxFailures,
+	})
 	if err := a.Start(); err != nil {
-		log.WithError(err).Fatal("Failed to start Astrolavos")
+		log.WithError(err).Fatal("Astrolavos failed")
 	}
 
 	log.Info("Shutting down Astrolavos...")
 }
 
+// runCommand runs a subcommand with the arguments following its name until
+// it finishes or the process is interrupted, and returns its exit code.
+func runCommand(cmd func(ctx context.Context, args []string, stdout, stderr io.Writer) int) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.SetOutput(os.Stderr)
+
+	return cmd(ctx, os.Args[2:], os.Stdout, os.Stderr)
+}
+
 // initLogging initializes structured JSON logging at the specified level.
 func initLogging(logLevel string) {
 	l, err := log.ParseLevel(logLevel)
@@ -56,7 +114,14 @@ func initLogging(logLevel string) {
 	}
 
 	log.SetLevel(l)
-	log.SetOutput(os.Stdout)
+
+	// Keep stdout for the report of oneoff runs
+	if *oneOffFlag {
+		log.SetOutput(os.Stderr)
+	} else {
+		log.SetOutput(os.Stdout)
+	}
+
 	log.SetFormatter(&log.JSONFormatter{
 		TimestampFormat: "2006-01-02T15:04:05.000Z07:00",
 		FieldMap: log.FieldMap{